@@ -2,39 +2,83 @@ package pipelinex
 
 // PipelineConfig 流水线配置结构
 type PipelineConfig struct {
-	Version   string                    `yaml:"Version"`
-	Name      string                    `yaml:"Name"`
-	Metadate  MetadataConfig            `yaml:"Metadate"`
-	AI        AIConfig                  `yaml:"AI"`
-	Param     map[string]interface{}    `yaml:"Param"`
-	Executors map[string]ExecutorConfig `yaml:"Executors"`
-	Logging   LoggingConfig             `yaml:"Logging"`
-	Graph     string                    `yaml:"Graph"`
-	Status    map[string]string         `yaml:"Status"`
-	Nodes     map[string]NodeConfig     `yaml:"Nodes"`
+	Version    string                    `yaml:"Version"`
+	Name       string                    `yaml:"Name"`
+	Metadate   MetadataConfig            `yaml:"Metadate"`
+	AI         AIConfig                  `yaml:"AI"`
+	Param      map[string]interface{}    `yaml:"Param"`
+	Executors  map[string]ExecutorConfig `yaml:"Executors"`
+	Logging    LoggingConfig             `yaml:"Logging"`
+	Graph      string                    `yaml:"Graph"`
+	Expression ExpressionConfig          `yaml:"Expression"`
+	Status     map[string]string         `yaml:"Status"`
+	Groups     map[string]GroupConfig    `yaml:"Groups"`
+	Nodes      map[string]NodeConfig     `yaml:"Nodes"`
+}
+
+// ExpressionConfig 条件表达式配置
+type ExpressionConfig struct {
+	Engine string            `yaml:"engine"` // 条件边使用的表达式引擎：pongo2（默认）| cel
+	Schema map[string]string `yaml:"schema"` // cel 引擎的顶层变量类型声明，声明后编译时做类型检查
 }
 
 // MetadataConfig 元数据配置结构
 type MetadataConfig struct {
-	Type string                 `yaml:"type"`
-	Data map[string]interface{} `yaml:"data"`
+	Type  string                 `yaml:"type"`
+	Scope string                 `yaml:"scope"` // key 的命名空间：global | pipeline | run
+	TTL   string                 `yaml:"ttl"`   // 写入 key 的默认过期时间，例如 24h
+	Data  map[string]interface{} `yaml:"data"`
 }
 
 // HTTPMetadataConfig HTTP元数据配置
 type HTTPMetadataConfig struct {
-	URL     string            `yaml:"url"`
-	Method  string            `yaml:"method"`
-	Headers map[string]string `yaml:"headers"`
-	Timeout string            `yaml:"timeout"`
+	URL          string             `yaml:"url"`
+	Method       string             `yaml:"method"` // 写入使用的方法：PUT | POST
+	Headers      map[string]string  `yaml:"headers"`
+	Timeout      string             `yaml:"timeout"`
+	Token        string             `yaml:"token"`    // Bearer 认证
+	Username     string             `yaml:"username"` // Basic 认证
+	Password     string             `yaml:"password"`
+	Retries      int                `yaml:"retries"`       // 失败重试次数
+	RetryBackoff string             `yaml:"retry_backoff"` // 首次重试等待时间，之后指数增长
+	TLS          *MetadataTLSConfig `yaml:"tls"`
+}
+
+// MetadataTLSConfig 元数据存储的TLS配置
+type MetadataTLSConfig struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
 }
 
 // RedisMetadataConfig Redis元数据配置
 type RedisMetadataConfig struct {
-	Host     string `yaml:"host"`
-	Port     int    `yaml:"port"`
-	DB       int    `yaml:"db"`
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
+	URL              string             `yaml:"url"`  // redis:// 或 rediss:// 连接串，与 host/port 二选一
+	Mode             string             `yaml:"mode"` // single | sentinel | cluster，为空时按配置推断
+	Host             string             `yaml:"host"`
+	Port             int                `yaml:"port"`
+	Addrs            []string           `yaml:"addrs"` // sentinel 或 cluster 节点地址
+	MasterName       string             `yaml:"master_name"`
+	DB               int                `yaml:"db"`
+	Username         string             `yaml:"username"`
+	Password         string             `yaml:"password"`
+	SentinelUsername string             `yaml:"sentinel_username"`
+	SentinelPassword string             `yaml:"sentinel_password"`
+	PoolSize         int                `yaml:"pool_size"`
+	MinIdleConns     int                `yaml:"min_idle_conns"`
+	MaxRetries       int                `yaml:"max_retries"`
+	DialTimeout      string             `yaml:"dial_timeout"`
+	ReadTimeout      string             `yaml:"read_timeout"`
+	WriteTimeout     string             `yaml:"write_timeout"`
+	PoolTimeout      string             `yaml:"pool_timeout"`
+	TLS              *MetadataTLSConfig `yaml:"tls"`
+}
+
+// FileMetadataConfig 文件元数据配置
+type FileMetadataConfig struct {
+	Path string `yaml:"path"`
 }
 
 // AIConfig AI配置结构
@@ -62,8 +106,9 @@ type LoggingConfig struct {
 
 // Step 步骤配置结构
 type Step struct {
-	Name string `yaml:"name"`
-	Run  string `yaml:"run"`
+	Name    string   `yaml:"name"`
+	Run     string   `yaml:"run"`
+	Outputs []string `yaml:"outputs"` // 声明步骤写入的输出 key，供配置检查使用
 }
 
 // NodeConfig 节点配置结构
@@ -72,4 +117,12 @@ type NodeConfig struct {
 	Image    string                 `yaml:"image"`
 	Steps    []Step                 `yaml:"steps"`
 	Config   map[string]interface{} `yaml:"Config"`
-}
\ No newline at end of file
+}
+
+// GroupConfig 节点组配置，键为 Graph 中复合状态的名称
+// 组内节点未设置的 executor、image 从组继承，Config 按 key 合并，节点的值优先；嵌套组从外层组继承
+type GroupConfig struct {
+	Executor string                 `yaml:"executor"`
+	Image    string                 `yaml:"image"`
+	Config   map[string]interface{} `yaml:"Config"`
+}
@@ -10,6 +10,11 @@ const (
 	StatusUnknown   = "UNKNOWN"
 	StatusCancelled = "CANCELLED"
 
+	// 元数据作用域常量
+	MetadataScopeGlobal   = "global"   // 所有流水线共享同一个 keyspace
+	MetadataScopePipeline = "pipeline" // 按流水线名称隔离
+	MetadataScopeRun      = "run"      // 按流水线名称和运行ID隔离
+
 	// 流水线事件常量
 	EventPipelineInit                = "pipeline-init"
 	EventPipelineStart               = "pipeline-start"
@@ -18,6 +23,8 @@ const (
 	EventPipelineExecutorPrepareDone = "pipeline-executor-prepare-done"
 	EventPipelineNodeStart           = "pipeline-node-start"
 	EventPipelineNodeFinish          = "pipeline-node-finish"
+	EventPipelineGroupStart          = "pipeline-group-start"
+	EventPipelineGroupFinish         = "pipeline-group-finish"
 	EventPipelineCancelled           = "pipeline-cancelled"
 	EventPipelineStatusUpdate        = "pipeline-status-update"
 )
@@ -11,7 +11,7 @@ type Edge interface {
 	Expression() string
 	// Evaluate 评估条件表达式，返回bool表示是否通过
 	Evaluate(ctx EvaluationContext) (bool, error)
-	// ID 返回边的唯一标识符（格式：source->target）
+	// ID 返回边的标识符（格式：source->target），同一对节点之间的并行边ID相同
 	ID() string
 }
 
@@ -0,0 +1,88 @@
+package pipelinex
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 预检查GuardedEdge是否实现了Edge接口
+var _ Edge = (*GuardedEdge)(nil)
+
+// EdgeCondition 边上的一个条件，Negate 为 true 时条件不满足才能通过
+type EdgeCondition struct {
+	Expression string
+	Negate     bool
+}
+
+// GuardedEdge 由多个条件共同决定的边，所有条件都满足时才能通过
+// 经过 fork/join/choice 伪状态的路径合并为一条 GuardedEdge，
+// 例如 choice 的第二个分支为「第一个分支的条件不满足且自身条件满足」
+type GuardedEdge struct {
+	source     Node
+	target     Node
+	conditions []EdgeCondition
+	engine     TemplateEngine
+}
+
+// NewGuardedEdge 创建一条由多个条件共同决定的边，没有条件时总是可以通过
+func NewGuardedEdge(source, target Node, conditions []EdgeCondition, engine TemplateEngine) Edge {
+	return &GuardedEdge{
+		source:     source,
+		target:     target,
+		conditions: conditions,
+		engine:     engine,
+	}
+}
+
+// Source 返回边的源节点
+func (e *GuardedEdge) Source() Node {
+	return e.source
+}
+
+// Target 返回边的目标节点
+func (e *GuardedEdge) Target() Node {
+	return e.target
+}
+
+// Conditions 返回边上的全部条件
+func (e *GuardedEdge) Conditions() []EdgeCondition {
+	return append([]EdgeCondition(nil), e.conditions...)
+}
+
+// Expression 返回用 && 连接的条件，取反的条件以 ! 开头，仅用于展示和检查引用的变量
+func (e *GuardedEdge) Expression() string {
+	parts := make([]string, len(e.conditions))
+	for i, c := range e.conditions {
+		if c.Negate {
+			parts[i] = "!" + c.Expression
+		} else {
+			parts[i] = c.Expression
+		}
+	}
+	return strings.Join(parts, " && ")
+}
+
+// ID 返回边的唯一标识符
+func (e *GuardedEdge) ID() string {
+	return fmt.Sprintf("%s->%s", e.source.Id(), e.target.Id())
+}
+
+// Evaluate 按顺序评估条件，遇到不满足的条件立即返回false
+func (e *GuardedEdge) Evaluate(ctx EvaluationContext) (bool, error) {
+	engine := e.engine
+	if engine == nil {
+		engine = defaultTemplateEngine
+	}
+
+	values := ctx.All()
+	for _, c := range e.conditions {
+		result, err := engine.EvaluateBool(c.Expression, values)
+		if err != nil {
+			return false, err
+		}
+		if result == c.Negate {
+			return false, nil
+		}
+	}
+	return true, nil
+}
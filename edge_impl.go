@@ -65,17 +65,17 @@ func (e *DGAEdge) ID() string {
 
 // Evaluate 评估条件表达式
 // 如果表达式为空，返回true（无条件边总是可以通过）
-// 如果有表达式但没有设置模板引擎，使用默认的Pongo2模板引擎
+// 如果有表达式但没有设置模板引擎，使用共享的默认Pongo2模板引擎
 func (e *DGAEdge) Evaluate(ctx EvaluationContext) (bool, error) {
 	// 无条件边总是返回true
 	if e.expression == "" {
 		return true, nil
 	}
 
-	// 获取模板引擎
+	// 获取模板引擎，未设置时使用共享的默认引擎以复用编译缓存
 	engine := e.engine
 	if engine == nil {
-		engine = NewPongo2TemplateEngine()
+		engine = defaultTemplateEngine
 	}
 
 	// 评估表达式
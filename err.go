@@ -3,6 +3,14 @@ package pipelinex
 import "errors"
 
 var (
-	ErrInvalidGraph = errors.New("invalid graph")
-	ErrHasCycle     = errors.New("has cycle")
+	ErrInvalidGraph     = errors.New("invalid graph")
+	ErrHasCycle         = errors.New("has cycle")
+	ErrKeyNotFound      = errors.New("key not found")
+	ErrGraphSyntax      = errors.New("invalid graph syntax")
+	ErrUnknownNode      = errors.New("unknown node")
+	ErrUnreferencedNode = errors.New("node not referenced by graph")
+	ErrUnreachableNode  = errors.New("node not reachable from [*]")
+	ErrNoTerminal       = errors.New("no terminal node reached")
+	ErrVertexNotFound   = errors.New("vertex not found")
+	ErrEdgeNotFound     = errors.New("edge not found")
 )
@@ -1,6 +1,9 @@
 package pipelinex
 
-import "context"
+import (
+	"context"
+	"io"
+)
 
 // Executor 执行器
 type Executor interface {
@@ -21,3 +24,20 @@ type Bridge interface {
 	// Conn 连接到环境中
 	Conn(ctx context.Context, adapter Adapter) (Executor, error)
 }
+
+// StepRunner 步骤执行器，负责在节点引用的执行器环境中执行单个步骤
+type StepRunner interface {
+	// RunStep 执行步骤，标准输出和标准错误写入 output
+	RunStep(ctx context.Context, execution StepExecution, output io.Writer) error
+}
+
+// StepExecution 单个步骤的执行信息
+type StepExecution struct {
+	Pipeline string                 // 流水线ID
+	Node     string                 // 节点ID
+	Step     Step                   // 步骤配置
+	Image    string                 // 节点镜像
+	Executor ExecutorConfig         // 节点引用的执行器配置
+	Config   map[string]interface{} // 节点配置
+	Env      map[string]string      // 额外注入的环境变量
+}
@@ -0,0 +1,48 @@
+package pipelinex
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// 预检查LocalStepRunner是否实现了StepRunner接口
+var _ StepRunner = (*LocalStepRunner)(nil)
+
+// LocalStepRunner 在本机通过 shell 执行步骤，对应 local 类型的执行器
+type LocalStepRunner struct{}
+
+// NewLocalStepRunner 创建本机步骤执行器
+func NewLocalStepRunner() *LocalStepRunner {
+	return &LocalStepRunner{}
+}
+
+// RunStep 使用 config.shell（默认 sh）执行 run 命令，工作目录为 config.workdir
+func (r *LocalStepRunner) RunStep(ctx context.Context, execution StepExecution, output io.Writer) error {
+	if execution.Executor.Type != "" && execution.Executor.Type != "local" {
+		return fmt.Errorf("local step runner does not support executor type %s", execution.Executor.Type)
+	}
+
+	shell := "sh"
+	if s, ok := execution.Executor.Config["shell"].(string); ok && s != "" {
+		shell = s
+	}
+
+	cmd := exec.CommandContext(ctx, shell, "-c", execution.Step.Run)
+	if workdir, ok := execution.Executor.Config["workdir"].(string); ok && workdir != "" {
+		if err := os.MkdirAll(workdir, 0o755); err != nil {
+			return fmt.Errorf("failed to create workdir: %w", err)
+		}
+		cmd.Dir = workdir
+	}
+
+	cmd.Env = os.Environ()
+	for k, v := range execution.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	return cmd.Run()
+}
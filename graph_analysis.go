@@ -0,0 +1,348 @@
+package pipelinex
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CycleError 图中存在环，Path 为环上的节点，首尾是同一个节点
+// errors.Is(err, ErrHasCycle) 对 CycleError 成立
+type CycleError struct {
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrHasCycle, strings.Join(e.Path, " -> "))
+}
+
+func (e *CycleError) Unwrap() error {
+	return ErrHasCycle
+}
+
+// TopologicalOrder 返回节点的拓扑序，同时可以执行的节点按ID排序，结果是确定的
+// 存在环时返回 *CycleError
+func (dga *DGAGraph) TopologicalOrder() ([]string, error) {
+	dga.mu.RLock()
+	defer dga.mu.RUnlock()
+	return dga.topologicalOrder()
+}
+
+// Ancestors 返回节点的全部上游节点（按ID排序），节点不存在时返回nil
+func (dga *DGAGraph) Ancestors(id string) []string {
+	dga.mu.RLock()
+	defer dga.mu.RUnlock()
+	if _, ok := dga.nodes[id]; !ok {
+		return nil
+	}
+	return dga.reachable(id, dga.predecessors())
+}
+
+// Descendants 返回节点的全部下游节点（按ID排序），节点不存在时返回nil
+func (dga *DGAGraph) Descendants(id string) []string {
+	dga.mu.RLock()
+	defer dga.mu.RUnlock()
+	if _, ok := dga.nodes[id]; !ok {
+		return nil
+	}
+	return dga.reachable(id, dga.graph)
+}
+
+// CriticalPath 返回权重之和最大的路径及其权重，weight 为 nil 时每个节点的权重为1（即最长路径）
+// 权重相同的路径中返回拓扑序靠前的一条；存在环时返回 *CycleError
+func (dga *DGAGraph) CriticalPath(weight func(node Node) float64) ([]string, float64, error) {
+	dga.mu.RLock()
+	defer dga.mu.RUnlock()
+
+	order, err := dga.topologicalOrder()
+	if err != nil {
+		return nil, 0, err
+	}
+	if weight == nil {
+		weight = func(Node) float64 { return 1 }
+	}
+
+	predecessors := dga.predecessors()
+	total := make(map[string]float64, len(order))
+	prev := make(map[string]string, len(order))
+	end := ""
+	for _, id := range order {
+		best, from := 0.0, ""
+		for _, p := range predecessors[id] {
+			if from == "" || total[p] > best {
+				best, from = total[p], p
+			}
+		}
+		total[id] = best + weight(dga.nodes[id])
+		prev[id] = from
+		if end == "" || total[id] > total[end] {
+			end = id
+		}
+	}
+	if end == "" {
+		return nil, 0, nil
+	}
+
+	var path []string
+	for id := end; id != ""; id = prev[id] {
+		path = append(path, id)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, total[end], nil
+}
+
+// RedundantEdges 返回删除后不影响执行顺序的边（按ID排序）
+// 目标节点本来就要等待从源节点出发的另一条路径时，两个节点之间的边都是冗余的；
+// 并行边中有无条件边时只需要保留第一条无条件边；只有条件边的节点对会阻塞目标节点，不视为冗余
+func (dga *DGAGraph) RedundantEdges() []Edge {
+	dga.mu.RLock()
+	defer dga.mu.RUnlock()
+	_, redundant := dga.reduceEdges()
+	return redundant
+}
+
+// TransitiveReduction 返回删除冗余边后的新图，节点、节点组、入口和终止节点与原图相同
+func (dga *DGAGraph) TransitiveReduction() (*DGAGraph, error) {
+	dga.mu.RLock()
+	defer dga.mu.RUnlock()
+
+	if _, err := dga.topologicalOrder(); err != nil {
+		return nil, err
+	}
+	kept, _ := dga.reduceEdges()
+
+	reduced := NewDGAGraph()
+	for _, node := range dga.nodes {
+		reduced.AddVertex(node)
+	}
+	for _, group := range dga.groups {
+		reduced.AddGroup(group)
+	}
+	for id := range dga.entries {
+		reduced.AddEntry(dga.nodes[id])
+	}
+	for id := range dga.terminals {
+		reduced.AddTerminal(dga.nodes[id])
+	}
+	for _, edge := range kept {
+		if err := reduced.AddEdge(edge); err != nil {
+			return nil, err
+		}
+	}
+	return reduced, nil
+}
+
+// FindCycle 返回图中的一个环，没有环时返回nil
+func (dga *DGAGraph) FindCycle() []string {
+	dga.mu.RLock()
+	defer dga.mu.RUnlock()
+	return dga.findCycle()
+}
+
+// topologicalOrder 使用 Kahn 算法计算拓扑序，调用方需持有读锁
+func (dga *DGAGraph) topologicalOrder() ([]string, error) {
+	indeg := dga.getIndegrees()
+	var ready []string
+	for id, d := range indeg {
+		if d == 0 {
+			ready = append(ready, id)
+		}
+	}
+	sort.Strings(ready)
+
+	order := make([]string, 0, len(dga.nodes))
+	for len(ready) > 0 {
+		id := ready[0]
+		ready = ready[1:]
+		order = append(order, id)
+		for _, next := range dga.graph[id] {
+			indeg[next]--
+			if indeg[next] == 0 {
+				// 按ID有序插入
+				i := sort.SearchStrings(ready, next)
+				ready = append(ready, "")
+				copy(ready[i+1:], ready[i:])
+				ready[i] = next
+			}
+		}
+	}
+	if len(order) != len(dga.nodes) {
+		return nil, &CycleError{Path: dga.findCycle()}
+	}
+	return order, nil
+}
+
+// predecessors 返回每个节点的直接上游节点，调用方需持有读锁
+func (dga *DGAGraph) predecessors() map[string][]string {
+	result := make(map[string][]string, len(dga.nodes))
+	for src, targets := range dga.graph {
+		for _, dest := range targets {
+			result[dest] = append(result[dest], src)
+		}
+	}
+	for _, sources := range result {
+		sort.Strings(sources)
+	}
+	return result
+}
+
+// reachable 返回沿 adjacency 从 id 可以到达的节点（不包括 id 本身，除非在环上），调用方需持有读锁
+func (dga *DGAGraph) reachable(id string, adjacency map[string][]string) []string {
+	seen := make(map[string]bool)
+	queue := append([]string(nil), adjacency[id]...)
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		if seen[next] {
+			continue
+		}
+		seen[next] = true
+		queue = append(queue, adjacency[next]...)
+	}
+	return sortedKeys(seen)
+}
+
+// reduceEdges 把边分为传递约简后保留的边和冗余的边，均按ID排序，调用方需持有读锁
+func (dga *DGAGraph) reduceEdges() (kept, redundant []Edge) {
+	for _, edge := range dga.sortedEdges() {
+		src, dest := edge.Source().Id(), edge.Target().Id()
+		unconditional := dga.firstUnconditional(src, dest)
+		switch {
+		case unconditional == nil:
+			kept = append(kept, edge)
+		case dga.hasAlternatePath(src, dest):
+			redundant = append(redundant, edge)
+		case edge == unconditional:
+			kept = append(kept, edge)
+		default:
+			redundant = append(redundant, edge)
+		}
+	}
+	return kept, redundant
+}
+
+// firstUnconditional 返回两个节点之间的第一条无条件边，没有时返回nil，调用方需持有读锁
+func (dga *DGAGraph) firstUnconditional(src, dest string) Edge {
+	for _, edge := range dga.edgeMap[src][dest] {
+		if edge.Expression() == "" {
+			return edge
+		}
+	}
+	return nil
+}
+
+// hasAlternatePath 判断不经过 src 到 dest 的直接边能否从 src 到达 dest，调用方需持有读锁
+func (dga *DGAGraph) hasAlternatePath(src, dest string) bool {
+	seen := map[string]bool{src: true}
+	var queue []string
+	for _, next := range dga.graph[src] {
+		if next != dest {
+			queue = append(queue, next)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == dest {
+			return true
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		queue = append(queue, dga.graph[id]...)
+	}
+	return false
+}
+
+// sortedEdges 返回按ID排序的边，并行边保持添加顺序，调用方需持有读锁
+func (dga *DGAGraph) sortedEdges() []Edge {
+	var edges []Edge
+	for _, targets := range dga.edgeMap {
+		for _, parallel := range targets {
+			edges = append(edges, parallel...)
+		}
+	}
+	sort.SliceStable(edges, func(i, j int) bool { return edges[i].ID() < edges[j].ID() })
+	return edges
+}
+
+// path 广度优先查找从 from 到 to 的路径（包括两端），不可达时返回nil，调用方需持有锁
+func (dga *DGAGraph) path(from, to string) []string {
+	prev := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == to {
+			var path []string
+			for p := id; p != from; p = prev[p] {
+				path = append(path, p)
+			}
+			path = append(path, from)
+			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+				path[i], path[j] = path[j], path[i]
+			}
+			return path
+		}
+		for _, next := range dga.graph[id] {
+			if _, ok := prev[next]; !ok {
+				prev[next] = id
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+// findCycle 深度优先查找一个环，调用方需持有锁
+func (dga *DGAGraph) findCycle() []string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(dga.nodes))
+	var stack []string
+
+	var visit func(id string) []string
+	visit = func(id string) []string {
+		state[id] = visiting
+		stack = append(stack, id)
+		next := append([]string(nil), dga.graph[id]...)
+		sort.Strings(next)
+		for _, n := range next {
+			switch state[n] {
+			case visiting:
+				for i := len(stack) - 1; i >= 0; i-- {
+					if stack[i] == n {
+						return append(append([]string(nil), stack[i:]...), n)
+					}
+				}
+			case unvisited:
+				if cycle := visit(n); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[id] = done
+		return nil
+	}
+
+	ids := make([]string, 0, len(dga.nodes))
+	for id := range dga.nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if state[id] == unvisited {
+			if cycle := visit(id); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,429 @@
+package pipelinex
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// graphStatusStyles 导出图时各节点状态的颜色，未列出的状态不着色
+var graphStatusStyles = []struct {
+	Status string
+	Fill   string
+	Stroke string
+}{
+	{Status: StatusRunning, Fill: "#bbdefb", Stroke: "#1e88e5"},
+	{Status: StatusSuccess, Fill: "#c8e6c9", Stroke: "#43a047"},
+	{Status: StatusFailed, Fill: "#ffcdd2", Stroke: "#e53935"},
+	{Status: StatusCancelled, Fill: "#eeeeee", Stroke: "#9e9e9e"},
+	{Status: StatusTerminate, Fill: "#eeeeee", Stroke: "#616161"},
+	{Status: StatusPaused, Fill: "#ffe0b2", Stroke: "#fb8c00"},
+}
+
+// GraphDocument 图的 JSON 文档，节点和边按ID排序
+type GraphDocument struct {
+	Nodes  []GraphDocumentNode `json:"nodes"`
+	Edges  []GraphDocumentEdge `json:"edges"`
+	Groups []NodeGroup         `json:"groups,omitempty"`
+}
+
+// GraphDocumentNode JSON 文档中的节点
+type GraphDocumentNode struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Entry    bool   `json:"entry,omitempty"`
+	Terminal bool   `json:"terminal,omitempty"`
+}
+
+// GraphDocumentEdge JSON 文档中的边
+// 提供求值上下文时条件边带有求值结果 Result，求值失败时为 Error
+type GraphDocumentEdge struct {
+	ID         string `json:"id"`
+	Source     string `json:"source"`
+	Target     string `json:"target"`
+	Expression string `json:"expression,omitempty"`
+	Result     *bool  `json:"result,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// NewGraphDocument 生成图的 JSON 文档，evalCtx 为 nil 时不对条件边求值
+func NewGraphDocument(graph GraphReader, evalCtx EvaluationContext) GraphDocument {
+	entries := toSet(graph.Entries())
+	terminals := toSet(graph.Terminals())
+
+	doc := GraphDocument{Nodes: []GraphDocumentNode{}, Edges: []GraphDocumentEdge{}}
+	for _, node := range sortedNodes(graph) {
+		doc.Nodes = append(doc.Nodes, GraphDocumentNode{
+			ID:       node.Id(),
+			Status:   node.Status(),
+			Entry:    entries[node.Id()],
+			Terminal: terminals[node.Id()],
+		})
+	}
+	for _, edge := range sortedEdges(graph) {
+		item := GraphDocumentEdge{
+			ID:         edge.ID(),
+			Source:     edge.Source().Id(),
+			Target:     edge.Target().Id(),
+			Expression: edge.Expression(),
+		}
+		if evalCtx != nil && item.Expression != "" {
+			result, err := edge.Evaluate(evalCtx)
+			if err != nil {
+				item.Error = err.Error()
+			} else {
+				item.Result = &result
+			}
+		}
+		doc.Edges = append(doc.Edges, item)
+	}
+
+	groups := graph.Groups()
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		doc.Groups = append(doc.Groups, groups[name])
+	}
+	return doc
+}
+
+// ExportJSON 把图导出为 JSON 文档，包含节点状态、边的条件表达式和求值结果
+func ExportJSON(graph GraphReader, evalCtx EvaluationContext) ([]byte, error) {
+	data, err := json.Marshal(NewGraphDocument(graph, evalCtx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal graph: %w", err)
+	}
+	return data, nil
+}
+
+// ExportMermaid 把图导出为 Mermaid 状态图，节点按状态使用 classDef 着色
+// 条件边的表达式作为转换标签，入口和终止节点与 [*] 相连
+// 节点组导出为复合状态，组内的边写在两端共同所在的最内层组中，组的入口和出口与组内的 [*] 相连；
+// 组外的节点以相同条件连接到组的每个入口时，这些边写为到复合状态的转换。
+// 没有入边的复合状态重新解析时视为从 [*] 进入，因此组没有可以写为转换的入边、入口又不是图的入口时，不导出组的入口
+func ExportMermaid(graph GraphReader) string {
+	var b strings.Builder
+	b.WriteString("stateDiagram-v2\n")
+	for _, style := range graphStatusStyles {
+		fmt.Fprintf(&b, "    classDef %s fill:%s,stroke:%s\n", statusClass(style.Status), style.Fill, style.Stroke)
+	}
+
+	nodes := sortedNodes(graph)
+	edges := sortedEdges(graph)
+	groups := newMermaidGroups(graph.Groups())
+	linked := make(map[string]bool)
+	lines := make(map[string][]string) // 按所在复合状态分组的转换，顶层为空
+	transition := func(scope, from, to, label string) {
+		linked[from], linked[to] = true, true
+		line := from + " --> " + to
+		if label != "" {
+			line += ": " + label
+		}
+		lines[scope] = append(lines[scope], line)
+	}
+
+	for _, id := range graph.Entries() {
+		transition("", graphTerminal, id, "")
+	}
+	written := groups.transitions(edges, toSet(graph.Entries()), transition)
+	for i, edge := range edges {
+		if written[i] {
+			continue
+		}
+		source, target := edge.Source().Id(), edge.Target().Id()
+		transition(groups.enclosing(groups.owner[source], target), source, target, mermaidLabel(edge))
+	}
+	for _, id := range graph.Terminals() {
+		transition("", id, graphTerminal, "")
+	}
+	for _, line := range lines[""] {
+		fmt.Fprintf(&b, "    %s\n", line)
+	}
+	groups.write(&b, "", lines, 1)
+
+	for _, node := range nodes {
+		if !linked[node.Id()] {
+			fmt.Fprintf(&b, "    state %s\n", node.Id())
+		}
+	}
+	for _, node := range nodes {
+		if statusStyled(node.Status()) {
+			fmt.Fprintf(&b, "    class %s %s\n", node.Id(), statusClass(node.Status()))
+		}
+	}
+	return b.String()
+}
+
+// mermaidGroups 导出 Mermaid 时的节点组，按嵌套深度和名称排序
+type mermaidGroups struct {
+	groups  map[string]NodeGroup
+	names   []string
+	members map[string]map[string]bool
+	owner   map[string]string // 节点所在的最内层组
+}
+
+// newMermaidGroups 计算每个节点所在的最内层组
+func newMermaidGroups(groups map[string]NodeGroup) *mermaidGroups {
+	m := &mermaidGroups{
+		groups:  groups,
+		members: make(map[string]map[string]bool, len(groups)),
+		owner:   make(map[string]string),
+	}
+	depth := make(map[string]int, len(groups))
+	for name, group := range groups {
+		m.names = append(m.names, name)
+		m.members[name] = toSet(group.Nodes)
+		for parent := group.Parent; parent != ""; parent = groups[parent].Parent {
+			depth[name]++
+		}
+	}
+	sort.Slice(m.names, func(i, j int) bool {
+		if depth[m.names[i]] != depth[m.names[j]] {
+			return depth[m.names[i]] < depth[m.names[j]]
+		}
+		return m.names[i] < m.names[j]
+	})
+	for _, name := range m.names {
+		for _, node := range groups[name].Nodes {
+			if current, ok := m.owner[node]; !ok || depth[name] > depth[current] {
+				m.owner[node] = name
+			}
+		}
+	}
+	return m
+}
+
+// enclosing 从组 name 向外查找包含节点的组，找不到时返回空（顶层）
+func (m *mermaidGroups) enclosing(name, node string) string {
+	for ; name != ""; name = m.groups[name].Parent {
+		if m.members[name][node] {
+			return name
+		}
+	}
+	return ""
+}
+
+// transitions 写出组的出口、组内的 [*] 和到复合状态的转换，返回已经写为到复合状态转换的边
+// 入口都是外层组入口的组由外层组内的 [*] 进入，否则尝试把组外到入口的边写为到复合状态的转换
+func (m *mermaidGroups) transitions(edges []Edge, entries map[string]bool, transition func(scope, from, to, label string)) map[int]bool {
+	written := make(map[int]bool)
+	entered := make(map[string]bool) // 组内写出了 [*] 转换的组
+	for _, name := range m.names {
+		group := m.groups[name]
+		for _, id := range group.Exits {
+			transition(name, id, graphTerminal, "")
+		}
+		if len(group.Entries) == 0 {
+			continue
+		}
+
+		covered := group.Parent != "" && entered[group.Parent] && m.coveredBy(name, group.Parent)
+		if !covered && !m.enter(name, edges, written, transition) && !allIn(group.Entries, entries) {
+			continue
+		}
+		entered[name] = true
+		if covered {
+			transition(group.Parent, graphTerminal, name, "")
+		}
+		children := make(map[string]bool)
+		for _, child := range m.names {
+			if m.groups[child].Parent == name && len(m.groups[child].Entries) > 0 && m.coveredBy(child, name) {
+				for _, id := range m.groups[child].Entries {
+					children[id] = true
+				}
+			}
+		}
+		for _, id := range group.Entries {
+			if !children[id] {
+				transition(name, graphTerminal, id, "")
+			}
+		}
+	}
+	return written
+}
+
+// coveredBy 判断组的入口是否都是外层组 parent 的入口
+func (m *mermaidGroups) coveredBy(name, parent string) bool {
+	return allIn(m.groups[name].Entries, toSet(m.groups[parent].Entries))
+}
+
+// enter 把组外节点到组内每个入口条件相同的边写为到复合状态的转换，返回是否写出了转换
+func (m *mermaidGroups) enter(name string, edges []Edge, written map[int]bool, transition func(scope, from, to, label string)) bool {
+	group := m.groups[name]
+	targets := toSet(group.Entries)
+	labels := make(map[string]map[string][]string) // 源节点到每个入口的边的标签
+	indexes := make(map[string][]int)
+	for i, edge := range edges {
+		source, target := edge.Source().Id(), edge.Target().Id()
+		if written[i] || !targets[target] || m.members[name][source] {
+			continue
+		}
+		if labels[source] == nil {
+			labels[source] = make(map[string][]string)
+		}
+		labels[source][target] = append(labels[source][target], mermaidLabel(edge))
+		indexes[source] = append(indexes[source], i)
+	}
+
+	sources := make([]string, 0, len(labels))
+	for source := range labels {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	entered := false
+	for _, source := range sources {
+		if len(labels[source]) != len(targets) {
+			continue
+		}
+		expected := sortedCopy(labels[source][group.Entries[0]])
+		same := true
+		for _, id := range group.Entries[1:] {
+			same = same && slices.Equal(sortedCopy(labels[source][id]), expected)
+		}
+		if !same {
+			continue
+		}
+		for _, label := range expected {
+			transition(m.enclosing(group.Parent, source), source, name, label)
+		}
+		for _, i := range indexes[source] {
+			written[i] = true
+		}
+		entered = true
+	}
+	return entered
+}
+
+// write 按嵌套关系写出 parent 的子组对应的复合状态
+func (m *mermaidGroups) write(b *strings.Builder, parent string, lines map[string][]string, level int) {
+	indent := strings.Repeat("    ", level)
+	for _, name := range m.names {
+		if m.groups[name].Parent != parent {
+			continue
+		}
+		fmt.Fprintf(b, "%sstate %s {\n", indent, name)
+		for _, line := range lines[name] {
+			fmt.Fprintf(b, "%s    %s\n", indent, line)
+		}
+		m.write(b, name, lines, level+1)
+		fmt.Fprintf(b, "%s}\n", indent)
+	}
+}
+
+// mermaidLabel 返回边在 Mermaid 中的转换标签，表达式中的空白合并为一个空格
+func mermaidLabel(edge Edge) string {
+	return strings.Join(strings.Fields(edge.Expression()), " ")
+}
+
+// ExportDOT 把图导出为 Graphviz DOT，节点按状态着色
+// 提供求值上下文时，条件不满足的边画为虚线，求值失败的边为红色
+func ExportDOT(graph GraphReader, evalCtx EvaluationContext) string {
+	fills := make(map[string]string, len(graphStatusStyles))
+	for _, style := range graphStatusStyles {
+		fills[style.Status] = style.Fill
+	}
+
+	var b strings.Builder
+	b.WriteString("digraph pipeline {\n")
+	b.WriteString("    rankdir=LR;\n")
+	b.WriteString("    node [shape=box, style=\"rounded,filled\", fillcolor=\"#ffffff\"];\n")
+	for _, node := range sortedNodes(graph) {
+		attrs := []string{"label=" + dotQuote(node.Id()+"\n"+node.Status())}
+		if fill, ok := fills[node.Status()]; ok {
+			attrs = append(attrs, "fillcolor="+dotQuote(fill))
+		}
+		fmt.Fprintf(&b, "    %s [%s];\n", dotQuote(node.Id()), strings.Join(attrs, ", "))
+	}
+	for _, edge := range sortedEdges(graph) {
+		var attrs []string
+		if expression := edge.Expression(); expression != "" {
+			attrs = append(attrs, "label="+dotQuote(expression))
+			if evalCtx != nil {
+				switch result, err := edge.Evaluate(evalCtx); {
+				case err != nil:
+					attrs = append(attrs, "color=\"#e53935\"")
+				case !result:
+					attrs = append(attrs, "style=dashed")
+				}
+			}
+		}
+		fmt.Fprintf(&b, "    %s -> %s", dotQuote(edge.Source().Id()), dotQuote(edge.Target().Id()))
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, " [%s]", strings.Join(attrs, ", "))
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// statusClass 返回节点状态对应的 Mermaid class 名称
+func statusClass(status string) string {
+	return strings.ToLower(status)
+}
+
+// statusStyled 判断节点状态是否有对应的颜色
+func statusStyled(status string) bool {
+	for _, style := range graphStatusStyles {
+		if style.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// dotQuote 返回 DOT 中带引号的字符串
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+// sortedNodes 返回按ID排序的节点
+func sortedNodes(graph GraphReader) []Node {
+	nodes := make([]Node, 0)
+	for _, node := range graph.Nodes() {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Id() < nodes[j].Id() })
+	return nodes
+}
+
+// sortedEdges 返回按ID排序的边，并行边保持 Edges() 中的顺序
+func sortedEdges(graph GraphReader) []Edge {
+	edges := graph.Edges()
+	sort.SliceStable(edges, func(i, j int) bool { return edges[i].ID() < edges[j].ID() })
+	return edges
+}
+
+// toSet 把字符串列表转换为集合
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// allIn 判断列表中的值是否都在集合中
+func allIn(values []string, set map[string]bool) bool {
+	for _, v := range values {
+		if !set[v] {
+			return false
+		}
+	}
+	return true
+}
+
+// sortedCopy 返回排序后的副本
+func sortedCopy(values []string) []string {
+	values = slices.Clone(values)
+	sort.Strings(values)
+	return values
+}
@@ -0,0 +1,268 @@
+package pipelinex
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Mermaid flowchart 语法
+// mermaid-check 的 flowchart 解析器不支持 A --> B --> C 链式边和 A & B --> C，这里按行自行解析
+var (
+	flowchartHeaderPattern = regexp.MustCompile(`^(flowchart|graph)(\s+(TB|TD|BT|RL|LR))?\s*;?\s*$`)
+	flowchartIgnorePattern = regexp.MustCompile(`^(classDef|class|style|linkStyle|click|direction)\s`)
+	flowchartSubgraph      = regexp.MustCompile(`^subgraph(\s|$)`)
+	flowchartIDPattern     = regexp.MustCompile(`^[A-Za-z0-9_]+`)
+	flowchartClassPattern  = regexp.MustCompile(`^:::[A-Za-z0-9_-]+`)
+
+	// 有向边：A --> B、A ==> B、A -.-> B，可带 |标签|
+	flowchartArrowPattern = regexp.MustCompile(`^(<?)(-{2,}>|={2,}>|-\.+->)`)
+	// 文本标签：A -- 标签 --> B、A == 标签 ==> B、A -. 标签 .-> B
+	flowchartTextPattern = regexp.MustCompile(`^(--|==|-\.)\s+(.+?)\s+(-{2,}>|={2,}>|\.+->)`)
+	// 无方向的连线：A --- B、A === B、A -.- B
+	flowchartLinePattern = regexp.MustCompile(`^(-{3,}|={3,}|-\.+-)`)
+)
+
+// flowchartShapeClose 节点形状的起始括号对应的结束括号
+var flowchartShapeClose = map[byte]byte{'[': ']', '(': ')', '{': '}', '>': ']'}
+
+// parseFlowchartGraph 解析 flowchart / graph 语法
+// 支持 A --> B、A -->|标签| B、A -- 标签 --> B、链式边 A --> B --> C 和 A & B --> C，
+// 边标签中的 {{ }} 与状态图一样作为条件表达式；subgraph 只用于分组显示，不影响依赖关系
+func parseFlowchartGraph(lines []string) (*graphDefinition, error) {
+	if !flowchartHeaderPattern.MatchString(strings.TrimSpace(lines[0])) {
+		return nil, &GraphError{
+			Line:   1,
+			Column: indentColumn(lines[0]),
+			Err:    fmt.Errorf("%w: invalid flowchart header %q, expected \"flowchart LR\" or \"graph TD\"", ErrGraphSyntax, strings.TrimSpace(lines[0])),
+		}
+	}
+
+	def := &graphDefinition{}
+	var subgraphs []int // 未关闭的 subgraph 所在的行
+	for i := 1; i < len(lines); i++ {
+		line := i + 1
+		trimmed := strings.TrimSpace(lines[i])
+		trimmed = strings.TrimSpace(strings.TrimSuffix(trimmed, ";"))
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "%%"):
+		case flowchartSubgraph.MatchString(trimmed):
+			subgraphs = append(subgraphs, line)
+		case trimmed == "end":
+			if len(subgraphs) == 0 {
+				def.errs = append(def.errs, &GraphError{
+					Line:   line,
+					Column: indentColumn(lines[i]),
+					Err:    fmt.Errorf("%w: \"end\" without matching \"subgraph\"", ErrGraphSyntax),
+				})
+				continue
+			}
+			subgraphs = subgraphs[:len(subgraphs)-1]
+		case flowchartIgnorePattern.MatchString(trimmed):
+		default:
+			parser := &flowchartLineParser{text: lines[i], pos: indentColumn(lines[i]) - 1, line: line}
+			if err := parser.parse(def); err != nil {
+				def.errs = append(def.errs, err)
+			}
+		}
+	}
+	for _, line := range subgraphs {
+		def.errs = append(def.errs, &GraphError{
+			Line:   line,
+			Column: indentColumn(lines[line-1]),
+			Err:    fmt.Errorf("%w: subgraph is not closed with \"end\"", ErrGraphSyntax),
+		})
+	}
+	return def, nil
+}
+
+// flowchartLineParser 解析 flowchart 中的一行语句
+type flowchartLineParser struct {
+	text string
+	pos  int
+	line int
+}
+
+// parse 解析节点和边，结果追加到 def 中
+// 语句形如 nodes (link nodes)*，nodes 为以 & 分隔的一个或多个节点
+func (p *flowchartLineParser) parse(def *graphDefinition) *GraphError {
+	from, err := p.nodes()
+	if err != nil {
+		return err
+	}
+	if p.done() {
+		// 单独的节点定义，例如 A[构建]
+		def.mentions = append(def.mentions, from...)
+		return nil
+	}
+
+	for !p.done() {
+		label, labelColumn, err := p.link()
+		if err != nil {
+			return err
+		}
+		to, err := p.nodes()
+		if err != nil {
+			return err
+		}
+		for _, src := range from {
+			for _, dest := range to {
+				def.transitions = append(def.transitions, graphTransition{
+					From:        src,
+					To:          dest,
+					Label:       label,
+					LabelColumn: labelColumn,
+					Line:        p.line,
+				})
+			}
+		}
+		from = to
+	}
+	return nil
+}
+
+// nodes 解析以 & 分隔的节点列表
+func (p *flowchartLineParser) nodes() ([]graphNodeRef, *GraphError) {
+	var refs []graphNodeRef
+	for {
+		ref, err := p.node()
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+		if p.done() || p.text[p.pos] != '&' {
+			return refs, nil
+		}
+		p.pos++
+	}
+}
+
+// node 解析节点ID以及可选的形状、标签和 :::class
+func (p *flowchartLineParser) node() (graphNodeRef, *GraphError) {
+	p.skipSpaces()
+	id := flowchartIDPattern.FindString(p.text[p.pos:])
+	if id == "" {
+		return graphNodeRef{}, p.errorf("expected node id")
+	}
+	ref := graphNodeRef{Name: id, Line: p.line, Column: p.pos + 1}
+	p.pos += len(id)
+
+	if p.pos < len(p.text) {
+		if closer, ok := flowchartShapeClose[p.text[p.pos]]; ok {
+			if err := p.shape(closer); err != nil {
+				return graphNodeRef{}, err
+			}
+		}
+	}
+	if class := flowchartClassPattern.FindString(p.text[p.pos:]); class != "" {
+		p.pos += len(class)
+	}
+	return ref, nil
+}
+
+// shape 跳过节点形状，例如 [标签]、((标签))、{"标签"}，括号可以嵌套
+func (p *flowchartLineParser) shape(closer byte) *GraphError {
+	start := p.pos
+	opener := p.text[p.pos]
+	depth := 0
+	var quote byte
+	for ; p.pos < len(p.text); p.pos++ {
+		c := p.text[p.pos]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"':
+			quote = c
+		case c == opener && opener != '>':
+			depth++
+		case c == closer:
+			depth--
+			if depth <= 0 {
+				p.pos++
+				return nil
+			}
+		}
+	}
+	p.pos = start
+	return p.errorf("node shape is not closed")
+}
+
+// link 解析一条连线，返回标签和标签所在的列号
+func (p *flowchartLineParser) link() (string, int, *GraphError) {
+	p.skipSpaces()
+	rest := p.text[p.pos:]
+	if m := flowchartArrowPattern.FindStringSubmatchIndex(rest); m != nil {
+		if m[3] > m[2] {
+			return "", 0, p.errorf("bidirectional links are not supported")
+		}
+		p.pos += m[1]
+		p.skipSpaces()
+		if p.pos >= len(p.text) || p.text[p.pos] != '|' {
+			return "", 0, nil
+		}
+		return p.pipeLabel()
+	}
+	if m := flowchartTextPattern.FindStringSubmatchIndex(rest); m != nil {
+		label := rest[m[4]:m[5]]
+		column := p.pos + m[4] + 1
+		p.pos += m[1]
+		return trimFlowchartLabel(label), column, nil
+	}
+	if flowchartLinePattern.MatchString(rest) {
+		return "", 0, p.errorf("link without arrow, use \"-->\" to declare a dependency")
+	}
+	return "", 0, p.errorf("expected link such as \"-->\"")
+}
+
+// pipeLabel 解析 |标签|，模板标记 {{ }} 和 {% %} 中的 | 是过滤器，不结束标签
+func (p *flowchartLineParser) pipeLabel() (string, int, *GraphError) {
+	start := p.pos
+	for i := start + 1; i < len(p.text); i++ {
+		switch {
+		case strings.HasPrefix(p.text[i:], "{{"), strings.HasPrefix(p.text[i:], "{%"):
+			end := strings.Index(p.text[i+2:], map[byte]string{'{': "}}", '%': "%}"}[p.text[i+1]])
+			if end < 0 {
+				return "", 0, p.errorf("template tag in link label is not closed")
+			}
+			i += end + 3
+		case p.text[i] == '|':
+			label := p.text[start+1 : i]
+			p.pos = i + 1
+			return trimFlowchartLabel(label), start + 1 + indentColumn(label), nil
+		}
+	}
+	return "", 0, p.errorf("link label is not closed with \"|\"")
+}
+
+// trimFlowchartLabel 去掉标签两端的空白和引号
+func trimFlowchartLabel(label string) string {
+	label = strings.TrimSpace(label)
+	if len(label) >= 2 && label[0] == '"' && label[len(label)-1] == '"' {
+		label = label[1 : len(label)-1]
+	}
+	return label
+}
+
+func (p *flowchartLineParser) skipSpaces() {
+	for p.pos < len(p.text) && (p.text[p.pos] == ' ' || p.text[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *flowchartLineParser) done() bool {
+	p.skipSpaces()
+	rest := strings.TrimSpace(p.text[p.pos:])
+	return rest == "" || rest == ";"
+}
+
+// errorf 返回当前位置的语法错误
+func (p *flowchartLineParser) errorf(format string, args ...any) *GraphError {
+	rest := strings.TrimSpace(p.text[p.pos:])
+	return &GraphError{
+		Line:   p.line,
+		Column: p.pos + 1,
+		Err:    fmt.Errorf("%w: %s at %q", ErrGraphSyntax, fmt.Sprintf(format, args...), rest),
+	}
+}
@@ -0,0 +1,375 @@
+package pipelinex
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 复合状态展开后的伪状态类型
+const (
+	pseudoComposite    = "composite"     // 复合状态的入口，组内 [*] --> X 从这里出发
+	pseudoCompositeEnd = "composite-end" // 复合状态的出口，组内 X --> [*] 到达这里
+)
+
+// compositeEndSuffix 复合状态出口伪状态的名称后缀，不是合法的节点名，不会与节点冲突
+const compositeEndSuffix = "/" + graphTerminal
+
+// graphResolver 检查转换引用的节点、编译标签中的条件，并展开伪状态
+type graphResolver struct {
+	def     *graphDefinition
+	nodeMap map[string]Node
+	engine  TemplateEngine
+
+	// 复合状态展开为入口和出口两个伪状态后的转换和伪状态
+	transitions []graphTransition
+	pseudo      map[string]graphPseudoState
+
+	valid    []bool
+	isElse   []bool
+	guards   [][]EdgeCondition
+	outgoing map[string][]int // 伪状态的出边，保持声明顺序
+	incoming map[string][]int
+	errs     []*GraphError
+}
+
+// resolveGraphEdges 展开伪状态和复合状态，返回节点（或 [*]）之间的边和节点组
+// 经过伪状态的每条路径合并为一条边，条件为路径上各段条件的合取：
+// fork 把入边连接到每个出边的目标，join 的目标等待所有入边（与普通节点的多个入边相同），
+// choice 按声明顺序选择第一个条件满足的分支，else 分支在其他分支都不满足时通过；
+// 复合状态的入边连接到组内 [*] 的后继，出边从组内转换到 [*] 的节点出发
+func resolveGraphEdges(def *graphDefinition, nodeMap map[string]Node, engine TemplateEngine) ([]graphEdge, []NodeGroup, []*GraphError) {
+	r := &graphResolver{def: def, nodeMap: nodeMap, engine: engine}
+	r.expandComposites()
+	for _, ref := range def.mentions {
+		r.known(ref)
+	}
+	r.compile()
+	r.checkPseudo()
+	edges := r.edges()
+	groups := r.groups()
+	return edges, groups, r.errs
+}
+
+// expandComposites 把复合状态 G 展开为入口伪状态 G 和出口伪状态 G/[*]
+// 组内的 [*] --> X 变为 G --> X，X --> [*] 变为 X --> G/[*]，组外的 G --> Y 变为 G/[*] --> Y
+func (r *graphResolver) expandComposites() {
+	r.pseudo = make(map[string]graphPseudoState, len(r.def.pseudo)+2*len(r.def.groups))
+	for name, state := range r.def.pseudo {
+		r.pseudo[name] = state
+	}
+	for name, group := range r.def.groups {
+		r.pseudo[name] = graphPseudoState{Kind: pseudoComposite, Ref: group.Ref}
+		r.pseudo[name+compositeEndSuffix] = graphPseudoState{Kind: pseudoCompositeEnd, Ref: group.Ref}
+	}
+
+	r.transitions = make([]graphTransition, len(r.def.transitions))
+	for i, t := range r.def.transitions {
+		if _, ok := r.def.groups[t.From.Name]; ok {
+			t.From.Name += compositeEndSuffix
+		}
+		if t.Scope != "" {
+			if t.From.Name == graphTerminal {
+				t.From.Name = t.Scope
+			}
+			if t.To.Name == graphTerminal {
+				t.To.Name = t.Scope + compositeEndSuffix
+			}
+		}
+		r.transitions[i] = t
+	}
+}
+
+// known 检查引用的名称是节点、伪状态或 [*]，否则记录错误
+func (r *graphResolver) known(ref graphNodeRef) bool {
+	if _, ok := r.nodeMap[ref.Name]; ok || ref.Name == graphTerminal {
+		return true
+	}
+	if _, ok := r.pseudo[ref.Name]; ok {
+		return true
+	}
+	r.errs = append(r.errs, &GraphError{Line: ref.Line, Column: ref.Column, Err: fmt.Errorf("%w %q", ErrUnknownNode, ref.Name)})
+	return false
+}
+
+// compile 编译每个转换的条件，记录伪状态的出边和入边
+func (r *graphResolver) compile() {
+	n := len(r.transitions)
+	r.valid = make([]bool, n)
+	r.isElse = make([]bool, n)
+	r.guards = make([][]EdgeCondition, n)
+	r.outgoing = make(map[string][]int)
+	r.incoming = make(map[string][]int)
+
+	for i, t := range r.transitions {
+		if !r.known(t.From) || !r.known(t.To) {
+			continue
+		}
+		if state, ok := r.pseudo[t.From.Name]; ok && state.Kind == pseudoChoice && strings.TrimSpace(t.Label) == choiceElse {
+			r.isElse[i] = true
+		} else {
+			// 从 Label 中提取条件表达式
+			expression, err := extractExpression(r.engine, t.Label)
+			if err != nil {
+				r.errs = append(r.errs, &GraphError{
+					Line:   t.Line,
+					Column: t.LabelColumn,
+					Err:    fmt.Errorf("invalid condition on edge %s->%s: %w", displayName(t.From.Name), displayName(t.To.Name), err),
+				})
+				continue
+			}
+			if expression != "" {
+				r.guards[i] = []EdgeCondition{{Expression: expression}}
+			}
+		}
+		r.valid[i] = true
+		r.outgoing[t.From.Name] = append(r.outgoing[t.From.Name], i)
+		r.incoming[t.To.Name] = append(r.incoming[t.To.Name], i)
+	}
+}
+
+// checkPseudo 检查伪状态的名称冲突和出入边，并计算 choice 各分支的条件
+func (r *graphResolver) checkPseudo() {
+	names := make([]string, 0, len(r.pseudo))
+	for name := range r.pseudo {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := r.pseudo[names[i]].Ref, r.pseudo[names[j]].Ref
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return names[i] < names[j]
+	})
+
+	for _, name := range names {
+		state := r.pseudo[name]
+		if state.Kind == pseudoCompositeEnd {
+			continue
+		}
+		if _, ok := r.nodeMap[name]; ok {
+			r.errs = append(r.errs, &GraphError{
+				Line:   state.Ref.Line,
+				Column: state.Ref.Column,
+				Err:    fmt.Errorf("%w: %s state %q conflicts with node of the same name", ErrInvalidGraph, state.Kind, name),
+			})
+		}
+		// 复合状态可以作为图的起点，出口也可以没有后继
+		if state.Kind == pseudoComposite {
+			continue
+		}
+		if len(r.incoming[name]) == 0 || len(r.outgoing[name]) == 0 {
+			direction := "incoming"
+			if len(r.incoming[name]) > 0 {
+				direction = "outgoing"
+			}
+			r.errs = append(r.errs, &GraphError{
+				Line:   state.Ref.Line,
+				Column: state.Ref.Column,
+				Err:    fmt.Errorf("%w: %s state %q has no %s transition", ErrInvalidGraph, state.Kind, name, direction),
+			})
+		}
+		if state.Kind == pseudoChoice {
+			r.choiceGuards(name)
+		}
+	}
+}
+
+// choiceGuards 计算 choice 各分支的条件：之前的分支都不满足且自身条件满足
+// 除 else 外的分支必须有条件表达式，else 最多一个
+func (r *graphResolver) choiceGuards(name string) {
+	var previous []EdgeCondition
+	elseBranch := -1
+	for _, i := range r.outgoing[name] {
+		t := r.transitions[i]
+		switch {
+		case r.isElse[i] && elseBranch >= 0:
+			r.valid[i] = false
+			r.errs = append(r.errs, &GraphError{
+				Line:   t.Line,
+				Column: t.LabelColumn,
+				Err:    fmt.Errorf("%w: choice state %q has more than one else branch", ErrInvalidGraph, name),
+			})
+		case r.isElse[i]:
+			elseBranch = i
+		case len(r.guards[i]) == 0:
+			r.valid[i] = false
+			r.errs = append(r.errs, &GraphError{
+				Line:   t.Line,
+				Column: t.From.Column,
+				Err:    fmt.Errorf("%w: choice branch %s->%s requires a condition or else", ErrInvalidGraph, name, displayName(t.To.Name)),
+			})
+		default:
+			condition := r.guards[i][0]
+			r.guards[i] = append(append([]EdgeCondition(nil), previous...), condition)
+			previous = append(previous, EdgeCondition{Expression: condition.Expression, Negate: true})
+		}
+	}
+	if elseBranch >= 0 {
+		r.guards[elseBranch] = previous
+	}
+}
+
+// edges 从节点或 [*] 出发，沿伪状态展开到下一个节点或 [*]
+// 没有入边的复合状态视为从 [*] 进入
+func (r *graphResolver) edges() []graphEdge {
+	var edges []graphEdge
+	cycles := make(map[string]bool)
+
+	var walk func(from graphNodeRef, i int, conditions []EdgeCondition, visiting map[string]bool)
+	walk = func(from graphNodeRef, i int, conditions []EdgeCondition, visiting map[string]bool) {
+		t := r.transitions[i]
+		conditions = append(append([]EdgeCondition(nil), conditions...), r.guards[i]...)
+		if _, ok := r.pseudo[t.To.Name]; !ok {
+			edges = append(edges, graphEdge{From: from, To: t.To, Conditions: conditions})
+			return
+		}
+		if visiting[t.To.Name] {
+			if !cycles[t.To.Name] {
+				cycles[t.To.Name] = true
+				r.errs = append(r.errs, &GraphError{
+					Line:   t.Line,
+					Column: t.From.Column,
+					Err:    fmt.Errorf("edge %s->%s: %w", displayName(t.From.Name), displayName(t.To.Name), ErrHasCycle),
+				})
+			}
+			return
+		}
+		visiting[t.To.Name] = true
+		for _, next := range r.outgoing[t.To.Name] {
+			if r.valid[next] {
+				walk(from, next, conditions, visiting)
+			}
+		}
+		delete(visiting, t.To.Name)
+	}
+
+	for i, t := range r.transitions {
+		if _, ok := r.pseudo[t.From.Name]; ok || !r.valid[i] {
+			continue
+		}
+		walk(t.From, i, nil, make(map[string]bool))
+	}
+
+	names := make([]string, 0, len(r.def.groups))
+	for name := range r.def.groups {
+		if len(r.incoming[name]) == 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ref := r.def.groups[name].Ref
+		from := graphNodeRef{Name: graphTerminal, Line: ref.Line, Column: ref.Column}
+		for _, i := range r.outgoing[name] {
+			if r.valid[i] {
+				walk(from, i, nil, map[string]bool{name: true})
+			}
+		}
+	}
+	return edges
+}
+
+// groups 计算每个复合状态包含的节点、入口节点和出口节点
+// 节点属于出现它的最内层复合状态及其外层，同时出现在两个互不嵌套的复合状态中视为错误
+func (r *graphResolver) groups() []NodeGroup {
+	if len(r.def.groups) == 0 {
+		return nil
+	}
+
+	members := make(map[string]map[string]bool, len(r.def.groups))
+	owner := make(map[string]string)
+	for i, t := range r.transitions {
+		if !r.valid[i] || t.Scope == "" {
+			continue
+		}
+		for _, ref := range []graphNodeRef{t.From, t.To} {
+			if _, ok := r.nodeMap[ref.Name]; !ok {
+				continue
+			}
+			switch current, ok := owner[ref.Name]; {
+			case !ok || r.isAncestor(current, t.Scope):
+				owner[ref.Name] = t.Scope
+			case current == t.Scope || r.isAncestor(t.Scope, current):
+			default:
+				r.errs = append(r.errs, &GraphError{
+					Line:   ref.Line,
+					Column: ref.Column,
+					Err:    fmt.Errorf("%w: node %q belongs to composite states %q and %q", ErrInvalidGraph, ref.Name, current, t.Scope),
+				})
+			}
+		}
+	}
+	for node, scope := range owner {
+		for name := scope; name != ""; name = r.def.groups[name].Parent {
+			if members[name] == nil {
+				members[name] = make(map[string]bool)
+			}
+			members[name][node] = true
+		}
+	}
+
+	groups := make([]NodeGroup, 0, len(r.def.groups))
+	for name, group := range r.def.groups {
+		end := name + compositeEndSuffix
+		groups = append(groups, NodeGroup{
+			Name:    name,
+			Parent:  group.Parent,
+			Nodes:   sortedKeys(members[name]),
+			Entries: sortedKeys(r.reach(name, r.outgoing, func(t graphTransition) string { return t.To.Name }, map[string]bool{name: true, end: true})),
+			Exits:   sortedKeys(r.reach(end, r.incoming, func(t graphTransition) string { return t.From.Name }, map[string]bool{name: true, end: true})),
+		})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups
+}
+
+// reach 从伪状态 name 沿 adjacency 经过其他伪状态找到相邻的节点
+func (r *graphResolver) reach(name string, adjacency map[string][]int, next func(graphTransition) string, seen map[string]bool) map[string]bool {
+	nodes := make(map[string]bool)
+	for _, i := range adjacency[name] {
+		if !r.valid[i] {
+			continue
+		}
+		target := next(r.transitions[i])
+		if _, ok := r.nodeMap[target]; ok {
+			nodes[target] = true
+			continue
+		}
+		if _, ok := r.pseudo[target]; ok && !seen[target] {
+			seen[target] = true
+			for node := range r.reach(target, adjacency, next, seen) {
+				nodes[node] = true
+			}
+		}
+	}
+	return nodes
+}
+
+// isAncestor 判断复合状态 ancestor 是否包含 name（不包括自身）
+func (r *graphResolver) isAncestor(ancestor, name string) bool {
+	if ancestor == "" {
+		return name != ""
+	}
+	for parent := r.def.groups[name].Parent; parent != ""; parent = r.def.groups[parent].Parent {
+		if parent == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// displayName 返回错误信息中使用的名称，复合状态的出口显示为复合状态名
+func displayName(name string) string {
+	return strings.TrimSuffix(name, compositeEndSuffix)
+}
+
+// sortedKeys 返回排序后的集合元素
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
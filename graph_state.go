@@ -0,0 +1,244 @@
+package pipelinex
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/tetrafolium/mermaid-check/ast"
+	"github.com/tetrafolium/mermaid-check/parser"
+)
+
+// mermaid-check 不解析、但属于合法 Mermaid 状态图的语句，构建图时忽略
+var (
+	graphDirectionPattern = regexp.MustCompile(`^direction\s+(TB|TD|BT|LR|RL)\s*$`)
+	graphStylePattern     = regexp.MustCompile(`^(classDef|class|style)\s+\S`)
+	graphStateDeclPattern = regexp.MustCompile(`^state\s+\w+\s*$`)
+	graphStateDescPattern = regexp.MustCompile(`^\w+\s*:`)
+	graphNoteBlockPattern = regexp.MustCompile(`^note\s+(left|right)\s+of\s+\w+\s*$`)
+	graphNoteEndPattern   = regexp.MustCompile(`^end\s+note\s*$`)
+)
+
+// 复合状态 state Build { ... }，-- 分隔并发区域
+var (
+	graphCompositeOpenPattern  = regexp.MustCompile(`^state\s+(?:"[^"]*"\s+as\s+)?(\w+)\s*\{\s*$`)
+	graphCompositeClosePattern = regexp.MustCompile(`^\}\s*$`)
+	graphRegionPattern         = regexp.MustCompile(`^--\s*$`)
+)
+
+// parseStateGraph 使用 mermaid-check 库解析 stateDiagram-v2 语法
+// 支持从边标签中解析条件表达式，例如：A --> B: {{ Param.env == "prod" }}
+func parseStateGraph(source string, lines []string) (*graphDefinition, error) {
+	stateParser := parser.NewStateParser()
+	diagram, err := stateParser.Parse(source)
+	if err != nil {
+		return nil, &GraphError{Line: 1, Column: indentColumn(lines[0]), Err: fmt.Errorf("%w: %v", ErrGraphSyntax, err)}
+	}
+
+	// 转换为状态图
+	stateDiagram, ok := diagram.(*ast.StateDiagram)
+	if !ok {
+		return nil, &GraphError{Line: 1, Column: indentColumn(lines[0]), Err: fmt.Errorf("%w: not a state diagram", ErrGraphSyntax)}
+	}
+
+	def := &graphDefinition{
+		pseudo: make(map[string]graphPseudoState),
+		groups: make(map[string]graphGroup),
+	}
+	scopes, parsed := stateScopes(lines, def)
+
+	// 遍历所有语句，提取转换关系
+	for _, stmt := range stateDiagram.Statements {
+		line := stmt.GetPosition().Line
+		parsed[line] = true
+		text := lines[line-1]
+		arrow := strings.Index(text, "-->") + len("-->")
+		ref := func(name string, after int) graphNodeRef {
+			return graphNodeRef{Name: name, Line: line, Column: nameColumn(text, name, after)}
+		}
+
+		switch s := stmt.(type) {
+		case *ast.Fork:
+			def.pseudo[s.ID] = graphPseudoState{Kind: pseudoFork, Ref: ref(s.ID, 0)}
+		case *ast.Join:
+			def.pseudo[s.ID] = graphPseudoState{Kind: pseudoJoin, Ref: ref(s.ID, 0)}
+		case *ast.Choice:
+			def.pseudo[s.ID] = graphPseudoState{Kind: pseudoChoice, Ref: ref(s.ID, 0)}
+		case *ast.StartState:
+			// mermaid-check 不保留 [*] 转换的标签，从原文中提取
+			def.transitions = append(def.transitions, graphTransition{
+				From:        graphNodeRef{Name: graphTerminal, Line: line, Column: indentColumn(text)},
+				To:          ref(s.To, arrow),
+				Label:       stateLabel(text, arrow),
+				LabelColumn: labelColumn(text, arrow),
+				Line:        line,
+				Scope:       scopes[line],
+			})
+		case *ast.EndState:
+			def.transitions = append(def.transitions, graphTransition{
+				From:        ref(s.From, 0),
+				To:          graphNodeRef{Name: graphTerminal, Line: line, Column: arrow + indentColumn(text[arrow:])},
+				Label:       stateLabel(text, arrow),
+				LabelColumn: labelColumn(text, arrow),
+				Line:        line,
+				Scope:       scopes[line],
+			})
+		case *ast.StateNote:
+			def.mentions = append(def.mentions, ref(s.StateID, strings.Index(text, " of ")+len(" of ")))
+		case *ast.Transition:
+			def.transitions = append(def.transitions, graphTransition{
+				From:        ref(s.From, 0),
+				To:          ref(s.To, arrow),
+				Label:       s.Label,
+				LabelColumn: labelColumn(text, arrow),
+				Line:        line,
+				Scope:       scopes[line],
+			})
+		}
+	}
+
+	def.errs = append(def.errs, checkStateLines(lines, parsed)...)
+	return def, nil
+}
+
+// stateScopes 找出复合状态，返回每一行所在的最内层复合状态（顶层为空，按行号索引），
+// 以及复合状态的开始、结束和区域分隔所在的行
+func stateScopes(lines []string, def *graphDefinition) ([]string, map[int]bool) {
+	scopes := make([]string, len(lines)+1)
+	structural := make(map[int]bool)
+	var stack []string
+	for i := 1; i < len(lines); i++ {
+		line := i + 1
+		trimmed := strings.TrimSpace(lines[i])
+		if len(stack) > 0 {
+			scopes[line] = stack[len(stack)-1]
+		}
+
+		if m := graphCompositeOpenPattern.FindStringSubmatch(trimmed); m != nil {
+			structural[line] = true
+			name := m[1]
+			ref := graphNodeRef{Name: name, Line: line, Column: nameColumn(lines[i], name, strings.Index(lines[i], "state")+len("state"))}
+			if _, ok := def.groups[name]; ok {
+				def.errs = append(def.errs, &GraphError{
+					Line:   line,
+					Column: ref.Column,
+					Err:    fmt.Errorf("%w: composite state %q is already defined", ErrGraphSyntax, name),
+				})
+			} else {
+				def.groups[name] = graphGroup{Ref: ref, Parent: scopes[line]}
+			}
+			stack = append(stack, name)
+			continue
+		}
+
+		switch {
+		case graphCompositeClosePattern.MatchString(trimmed):
+			structural[line] = true
+			if len(stack) == 0 {
+				def.errs = append(def.errs, &GraphError{
+					Line:   line,
+					Column: indentColumn(lines[i]),
+					Err:    fmt.Errorf("%w: unexpected \"}\" outside composite state", ErrGraphSyntax),
+				})
+				continue
+			}
+			stack = stack[:len(stack)-1]
+		case graphRegionPattern.MatchString(trimmed) && len(stack) > 0:
+			structural[line] = true
+		}
+	}
+	for _, name := range stack {
+		ref := def.groups[name].Ref
+		def.errs = append(def.errs, &GraphError{
+			Line:   ref.Line,
+			Column: ref.Column,
+			Err:    fmt.Errorf("%w: composite state %q is not closed with \"}\"", ErrGraphSyntax, name),
+		})
+	}
+	return scopes, structural
+}
+
+// checkStateLines 检查 mermaid-check 跳过的行，parsed 为已解析语句所在的行号
+// 合法但不影响图结构的 Mermaid 语句被忽略，其余的行作为语法错误返回
+func checkStateLines(lines []string, parsed map[int]bool) []*GraphError {
+	var errs []*GraphError
+	inNote := false
+	for i := 1; i < len(lines); i++ {
+		line := i + 1
+		trimmed := strings.TrimSpace(lines[i])
+		switch {
+		case inNote:
+			inNote = !graphNoteEndPattern.MatchString(trimmed)
+		case trimmed == "" || parsed[line]:
+		case graphNoteBlockPattern.MatchString(trimmed):
+			inNote = true
+		case graphDirectionPattern.MatchString(trimmed),
+			graphStylePattern.MatchString(trimmed),
+			graphStateDeclPattern.MatchString(trimmed),
+			graphStateDescPattern.MatchString(trimmed):
+		default:
+			errs = append(errs, &GraphError{
+				Line:   line,
+				Column: indentColumn(lines[i]),
+				Err:    stateSyntaxError(trimmed),
+			})
+		}
+	}
+	if inNote {
+		errs = append(errs, &GraphError{
+			Line:   len(lines),
+			Column: indentColumn(lines[len(lines)-1]),
+			Err:    fmt.Errorf("%w: note is not closed with \"end note\"", ErrGraphSyntax),
+		})
+	}
+	return errs
+}
+
+// stateSyntaxError 返回无法解析的语句的错误说明
+func stateSyntaxError(statement string) error {
+	switch {
+	case strings.Contains(statement, "-->"):
+		return fmt.Errorf("%w: invalid transition %q, expected \"A --> B\" or \"A --> B: label\"", ErrGraphSyntax, statement)
+	default:
+		return fmt.Errorf("%w: unexpected statement %q", ErrGraphSyntax, statement)
+	}
+}
+
+// nameColumn 返回节点名在行中偏移 after 之后第一次作为完整单词出现的列号
+func nameColumn(line, name string, after int) int {
+	if after < 0 {
+		after = 0
+	}
+	for offset := after; offset < len(line); {
+		i := strings.Index(line[offset:], name)
+		if i < 0 {
+			break
+		}
+		start := offset + i
+		end := start + len(name)
+		if (start == 0 || !isIdentByte(line[start-1])) && (end == len(line) || !isIdentByte(line[end])) {
+			return start + 1
+		}
+		offset = end
+	}
+	return indentColumn(line)
+}
+
+// stateLabel 返回转换标签，arrow 为 --> 之后的偏移，没有标签时返回空字符串
+func stateLabel(line string, arrow int) string {
+	_, label, ok := strings.Cut(line[arrow:], ":")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(label)
+}
+
+// labelColumn 返回转换标签在行中的列号，arrow 为 --> 之后的偏移
+func labelColumn(line string, arrow int) int {
+	i := strings.Index(line[arrow:], ":")
+	if i < 0 {
+		return indentColumn(line)
+	}
+	start := arrow + i + 1
+	return start + indentColumn(line[start:])
+}
@@ -38,3 +38,16 @@ type Pusher interface {
 	// Close 关闭连接，刷新缓冲
 	Close() error
 }
+
+// LogStream 日志流，记录已推送的日志并支持多订阅者实时跟随
+type LogStream interface {
+	Pusher
+
+	// Subscribe 订阅日志，先回放已推送的日志再持续跟随
+	// node 为空时订阅全部节点，流水线结束时关闭通道
+	// node 不为空时只订阅该节点，节点结束时关闭通道
+	Subscribe(ctx context.Context, node string) (<-chan Entry, error)
+
+	// NodeDone 标记节点已结束，关闭该节点的订阅
+	NodeDone(node string)
+}
@@ -0,0 +1,135 @@
+package pipelinex
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 预检查LogBroker是否实现了LogStream接口
+var _ LogStream = (*LogBroker)(nil)
+
+// LogBroker LogStream接口的内存实现
+// 保存单条流水线已推送的全部日志，并将日志转发给下游Pusher
+type LogBroker struct {
+	buildID   string
+	next      Pusher          // 下游日志推送器，可以为空
+	entries   []Entry         // 已推送的日志
+	nodesDone map[string]bool // 已结束的节点
+	closed    bool
+	notify    chan struct{} // 每次有新日志或状态变化时关闭并重建，用于唤醒订阅者
+	mu        sync.Mutex
+}
+
+// NewLogBroker 创建日志流，buildID 会填充到未设置 BuildID 的日志中
+func NewLogBroker(buildID string, next Pusher) *LogBroker {
+	return &LogBroker{
+		buildID:   buildID,
+		next:      next,
+		entries:   []Entry{},
+		nodesDone: map[string]bool{},
+		notify:    make(chan struct{}),
+	}
+}
+
+// Push 推送单条日志
+func (b *LogBroker) Push(ctx context.Context, entry Entry) error {
+	return b.PushBatch(ctx, []Entry{entry})
+}
+
+// PushBatch 批量推送
+func (b *LogBroker) PushBatch(ctx context.Context, entries []Entry) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return fmt.Errorf("log stream %s is closed", b.buildID)
+	}
+	for i := range entries {
+		if entries[i].BuildID == "" {
+			entries[i].BuildID = b.buildID
+		}
+		if entries[i].Timestamp.IsZero() {
+			entries[i].Timestamp = time.Now()
+		}
+	}
+	b.entries = append(b.entries, entries...)
+	b.broadcast()
+	next := b.next
+	b.mu.Unlock()
+
+	if next != nil {
+		return next.PushBatch(ctx, entries)
+	}
+	return nil
+}
+
+// Close 关闭日志流，所有订阅在回放完剩余日志后结束
+// 下游Pusher由调用方管理，这里不会关闭
+func (b *LogBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		b.broadcast()
+	}
+	return nil
+}
+
+// NodeDone 标记节点已结束
+func (b *LogBroker) NodeDone(node string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nodesDone[node] = true
+	b.broadcast()
+}
+
+// Subscribe 订阅日志
+func (b *LogBroker) Subscribe(ctx context.Context, node string) (<-chan Entry, error) {
+	ch := make(chan Entry, 64)
+	go b.follow(ctx, node, ch)
+	return ch, nil
+}
+
+// follow 从头回放日志并跟随新日志，直到日志流关闭、节点结束或ctx取消
+func (b *LogBroker) follow(ctx context.Context, node string, ch chan<- Entry) {
+	defer close(ch)
+
+	offset := 0
+	for {
+		b.mu.Lock()
+		pending := b.entries[offset:]
+		offset = len(b.entries)
+		finished := b.closed || (node != "" && b.nodesDone[node])
+		notify := b.notify
+		b.mu.Unlock()
+
+		for _, entry := range pending {
+			if node != "" && entry.Node != node {
+				continue
+			}
+			select {
+			case ch <- entry:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		// 结束状态是和日志一起读取的，此时已回放完结束前的全部日志
+		if finished {
+			return
+		}
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// broadcast 唤醒所有等待中的订阅者，调用方需持有锁
+func (b *LogBroker) broadcast() {
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
@@ -1,6 +1,9 @@
 package pipelinex
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // MetadataStore 元数据存储接口
 type MetadataStore interface {
@@ -8,14 +11,42 @@ type MetadataStore interface {
 	Get(ctx context.Context, key string) (string, error)
 	// Set 设置元数据值
 	Set(ctx context.Context, key string, value string) error
+	// SetWithTTL 设置元数据值并指定过期时间，ttl <= 0 表示永不过期
+	SetWithTTL(ctx context.Context, key string, value string, ttl time.Duration) error
 	// Delete 删除元数据
 	Delete(ctx context.Context, key string) error
+	// List 列出指定前缀的所有key（按字典序），前缀为空时列出全部
+	List(ctx context.Context, prefix string) ([]string, error)
+	// GetMany 批量获取元数据值，不存在的key不会出现在结果中
+	GetMany(ctx context.Context, keys []string) (map[string]string, error)
+	// SetMany 批量设置元数据值
+	SetMany(ctx context.Context, values map[string]string) error
+	// Watch 监听key的变化，key存在时会先发送一次当前值，ctx取消后通道关闭
+	Watch(ctx context.Context, key string) (<-chan MetadataEvent, error)
+	// CompareAndSwap 当前值等于old时写入new并返回true，old为空表示key不存在时才写入
+	// 因此无法与已存在的空值比较；写入的值不过期
+	CompareAndSwap(ctx context.Context, key string, old string, new string) (bool, error)
+	// Incr 将key的整数值增加delta并返回新值，key不存在时视为0
+	Incr(ctx context.Context, key string, delta int64) (int64, error)
 	// Close 关闭元数据存储连接
 	Close() error
 }
 
+// MetadataEvent 元数据变化事件
+type MetadataEvent struct {
+	Key     string
+	Value   string
+	Deleted bool // key 被删除或已过期
+}
+
 // MetadataStoreFactory 元数据存储工厂接口，用于创建MetadataStore实例
 type MetadataStoreFactory interface {
 	// Create 根据配置创建MetadataStore实例
 	Create(config MetadataConfig) (MetadataStore, error)
-}
\ No newline at end of file
+}
+
+// MetadataStoreConstructor 根据配置创建某一类型的MetadataStore
+type MetadataStoreConstructor func(config MetadataConfig) (MetadataStore, error)
+
+// MetadataDataValidator 校验某一类型存储的 Metadate.data 配置
+type MetadataDataValidator func(data map[string]interface{}) error
@@ -0,0 +1,305 @@
+package pipelinex
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 预检查DefaultMetadataStoreFactory是否实现了MetadataStoreFactory接口
+var _ MetadataStoreFactory = (*DefaultMetadataStoreFactory)(nil)
+
+// metadataStoreDriver 已注册的存储类型
+type metadataStoreDriver struct {
+	constructor MetadataStoreConstructor
+	validator   MetadataDataValidator
+	noTTL       bool // 不支持过期时间，配置了 Metadate.ttl 时在创建前返回错误
+}
+
+// DefaultMetadataStoreFactory 默认的元数据存储工厂
+// 内置 in-config、memory、file、http、redis 类型，可以通过 Register 注册自定义类型
+// 零值可以直接使用，首次注册或创建时加载内置类型
+type DefaultMetadataStoreFactory struct {
+	mu      sync.RWMutex
+	drivers map[string]metadataStoreDriver
+}
+
+// NewMetadataStoreFactory 创建默认的元数据存储工厂
+func NewMetadataStoreFactory() *DefaultMetadataStoreFactory {
+	return &DefaultMetadataStoreFactory{drivers: builtinMetadataStoreDrivers()}
+}
+
+// builtinMetadataStoreDrivers 返回内置的存储类型
+func builtinMetadataStoreDrivers() map[string]metadataStoreDriver {
+	return map[string]metadataStoreDriver{
+		"in-config": {constructor: func(config MetadataConfig) (MetadataStore, error) {
+			return NewInConfigMetadataStore(config)
+		}, noTTL: true},
+		"memory": {constructor: func(config MetadataConfig) (MetadataStore, error) {
+			return NewMemoryMetadataStore(config)
+		}},
+		"file": {constructor: func(config MetadataConfig) (MetadataStore, error) {
+			return NewFileMetadataStore(config)
+		}, validator: validateFileMetadataData, noTTL: true},
+		"http": {constructor: func(config MetadataConfig) (MetadataStore, error) {
+			return NewHTTPMetadataStore(config)
+		}, validator: validateHTTPMetadataData},
+		"redis": {constructor: func(config MetadataConfig) (MetadataStore, error) {
+			return NewRedisMetadataStore(config)
+		}, validator: validateRedisMetadataData},
+	}
+}
+
+// initLocked 零值工厂首次使用时加载内置类型，调用方需持有写锁
+func (f *DefaultMetadataStoreFactory) initLocked() {
+	if f.drivers == nil {
+		f.drivers = builtinMetadataStoreDrivers()
+	}
+}
+
+// registered 返回已注册的存储类型，零值工厂在此时加载内置类型
+func (f *DefaultMetadataStoreFactory) registered() map[string]metadataStoreDriver {
+	f.mu.RLock()
+	drivers := f.drivers
+	f.mu.RUnlock()
+	if drivers != nil {
+		return drivers
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.initLocked()
+	return f.drivers
+}
+
+// Register 注册存储类型，已存在的类型会被覆盖
+// validator 可以为空，表示不校验 Metadate.data
+func (f *DefaultMetadataStoreFactory) Register(storeType string, constructor MetadataStoreConstructor, validator MetadataDataValidator) {
+	f.register(storeType, metadataStoreDriver{constructor: constructor, validator: validator})
+}
+
+// RegisterWithoutTTL 注册不支持过期时间的存储类型，配置了 Metadate.ttl 时 Validate/Create 返回错误
+func (f *DefaultMetadataStoreFactory) RegisterWithoutTTL(storeType string, constructor MetadataStoreConstructor, validator MetadataDataValidator) {
+	f.register(storeType, metadataStoreDriver{constructor: constructor, validator: validator, noTTL: true})
+}
+
+// register 注册存储类型
+func (f *DefaultMetadataStoreFactory) register(storeType string, driver metadataStoreDriver) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.initLocked()
+	f.drivers[storeType] = driver
+}
+
+// Types 返回已注册的存储类型，按字典序排序
+func (f *DefaultMetadataStoreFactory) Types() []string {
+	drivers := f.registered()
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	types := make([]string, 0, len(drivers))
+	for storeType := range drivers {
+		types = append(types, storeType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Validate 校验存储类型是否已注册、Metadate.ttl 是否受支持以及 Metadate.data 是否合法
+func (f *DefaultMetadataStoreFactory) Validate(config MetadataConfig) error {
+	_, err := f.driver(config)
+	return err
+}
+
+// Create 根据配置类型创建对应的MetadataStore实例
+func (f *DefaultMetadataStoreFactory) Create(config MetadataConfig) (MetadataStore, error) {
+	driver, err := f.driver(config)
+	if err != nil {
+		return nil, err
+	}
+	store, err := driver.constructor(config)
+	if err != nil {
+		return nil, fmt.Errorf("metadata store %q: %w", config.Type, err)
+	}
+	return store, nil
+}
+
+// driver 查找存储类型并校验配置，在创建存储前发现 ttl 与存储类型不匹配等问题
+func (f *DefaultMetadataStoreFactory) driver(config MetadataConfig) (metadataStoreDriver, error) {
+	drivers := f.registered()
+	f.mu.RLock()
+	driver, ok := drivers[config.Type]
+	f.mu.RUnlock()
+
+	if !ok {
+		return driver, fmt.Errorf("unsupported metadata store type: %s (supported: %s)",
+			config.Type, strings.Join(f.Types(), ", "))
+	}
+	if config.TTL != "" {
+		ttl, err := time.ParseDuration(config.TTL)
+		if err != nil {
+			return driver, fmt.Errorf("invalid metadata ttl %q: %w", config.TTL, err)
+		}
+		if ttl > 0 && driver.noTTL {
+			return driver, fmt.Errorf("metadata store %q does not support ttl", config.Type)
+		}
+	}
+	if driver.validator != nil {
+		if err := driver.validator(config.Data); err != nil {
+			return driver, fmt.Errorf("metadata store %q: invalid data: %w", config.Type, err)
+		}
+	}
+	return driver, nil
+}
+
+// validateFileMetadataData 校验 file 类型配置
+func validateFileMetadataData(data map[string]interface{}) error {
+	if err := requireString(data, "path"); err != nil {
+		return err
+	}
+	return optionalDuration(data, "poll_interval")
+}
+
+// validateHTTPMetadataData 校验 http 类型配置
+func validateHTTPMetadataData(data map[string]interface{}) error {
+	if err := requireString(data, "url"); err != nil {
+		return err
+	}
+	u, err := url.Parse(data["url"].(string))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http(s) URL, got %q", data["url"])
+	}
+	for _, key := range []string{"method", "token", "username", "password"} {
+		if err := optionalString(data, key); err != nil {
+			return err
+		}
+	}
+	if data["token"] != nil && data["username"] != nil {
+		return fmt.Errorf("token and username are mutually exclusive")
+	}
+	if headers, ok := data["headers"]; ok && headers != nil {
+		if _, ok := toStringMap(headers); !ok {
+			return fmt.Errorf("headers must be a map of strings, got %T", headers)
+		}
+	}
+	if retries, ok := data["retries"]; ok {
+		if n, err := toInt(retries); err != nil || n < 0 {
+			return fmt.Errorf("retries must be a non-negative integer, got %v", retries)
+		}
+	}
+	for _, key := range []string{"timeout", "poll_interval", "retry_backoff"} {
+		if err := optionalDuration(data, key); err != nil {
+			return err
+		}
+	}
+	if _, err := parseMetadataTLS(data["tls"]); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validateRedisMetadataData 校验 redis 类型配置
+func validateRedisMetadataData(data map[string]interface{}) error {
+	if _, err := parseRedisOptions(data); err != nil {
+		return err
+	}
+	return optionalDuration(data, "poll_interval")
+}
+
+// requireString 要求字段存在且为非空字符串
+func requireString(data map[string]interface{}, key string) error {
+	value, ok := data[key]
+	if !ok || value == nil {
+		return fmt.Errorf("%s is required", key)
+	}
+	str, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("%s must be a string, got %T", key, value)
+	}
+	if str == "" {
+		return fmt.Errorf("%s must not be empty", key)
+	}
+	return nil
+}
+
+// optionalString 字段存在时必须为字符串
+func optionalString(data map[string]interface{}, key string) error {
+	if value, ok := data[key]; ok && value != nil {
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s must be a string, got %T", key, value)
+		}
+	}
+	return nil
+}
+
+// optionalDuration 字段存在时必须为合法的时间间隔，例如 5s
+func optionalDuration(data map[string]interface{}, key string) error {
+	value, ok := data[key]
+	if !ok || value == nil {
+		return nil
+	}
+	str, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("%s must be a duration string such as \"5s\", got %T", key, value)
+	}
+	if _, err := time.ParseDuration(str); err != nil {
+		return fmt.Errorf("%s must be a duration such as \"5s\", got %q", key, str)
+	}
+	return nil
+}
+
+// toInt 将 YAML 中的整数或数字字符串转换为 int
+func toInt(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case uint64:
+		return int(v), nil
+	case float64:
+		if v != float64(int(v)) {
+			return 0, fmt.Errorf("%v is not an integer", v)
+		}
+		return int(v), nil
+	case string:
+		return strconv.Atoi(v)
+	default:
+		return 0, fmt.Errorf("unsupported type %T", value)
+	}
+}
+
+// toStringMap 将 YAML 解析出的 map 转换为 map[string]string
+// yaml.v2 嵌套 map 的类型为 map[interface{}]interface{}
+func toStringMap(value interface{}) (map[string]string, bool) {
+	result := map[string]string{}
+	switch m := value.(type) {
+	case map[string]interface{}:
+		for k, v := range m {
+			str, ok := v.(string)
+			if !ok {
+				return nil, false
+			}
+			result[k] = str
+		}
+	case map[interface{}]interface{}:
+		for k, v := range m {
+			key, ok := k.(string)
+			str, ok2 := v.(string)
+			if !ok || !ok2 {
+				return nil, false
+			}
+			result[key] = str
+		}
+	case map[string]string:
+		for k, v := range m {
+			result[k] = v
+		}
+	default:
+		return nil, false
+	}
+	return result, true
+}
@@ -0,0 +1,547 @@
+package pipelinex
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HTTP 元数据存储协议，key 作为路径片段转义：
+//
+//	GET    {url}/{key}            200 {"key": "...", "value": "...", "version": "..."}，响应头 ETag 为版本号；404 表示 key 不存在
+//	PUT    {url}/{key}            请求体 {"value": "...", "ttl": 60}，ttl 为过期秒数
+//	                              If-Match: "{version}" 仅在版本一致时写入，If-None-Match: * 仅在 key 不存在时写入，不满足返回 412
+//	DELETE {url}/{key}            200 或 204，key 不存在时返回 404 也视为成功
+//	GET    {url}?prefix={prefix}  200 {"keys": ["..."]}
+//
+// 错误响应体为 {"error": "..."}，NewHTTPMetadataHandler 是该协议的参考实现
+
+const (
+	defaultHTTPMetadataRetries      = 3
+	defaultHTTPMetadataRetryBackoff = 200 * time.Millisecond
+)
+
+// metadataEnvelope 单个 key 的请求和响应体
+type metadataEnvelope struct {
+	Key     string `json:"key,omitempty"`
+	Value   string `json:"value"`
+	Version string `json:"version,omitempty"`
+	TTL     int64  `json:"ttl,omitempty"`
+}
+
+// metadataKeyList 列出 key 的响应体
+type metadataKeyList struct {
+	Keys []string `json:"keys"`
+}
+
+// metadataErrorBody 错误响应体
+type metadataErrorBody struct {
+	Error string `json:"error"`
+}
+
+// HTTPMetadataStore HTTP 元数据存储
+type HTTPMetadataStore struct {
+	url          string
+	method       string // 写入使用的方法
+	headers      map[string]string
+	token        string
+	username     string
+	password     string
+	retries      int
+	retryBackoff time.Duration
+	client       *http.Client
+	pollInterval time.Duration // Watch 轮询间隔
+}
+
+// NewHTTPMetadataStore 创建基于HTTP的元数据存储
+func NewHTTPMetadataStore(config MetadataConfig) (*HTTPMetadataStore, error) {
+	cfg := HTTPMetadataConfig{}
+
+	// 解析配置
+	if url, ok := config.Data["url"].(string); ok {
+		cfg.URL = strings.TrimRight(url, "/")
+	} else {
+		return nil, fmt.Errorf("http metadata store requires url")
+	}
+
+	// method 在旧协议中是读取使用的方法（默认 GET），GET/HEAD 已废弃，此时写入使用 PUT
+	cfg.Method = http.MethodPut
+	if method, ok := config.Data["method"].(string); ok && method != "" {
+		switch m := strings.ToUpper(method); m {
+		case http.MethodGet, http.MethodHead:
+			fmt.Printf("http metadata store: method %s is deprecated and ignored, writes use PUT\n", m)
+		default:
+			cfg.Method = m
+		}
+	}
+
+	cfg.Headers = make(map[string]string)
+	if headers, ok := toStringMap(config.Data["headers"]); ok {
+		cfg.Headers = headers
+	}
+
+	cfg.Token, _ = config.Data["token"].(string)
+	cfg.Username, _ = config.Data["username"].(string)
+	cfg.Password, _ = config.Data["password"].(string)
+
+	cfg.Retries = defaultHTTPMetadataRetries
+	if retries, ok := config.Data["retries"]; ok {
+		n, err := toInt(retries)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("retries must be a non-negative integer, got %v", retries)
+		}
+		cfg.Retries = n
+	}
+
+	tlsConfig, err := parseMetadataTLS(config.Data["tls"])
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &HTTPMetadataStore{
+		url:          cfg.URL,
+		method:       cfg.Method,
+		headers:      cfg.Headers,
+		token:        cfg.Token,
+		username:     cfg.Username,
+		password:     cfg.Password,
+		retries:      cfg.Retries,
+		retryBackoff: parseDurationOption(config.Data, "retry_backoff", defaultHTTPMetadataRetryBackoff),
+		client: &http.Client{
+			Timeout:   parseDurationOption(config.Data, "timeout", 30*time.Second),
+			Transport: transport,
+		},
+		pollInterval: parseDurationOption(config.Data, "poll_interval", defaultWatchInterval),
+	}, nil
+}
+
+// Get 从HTTP接口获取元数据
+func (s *HTTPMetadataStore) Get(ctx context.Context, key string) (string, error) {
+	envelope, err := s.get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	return envelope.Value, nil
+}
+
+// Set 通过HTTP接口设置元数据
+func (s *HTTPMetadataStore) Set(ctx context.Context, key string, value string) error {
+	return s.SetWithTTL(ctx, key, value, 0)
+}
+
+// SetWithTTL 通过HTTP接口设置元数据，ttl 以秒为单位放在请求体的 ttl 字段中
+// 协议中 0 表示永不过期，不足一秒的部分向上取整，避免短 ttl 变成永久 key
+func (s *HTTPMetadataStore) SetWithTTL(ctx context.Context, key string, value string, ttl time.Duration) error {
+	var seconds int64
+	if ttl > 0 {
+		seconds = int64((ttl + time.Second - 1) / time.Second)
+	}
+	_, err := s.put(ctx, key, metadataEnvelope{Value: value, TTL: seconds}, nil)
+	return err
+}
+
+// Delete 通过HTTP接口删除元数据
+func (s *HTTPMetadataStore) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, s.keyURL(key), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return statusError(resp)
+	}
+}
+
+// List 通过HTTP接口列出指定前缀的key
+func (s *HTTPMetadataStore) List(ctx context.Context, prefix string) ([]string, error) {
+	listURL := fmt.Sprintf("%s?prefix=%s", s.url, neturl.QueryEscape(prefix))
+	resp, err := s.do(ctx, http.MethodGet, listURL, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp)
+	}
+
+	var list metadataKeyList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode key list: %w", err)
+	}
+	if list.Keys == nil {
+		list.Keys = []string{}
+	}
+	sort.Strings(list.Keys)
+	return list.Keys, nil
+}
+
+// GetMany 逐个获取元数据值，跳过不存在的key
+func (s *HTTPMetadataStore) GetMany(ctx context.Context, keys []string) (map[string]string, error) {
+	result := make(map[string]string, len(keys))
+	for _, key := range keys {
+		value, err := s.Get(ctx, key)
+		if errors.Is(err, ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+// SetMany 逐个设置元数据值
+func (s *HTTPMetadataStore) SetMany(ctx context.Context, values map[string]string) error {
+	for key, value := range values {
+		if err := s.Set(ctx, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Watch 通过轮询监听key的变化
+func (s *HTTPMetadataStore) Watch(ctx context.Context, key string) (<-chan MetadataEvent, error) {
+	return watchLoop(ctx, key, func() (string, bool, error) {
+		return lookup(s.Get(ctx, key))
+	}, pollEvery(s.pollInterval)), nil
+}
+
+// CompareAndSwap 通过条件请求比较并交换
+// old 为空时使用 If-None-Match: *，否则先读取当前版本再使用 If-Match 写入，服务端返回 412 表示冲突
+func (s *HTTPMetadataStore) CompareAndSwap(ctx context.Context, key string, old string, new string) (bool, error) {
+	header := http.Header{}
+	if old == "" {
+		header.Set("If-None-Match", "*")
+	} else {
+		current, err := s.get(ctx, key)
+		if errors.Is(err, ErrKeyNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if current.Value != old {
+			return false, nil
+		}
+		if current.Version == "" {
+			return false, fmt.Errorf("server returned no version for key %s", key)
+		}
+		header.Set("If-Match", quoteETag(current.Version))
+	}
+
+	resp, err := s.put(ctx, key, metadataEnvelope{Value: new}, header)
+	if errors.Is(err, errPreconditionFailed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+// Incr 基于 CompareAndSwap 实现自增，冲突时重试
+func (s *HTTPMetadataStore) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	return incrByCAS(ctx, s, key, delta)
+}
+
+// Close 关闭存储
+func (s *HTTPMetadataStore) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// errPreconditionFailed 条件写入不满足
+var errPreconditionFailed = errors.New("precondition failed")
+
+// get 读取key的值和版本号
+func (s *HTTPMetadataStore) get(ctx context.Context, key string) (metadataEnvelope, error) {
+	envelope := metadataEnvelope{}
+	resp, err := s.do(ctx, http.MethodGet, s.keyURL(key), nil, nil)
+	if err != nil {
+		return envelope, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return envelope, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return envelope, statusError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return envelope, fmt.Errorf("failed to decode response body: %w", err)
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		envelope.Version = unquoteETag(etag)
+	}
+	return envelope, nil
+}
+
+// put 写入key，header 中可以携带条件请求头
+func (s *HTTPMetadataStore) put(ctx context.Context, key string, envelope metadataEnvelope, header http.Header) (*http.Response, error) {
+	envelope.Key = key
+	jsonData, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	resp, err := s.do(ctx, s.method, s.keyURL(key), jsonData, header)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return resp, nil
+	case http.StatusPreconditionFailed:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s", errPreconditionFailed, key)
+	default:
+		defer resp.Body.Close()
+		return nil, statusError(resp)
+	}
+}
+
+// do 发送请求，网络错误、429 和 5xx 时按指数退避重试
+// 条件写入只在服务端明确未处理请求（429、503）时重试，避免重复执行后被误判为冲突
+func (s *HTTPMetadataStore) do(ctx context.Context, method string, target string, body []byte, header http.Header) (*http.Response, error) {
+	conditional := header.Get("If-Match") != "" || header.Get("If-None-Match") != ""
+	backoff := s.retryBackoff
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.Header.Set("Accept", "application/json")
+		for k, v := range s.headers {
+			req.Header.Set(k, v)
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if s.token != "" {
+			req.Header.Set("Authorization", "Bearer "+s.token)
+		} else if s.username != "" {
+			req.SetBasicAuth(s.username, s.password)
+		}
+
+		resp, err := s.client.Do(req)
+		retry := false
+		if err != nil {
+			retry = !conditional && ctx.Err() == nil
+		} else {
+			switch {
+			case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusServiceUnavailable:
+				retry = true
+			case resp.StatusCode >= http.StatusInternalServerError:
+				retry = !conditional
+			}
+		}
+
+		if !retry || attempt >= s.retries {
+			if err != nil {
+				return nil, fmt.Errorf("failed to execute request: %w", err)
+			}
+			return resp, nil
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// keyURL 返回key对应的资源地址
+func (s *HTTPMetadataStore) keyURL(key string) string {
+	return s.url + "/" + neturl.PathEscape(key)
+}
+
+// statusError 将非预期的响应转换为错误，响应体为 {"error": "..."} 时附带错误信息
+func statusError(resp *http.Response) error {
+	var body metadataErrorBody
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		return fmt.Errorf("unexpected status code: %d: %s", resp.StatusCode, body.Error)
+	}
+	return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+}
+
+// quoteETag 将版本号转换为 ETag 格式
+func quoteETag(version string) string {
+	return `"` + version + `"`
+}
+
+// unquoteETag 从 ETag 中取出版本号
+func unquoteETag(etag string) string {
+	return strings.Trim(strings.TrimPrefix(etag, "W/"), `"`)
+}
+
+// httpMetadataHandler HTTP 元数据存储协议的参考服务端
+type httpMetadataHandler struct {
+	store MetadataStore
+}
+
+// NewHTTPMetadataHandler 创建基于 MetadataStore 的 HTTP 元数据服务端
+// 版本号为值的内容摘要，条件写入通过 CompareAndSwap 保证原子性；挂载到子路径时配合 http.StripPrefix 使用
+func NewHTTPMetadataHandler(store MetadataStore) http.Handler {
+	return &httpMetadataHandler{store: store}
+}
+
+// ServeHTTP 处理元数据请求
+func (h *httpMetadataHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key, err := neturl.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/"))
+	if err != nil {
+		writeMetadataError(w, http.StatusBadRequest, fmt.Sprintf("invalid key: %v", err))
+		return
+	}
+
+	if key == "" {
+		if r.Method != http.MethodGet {
+			writeMetadataError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h.list(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.get(w, r, key)
+	case http.MethodPut, http.MethodPost:
+		h.put(w, r, key)
+	case http.MethodDelete:
+		h.delete(w, r, key)
+	default:
+		writeMetadataError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// list 列出指定前缀的key
+func (h *httpMetadataHandler) list(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.store.List(r.Context(), r.URL.Query().Get("prefix"))
+	if err != nil {
+		writeMetadataError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeMetadataJSON(w, http.StatusOK, metadataKeyList{Keys: keys})
+}
+
+// get 读取key
+func (h *httpMetadataHandler) get(w http.ResponseWriter, r *http.Request, key string) {
+	value, err := h.store.Get(r.Context(), key)
+	if errors.Is(err, ErrKeyNotFound) {
+		writeMetadataError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeMetadataError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeMetadataEnvelope(w, http.StatusOK, key, value)
+}
+
+// put 写入key，带条件请求头时通过 CompareAndSwap 写入，此时忽略 ttl
+func (h *httpMetadataHandler) put(w http.ResponseWriter, r *http.Request, key string) {
+	var envelope metadataEnvelope
+	if err := json.NewDecoder(r.Body).Decode(&envelope); err != nil {
+		writeMetadataError(w, http.StatusBadRequest, fmt.Sprintf("invalid body: %v", err))
+		return
+	}
+	ctx := r.Context()
+
+	swapped := true
+	var err error
+	switch ifMatch := r.Header.Get("If-Match"); {
+	case r.Header.Get("If-None-Match") == "*":
+		swapped, err = h.store.CompareAndSwap(ctx, key, "", envelope.Value)
+	case ifMatch != "":
+		var current string
+		current, err = h.store.Get(ctx, key)
+		if errors.Is(err, ErrKeyNotFound) || (err == nil && metadataVersion(current) != unquoteETag(ifMatch)) {
+			swapped, err = false, nil
+		} else if err == nil {
+			swapped, err = h.store.CompareAndSwap(ctx, key, current, envelope.Value)
+		}
+	default:
+		err = h.store.SetWithTTL(ctx, key, envelope.Value, time.Duration(envelope.TTL)*time.Second)
+	}
+
+	if err != nil {
+		writeMetadataError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !swapped {
+		writeMetadataError(w, http.StatusPreconditionFailed, "precondition failed")
+		return
+	}
+	writeMetadataEnvelope(w, http.StatusOK, key, envelope.Value)
+}
+
+// delete 删除key
+func (h *httpMetadataHandler) delete(w http.ResponseWriter, r *http.Request, key string) {
+	if _, err := h.store.Get(r.Context(), key); errors.Is(err, ErrKeyNotFound) {
+		writeMetadataError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err := h.store.Delete(r.Context(), key); err != nil {
+		writeMetadataError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// metadataVersion 根据值计算版本号
+func metadataVersion(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:8])
+}
+
+// writeMetadataEnvelope 返回key的值和版本号
+func writeMetadataEnvelope(w http.ResponseWriter, status int, key string, value string) {
+	version := metadataVersion(value)
+	w.Header().Set("ETag", quoteETag(version))
+	writeMetadataJSON(w, status, metadataEnvelope{Key: key, Value: value, Version: version})
+}
+
+// writeMetadataError 返回错误信息
+func writeMetadataError(w http.ResponseWriter, status int, message string) {
+	writeMetadataJSON(w, status, metadataErrorBody{Error: message})
+}
+
+// writeMetadataJSON 返回JSON响应
+func writeMetadataJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
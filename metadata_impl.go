@@ -4,38 +4,38 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
-
-	"github.com/redis/go-redis/v9"
 )
 
 // InConfigMetadataStore 从配置中直接读取数据的元数据存储
 type InConfigMetadataStore struct {
-	data map[string]string
+	data   map[string]string
+	native map[string]any // 非字符串值的原生类型
 }
 
 // NewInConfigMetadataStore 创建基于配置的元数据存储
 func NewInConfigMetadataStore(config MetadataConfig) (*InConfigMetadataStore, error) {
-	data := make(map[string]string)
-	for key, value := range config.Data {
-		if strVal, ok := value.(string); ok {
-			data[key] = strVal
-		} else {
-			data[key] = fmt.Sprintf("%v", value)
-		}
+	data, native, err := seedMetadataData(config.Data)
+	if err != nil {
+		return nil, err
 	}
-	return &InConfigMetadataStore{data: data}, nil
+	return &InConfigMetadataStore{data: data, native: native}, nil
 }
 
 // Get 获取元数据值
 func (s *InConfigMetadataStore) Get(ctx context.Context, key string) (string, error) {
 	value, exists := s.data[key]
 	if !exists {
-		return "", fmt.Errorf("key %s not found", key)
+		return "", fmt.Errorf("%w: %s", ErrKeyNotFound, key)
 	}
 	return value, nil
 }
@@ -45,263 +45,789 @@ func (s *InConfigMetadataStore) Set(ctx context.Context, key string, value strin
 	return fmt.Errorf("in-config metadata store is read-only")
 }
 
+// SetWithTTL 设置元数据值（in-config 类型为只读，返回错误）
+func (s *InConfigMetadataStore) SetWithTTL(ctx context.Context, key string, value string, ttl time.Duration) error {
+	return fmt.Errorf("in-config metadata store is read-only")
+}
+
 // Delete 删除元数据（in-config 类型为只读，返回错误）
 func (s *InConfigMetadataStore) Delete(ctx context.Context, key string) error {
 	return fmt.Errorf("in-config metadata store is read-only")
 }
 
+// List 列出指定前缀的key
+func (s *InConfigMetadataStore) List(ctx context.Context, prefix string) ([]string, error) {
+	return listKeys(s.data, prefix), nil
+}
+
+// GetMany 批量获取元数据值
+func (s *InConfigMetadataStore) GetMany(ctx context.Context, keys []string) (map[string]string, error) {
+	return getMany(s.data, keys), nil
+}
+
+// SetMany 批量设置元数据值（in-config 类型为只读，返回错误）
+func (s *InConfigMetadataStore) SetMany(ctx context.Context, values map[string]string) error {
+	return fmt.Errorf("in-config metadata store is read-only")
+}
+
+// Watch 监听key的变化，in-config 数据不会变化，只会发送一次当前值
+func (s *InConfigMetadataStore) Watch(ctx context.Context, key string) (<-chan MetadataEvent, error) {
+	return watchLoop(ctx, key, func() (string, bool, error) {
+		value, exists := s.data[key]
+		return value, exists, nil
+	}, func() <-chan struct{} {
+		return nil
+	}), nil
+}
+
+// CompareAndSwap in-config 类型为只读，返回错误
+func (s *InConfigMetadataStore) CompareAndSwap(ctx context.Context, key string, old string, new string) (bool, error) {
+	return false, fmt.Errorf("in-config metadata store is read-only")
+}
+
+// Incr in-config 类型为只读，返回错误
+func (s *InConfigMetadataStore) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	return 0, fmt.Errorf("in-config metadata store is read-only")
+}
+
 // Close 关闭存储
 func (s *InConfigMetadataStore) Close() error {
 	return nil
 }
 
-// HTTPMetadataStore HTTP 元数据存储
-type HTTPMetadataStore struct {
-	url     string
-	method  string
-	headers map[string]string
-	client  *http.Client
+// nativeMetadataValue 返回配置中非字符串值的原生类型
+func (s *InConfigMetadataStore) nativeMetadataValue(key string) (any, bool) {
+	value, ok := s.native[key]
+	return value, ok
 }
 
-// NewHTTPMetadataStore 创建基于HTTP的元数据存储
-func NewHTTPMetadataStore(config MetadataConfig) (*HTTPMetadataStore, error) {
-	cfg := HTTPMetadataConfig{}
+// MemoryMetadataStore 内存元数据存储，可读写且并发安全
+type MemoryMetadataStore struct {
+	mu       sync.Mutex
+	data     map[string]string
+	native   map[string]any                        // 尚未被改写的初始数据中非字符串值的原生类型
+	expires  map[string]time.Time                  // key 的过期时间，未设置表示永不过期
+	watchers map[string]map[chan struct{}]struct{} // key -> 监听者的通知通道
+}
 
-	// 解析配置
-	if url, ok := config.Data["url"].(string); ok {
-		cfg.URL = url
+// NewMemoryMetadataStore 创建基于内存的元数据存储，config.Data 作为初始数据
+func NewMemoryMetadataStore(config MetadataConfig) (*MemoryMetadataStore, error) {
+	data, native, err := seedMetadataData(config.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &MemoryMetadataStore{
+		data:     data,
+		native:   native,
+		expires:  make(map[string]time.Time),
+		watchers: make(map[string]map[chan struct{}]struct{}),
+	}, nil
+}
+
+// Get 获取元数据值
+func (s *MemoryMetadataStore) Get(ctx context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purgeExpired()
+	value, exists := s.data[key]
+	if !exists {
+		return "", fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	return value, nil
+}
+
+// Set 设置元数据值
+func (s *MemoryMetadataStore) Set(ctx context.Context, key string, value string) error {
+	return s.SetWithTTL(ctx, key, value, 0)
+}
+
+// SetWithTTL 设置元数据值并指定过期时间
+func (s *MemoryMetadataStore) SetWithTTL(ctx context.Context, key string, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = value
+	if ttl > 0 {
+		s.expires[key] = time.Now().Add(ttl)
 	} else {
-		return nil, fmt.Errorf("http metadata store requires url")
+		delete(s.expires, key)
 	}
+	s.notify(key)
+	return nil
+}
 
-	cfg.Method = "GET"
-	if method, ok := config.Data["method"].(string); ok && method != "" {
-		cfg.Method = method
+// Delete 删除元数据
+func (s *MemoryMetadataStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, key)
+	delete(s.expires, key)
+	s.notify(key)
+	return nil
+}
+
+// List 列出指定前缀的key
+func (s *MemoryMetadataStore) List(ctx context.Context, prefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purgeExpired()
+	return listKeys(s.data, prefix), nil
+}
+
+// GetMany 批量获取元数据值
+func (s *MemoryMetadataStore) GetMany(ctx context.Context, keys []string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purgeExpired()
+	return getMany(s.data, keys), nil
+}
+
+// SetMany 批量设置元数据值
+func (s *MemoryMetadataStore) SetMany(ctx context.Context, values map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, value := range values {
+		s.data[key] = value
+		delete(s.expires, key)
+		s.notify(key)
 	}
+	return nil
+}
 
-	cfg.Headers = make(map[string]string)
-	if headers, ok := config.Data["headers"].(map[string]interface{}); ok {
-		for k, v := range headers {
-			if strVal, ok := v.(string); ok {
-				cfg.Headers[k] = strVal
-			}
+// Watch 监听key的变化
+func (s *MemoryMetadataStore) Watch(ctx context.Context, key string) (<-chan MetadataEvent, error) {
+	signal := make(chan struct{}, 1)
+
+	s.mu.Lock()
+	if s.watchers[key] == nil {
+		s.watchers[key] = make(map[chan struct{}]struct{})
+	}
+	s.watchers[key][signal] = struct{}{}
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		delete(s.watchers[key], signal)
+		if len(s.watchers[key]) == 0 {
+			delete(s.watchers, key)
 		}
+		s.mu.Unlock()
+	}()
+
+	return watchLoop(ctx, key, func() (string, bool, error) {
+		return lookup(s.Get(ctx, key))
+	}, func() <-chan struct{} {
+		return signal
+	}), nil
+}
+
+// CompareAndSwap 比较并交换，写入的值与 Set 一样永不过期
+func (s *MemoryMetadataStore) CompareAndSwap(ctx context.Context, key string, old string, new string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purgeExpired()
+	current, exists := s.data[key]
+	if !casMatch(current, exists, old) {
+		return false, nil
 	}
+	s.data[key] = new
+	delete(s.expires, key)
+	s.notify(key)
+	return true, nil
+}
+
+// Incr 原子自增
+func (s *MemoryMetadataStore) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purgeExpired()
+	val, err := incrValue(s.data, key, delta)
+	if err != nil {
+		return 0, err
+	}
+	s.notify(key)
+	return val, nil
+}
 
-	timeout := 30 * time.Second
-	if timeoutStr, ok := config.Data["timeout"].(string); ok {
-		if d, err := time.ParseDuration(timeoutStr); err == nil {
-			timeout = d
+// notify 在key被改写后调用，清除初始数据的原生类型并通知监听key的协程重新读取，调用方需持有锁
+func (s *MemoryMetadataStore) notify(key string) {
+	delete(s.native, key)
+	for signal := range s.watchers[key] {
+		select {
+		case signal <- struct{}{}:
+		default:
 		}
 	}
+}
+
+// purgeExpired 清理已过期的key，调用方需持有锁
+func (s *MemoryMetadataStore) purgeExpired() {
+	now := time.Now()
+	for key, expireAt := range s.expires {
+		if !now.Before(expireAt) {
+			delete(s.data, key)
+			delete(s.expires, key)
+			s.notify(key)
+		}
+	}
+}
+
+// Close 关闭存储
+func (s *MemoryMetadataStore) Close() error {
+	return nil
+}
+
+// nativeMetadataValue 返回尚未被改写的初始数据的原生类型
+func (s *MemoryMetadataStore) nativeMetadataValue(key string) (any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purgeExpired()
+	value, ok := s.native[key]
+	return value, ok
+}
+
+// FileMetadataStore 基于本地JSON文件的元数据存储
+// 写入时先写临时文件再重命名保证原子性，并通过文件锁保证多进程并发安全
+type FileMetadataStore struct {
+	path         string
+	pollInterval time.Duration // Watch 轮询间隔
+}
+
+// NewFileMetadataStore 创建基于文件的元数据存储
+func NewFileMetadataStore(config MetadataConfig) (*FileMetadataStore, error) {
+	cfg := FileMetadataConfig{}
+
+	if path, ok := config.Data["path"].(string); ok && path != "" {
+		cfg.Path = path
+	} else {
+		return nil, fmt.Errorf("file metadata store requires path")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create metadata directory: %w", err)
+	}
 
-	return &HTTPMetadataStore{
-		url:     cfg.URL,
-		method:  cfg.Method,
-		headers: cfg.Headers,
-		client: &http.Client{
-			Timeout: timeout,
-		},
+	return &FileMetadataStore{
+		path:         cfg.Path,
+		pollInterval: parseDurationOption(config.Data, "poll_interval", defaultWatchInterval),
 	}, nil
 }
 
-// Get 从HTTP接口获取元数据
-func (s *HTTPMetadataStore) Get(ctx context.Context, key string) (string, error) {
-	url := fmt.Sprintf("%s?key=%s", s.url, key)
-	req, err := http.NewRequestWithContext(ctx, s.method, url, nil)
+// Get 从文件获取元数据
+func (s *FileMetadataStore) Get(ctx context.Context, key string) (string, error) {
+	var value string
+	var exists bool
+	err := s.withLock(false, func() error {
+		data, err := s.load()
+		if err != nil {
+			return err
+		}
+		value, exists = data[key]
+		return nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return "", err
 	}
+	if !exists {
+		return "", fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	return value, nil
+}
 
-	for k, v := range s.headers {
-		req.Header.Set(k, v)
+// Set 设置元数据并写回文件
+func (s *FileMetadataStore) Set(ctx context.Context, key string, value string) error {
+	return s.withLock(true, func() error {
+		data, err := s.load()
+		if err != nil {
+			return err
+		}
+		data[key] = value
+		return s.save(data)
+	})
+}
+
+// SetWithTTL 文件存储不支持过期时间，ttl > 0 时返回错误
+func (s *FileMetadataStore) SetWithTTL(ctx context.Context, key string, value string, ttl time.Duration) error {
+	if ttl > 0 {
+		return fmt.Errorf("file metadata store does not support ttl")
 	}
+	return s.Set(ctx, key, value)
+}
+
+// Delete 删除元数据并写回文件
+func (s *FileMetadataStore) Delete(ctx context.Context, key string) error {
+	return s.withLock(true, func() error {
+		data, err := s.load()
+		if err != nil {
+			return err
+		}
+		if _, exists := data[key]; !exists {
+			return nil
+		}
+		delete(data, key)
+		return s.save(data)
+	})
+}
+
+// List 列出指定前缀的key
+func (s *FileMetadataStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := s.withLock(false, func() error {
+		data, err := s.load()
+		if err != nil {
+			return err
+		}
+		keys = listKeys(data, prefix)
+		return nil
+	})
+	return keys, err
+}
+
+// GetMany 批量获取元数据值
+func (s *FileMetadataStore) GetMany(ctx context.Context, keys []string) (map[string]string, error) {
+	var result map[string]string
+	err := s.withLock(false, func() error {
+		data, err := s.load()
+		if err != nil {
+			return err
+		}
+		result = getMany(data, keys)
+		return nil
+	})
+	return result, err
+}
 
-	resp, err := s.client.Do(req)
+// SetMany 批量设置元数据值，只写一次文件
+func (s *FileMetadataStore) SetMany(ctx context.Context, values map[string]string) error {
+	return s.withLock(true, func() error {
+		data, err := s.load()
+		if err != nil {
+			return err
+		}
+		for key, value := range values {
+			data[key] = value
+		}
+		return s.save(data)
+	})
+}
+
+// Watch 通过轮询文件监听key的变化，可以感知其他进程的写入
+func (s *FileMetadataStore) Watch(ctx context.Context, key string) (<-chan MetadataEvent, error) {
+	return watchLoop(ctx, key, func() (string, bool, error) {
+		return lookup(s.Get(ctx, key))
+	}, pollEvery(s.pollInterval)), nil
+}
+
+// CompareAndSwap 持有文件排他锁比较并交换
+func (s *FileMetadataStore) CompareAndSwap(ctx context.Context, key string, old string, new string) (bool, error) {
+	swapped := false
+	err := s.withLock(true, func() error {
+		data, err := s.load()
+		if err != nil {
+			return err
+		}
+		current, exists := data[key]
+		if !casMatch(current, exists, old) {
+			return nil
+		}
+		data[key] = new
+		swapped = true
+		return s.save(data)
+	})
+	return swapped && err == nil, err
+}
+
+// Incr 持有文件排他锁自增
+func (s *FileMetadataStore) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	var val int64
+	err := s.withLock(true, func() error {
+		data, err := s.load()
+		if err != nil {
+			return err
+		}
+		val, err = incrValue(data, key, delta)
+		if err != nil {
+			return err
+		}
+		return s.save(data)
+	})
+	return val, err
+}
+
+// Close 关闭存储
+func (s *FileMetadataStore) Close() error {
+	return nil
+}
+
+// withLock 持有文件锁执行fn，exclusive 为 false 时使用共享锁
+func (s *FileMetadataStore) withLock(exclusive bool, fn func() error) error {
+	lockFile, err := os.OpenFile(s.path+".lock", os.O_CREATE|os.O_RDWR, 0o644)
 	if err != nil {
-		return "", fmt.Errorf("failed to execute request: %w", err)
+		return fmt.Errorf("failed to open lock file: %w", err)
 	}
-	defer resp.Body.Close()
+	defer lockFile.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	if err := lockMetadataFile(lockFile, exclusive); err != nil {
+		return fmt.Errorf("failed to lock metadata file: %w", err)
 	}
+	defer unlockMetadataFile(lockFile)
+
+	return fn()
+}
 
-	body, err := io.ReadAll(resp.Body)
+// load 读取文件中的全部数据，文件不存在时返回空数据
+func (s *FileMetadataStore) load() (map[string]string, error) {
+	data := make(map[string]string)
+	content, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return data, nil
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %w", err)
+		return nil, fmt.Errorf("failed to read metadata file: %w", err)
 	}
-
-	return string(body), nil
+	if len(bytes.TrimSpace(content)) == 0 {
+		return data, nil
+	}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata file: %w", err)
+	}
+	return data, nil
 }
 
-// Set 通过HTTP接口设置元数据
-func (s *HTTPMetadataStore) Set(ctx context.Context, key string, value string) error {
-	payload := map[string]string{"key": key, "value": value}
-	jsonData, err := json.Marshal(payload)
+// save 原子地写入全部数据
+func (s *FileMetadataStore) save(data map[string]string) error {
+	content, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+		return fmt.Errorf("failed to encode metadata: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(jsonData))
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp-*")
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to create temp file: %w", err)
 	}
+	defer os.Remove(tmp.Name())
 
-	req.Header.Set("Content-Type", "application/json")
-	for k, v := range s.headers {
-		req.Header.Set(k, v)
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
 	}
 
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to execute request: %w", err)
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace metadata file: %w", err)
 	}
-	defer resp.Body.Close()
+	return nil
+}
+
+// ScopedMetadataStore 为底层存储的key增加命名空间前缀，并为写入设置默认过期时间
+// 多条流水线共用同一个存储时，通过命名空间互相隔离
+type ScopedMetadataStore struct {
+	store  MetadataStore
+	prefix string        // key前缀，为空表示全局作用域
+	ttl    time.Duration // Set/SetMany 的默认过期时间，0 表示永不过期
+}
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+// NewScopedMetadataStore 创建带命名空间的元数据存储，key 前缀为 namespace + "/"，namespace 为空时不增加前缀
+func NewScopedMetadataStore(store MetadataStore, namespace string, ttl time.Duration) *ScopedMetadataStore {
+	prefix := ""
+	if namespace != "" {
+		prefix = namespace + "/"
 	}
+	return &ScopedMetadataStore{store: store, prefix: prefix, ttl: ttl}
+}
 
-	return nil
+// Get 获取元数据值
+func (s *ScopedMetadataStore) Get(ctx context.Context, key string) (string, error) {
+	return s.store.Get(ctx, s.prefix+key)
 }
 
-// Delete 通过HTTP接口删除元数据
-func (s *HTTPMetadataStore) Delete(ctx context.Context, key string) error {
-	url := fmt.Sprintf("%s?key=%s", s.url, key)
-	req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+// Set 设置元数据值，使用默认过期时间
+func (s *ScopedMetadataStore) Set(ctx context.Context, key string, value string) error {
+	if s.ttl > 0 {
+		return s.store.SetWithTTL(ctx, s.prefix+key, value, s.ttl)
 	}
+	return s.store.Set(ctx, s.prefix+key, value)
+}
+
+// SetWithTTL 设置元数据值并指定过期时间
+func (s *ScopedMetadataStore) SetWithTTL(ctx context.Context, key string, value string, ttl time.Duration) error {
+	return s.store.SetWithTTL(ctx, s.prefix+key, value, ttl)
+}
+
+// Delete 删除元数据
+func (s *ScopedMetadataStore) Delete(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, s.prefix+key)
+}
 
-	for k, v := range s.headers {
-		req.Header.Set(k, v)
+// List 列出命名空间内指定前缀的key，返回的key不包含命名空间前缀
+func (s *ScopedMetadataStore) List(ctx context.Context, prefix string) ([]string, error) {
+	keys, err := s.store.List(ctx, s.prefix+prefix)
+	if err != nil {
+		return nil, err
+	}
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, s.prefix)
 	}
+	return keys, nil
+}
 
-	resp, err := s.client.Do(req)
+// GetMany 批量获取元数据值
+func (s *ScopedMetadataStore) GetMany(ctx context.Context, keys []string) (map[string]string, error) {
+	scopedKeys := make([]string, len(keys))
+	for i, key := range keys {
+		scopedKeys[i] = s.prefix + key
+	}
+	values, err := s.store.GetMany(ctx, scopedKeys)
 	if err != nil {
-		return fmt.Errorf("failed to execute request: %w", err)
+		return nil, err
+	}
+	result := make(map[string]string, len(values))
+	for key, value := range values {
+		result[strings.TrimPrefix(key, s.prefix)] = value
+	}
+	return result, nil
+}
+
+// SetMany 批量设置元数据值，设置了默认过期时间时逐个写入
+func (s *ScopedMetadataStore) SetMany(ctx context.Context, values map[string]string) error {
+	if s.ttl > 0 {
+		for key, value := range values {
+			if err := s.store.SetWithTTL(ctx, s.prefix+key, value, s.ttl); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
-	defer resp.Body.Close()
+	scoped := make(map[string]string, len(values))
+	for key, value := range values {
+		scoped[s.prefix+key] = value
+	}
+	return s.store.SetMany(ctx, scoped)
+}
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+// Watch 监听命名空间内key的变化，事件中的key不包含命名空间前缀
+func (s *ScopedMetadataStore) Watch(ctx context.Context, key string) (<-chan MetadataEvent, error) {
+	events, err := s.store.Watch(ctx, s.prefix+key)
+	if err != nil {
+		return nil, err
+	}
+	if s.prefix == "" {
+		return events, nil
 	}
 
-	return nil
+	ch := make(chan MetadataEvent, 1)
+	go func() {
+		defer close(ch)
+		for event := range events {
+			event.Key = strings.TrimPrefix(event.Key, s.prefix)
+			select {
+			case ch <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
 }
 
-// Close 关闭存储
-func (s *HTTPMetadataStore) Close() error {
-	return nil
+// CompareAndSwap 比较并交换
+func (s *ScopedMetadataStore) CompareAndSwap(ctx context.Context, key string, old string, new string) (bool, error) {
+	return s.store.CompareAndSwap(ctx, s.prefix+key, old, new)
 }
 
-// RedisMetadataStore Redis 元数据存储
-type RedisMetadataStore struct {
-	client *redis.Client
+// Incr 原子自增
+func (s *ScopedMetadataStore) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	return s.store.Incr(ctx, s.prefix+key, delta)
 }
 
-// NewRedisMetadataStore 创建基于Redis的元数据存储
-func NewRedisMetadataStore(config MetadataConfig) (*RedisMetadataStore, error) {
-	cfg := RedisMetadataConfig{}
+// Close 关闭底层存储
+func (s *ScopedMetadataStore) Close() error {
+	return s.store.Close()
+}
 
-	// 解析配置
-	if host, ok := config.Data["host"].(string); ok {
-		cfg.Host = host
-	} else {
-		cfg.Host = "localhost"
+// nativeMetadataValue 返回底层存储中初始数据的原生类型
+func (s *ScopedMetadataStore) nativeMetadataValue(key string) (any, bool) {
+	if reader, ok := s.store.(nativeMetadataReader); ok {
+		return reader.nativeMetadataValue(s.prefix + key)
 	}
+	return nil, false
+}
 
-	cfg.Port = 6379
-	if port, ok := config.Data["port"].(int); ok {
-		cfg.Port = port
-	} else if portStr, ok := config.Data["port"].(string); ok {
-		if p, err := strconv.Atoi(portStr); err == nil {
-			cfg.Port = p
+// MetadataNamespace 根据作用域计算元数据key的命名空间
+// 流水线作用域为 p/{名称}，运行作用域为 r/{名称}/{运行ID}，两者的前缀互不包含，名称和运行ID中的 / 会被转义
+// scope 为空时，in-config 和 memory 存储（每次运行独占，data 为初始数据）使用全局作用域，
+// 其他存储在设置了流水线名称时按流水线隔离
+func MetadataNamespace(config MetadataConfig, pipelineName string, runID string) (string, error) {
+	scope := config.Scope
+	if scope == "" {
+		if config.Type == "in-config" || config.Type == "memory" || pipelineName == "" {
+			scope = MetadataScopeGlobal
+		} else {
+			scope = MetadataScopePipeline
 		}
 	}
 
-	cfg.DB = 0
-	if db, ok := config.Data["db"].(int); ok {
-		cfg.DB = db
-	} else if dbStr, ok := config.Data["db"].(string); ok {
-		if d, err := strconv.Atoi(dbStr); err == nil {
-			cfg.DB = d
+	switch scope {
+	case MetadataScopeGlobal:
+		return "", nil
+	case MetadataScopePipeline:
+		if pipelineName == "" {
+			return "", fmt.Errorf("metadata scope %s requires pipeline Name", scope)
 		}
+		return "p/" + url.PathEscape(pipelineName), nil
+	case MetadataScopeRun:
+		if pipelineName == "" || runID == "" {
+			return "", fmt.Errorf("metadata scope %s requires pipeline Name and run id", scope)
+		}
+		return "r/" + url.PathEscape(pipelineName) + "/" + url.PathEscape(runID), nil
+	default:
+		return "", fmt.Errorf("unsupported metadata scope: %s", scope)
 	}
+}
 
-	if username, ok := config.Data["username"].(string); ok {
-		cfg.Username = username
-	}
-
-	if password, ok := config.Data["password"].(string); ok {
-		cfg.Password = password
-	}
+// defaultWatchInterval Watch 默认的轮询间隔
+const defaultWatchInterval = time.Second
+
+// watchLoop 读取key的当前值，每次 changed 返回的通道就绪后重新读取，值变化时发送事件
+// 首次读取时key存在才发送事件，读取出错时忽略本次结果等待下一次
+func watchLoop(ctx context.Context, key string, get func() (string, bool, error), changed func() <-chan struct{}) <-chan MetadataEvent {
+	ch := make(chan MetadataEvent, 1)
+	go func() {
+		defer close(ch)
+		var last string
+		lastExists := false
+		first := true
+		for {
+			// 先获取通知通道再读取，避免读取之后、等待之前的变化被遗漏
+			next := changed()
+			value, exists, err := get()
+			if err == nil && (first || exists != lastExists || value != last) {
+				if exists || !first {
+					select {
+					case ch <- MetadataEvent{Key: key, Value: value, Deleted: !exists}:
+					case <-ctx.Done():
+						return
+					}
+				}
+				last, lastExists, first = value, exists, false
+			}
 
-	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
-	client := redis.NewClient(&redis.Options{
-		Addr:     addr,
-		Username: cfg.Username,
-		Password: cfg.Password,
-		DB:       cfg.DB,
-	})
+			select {
+			case <-next:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
 
-	return &RedisMetadataStore{client: client}, nil
+// pollEvery 返回按固定间隔就绪的通知通道，用于不支持推送的存储
+func pollEvery(interval time.Duration) func() <-chan struct{} {
+	return func() <-chan struct{} {
+		ch := make(chan struct{})
+		time.AfterFunc(interval, func() { close(ch) })
+		return ch
+	}
 }
 
-// Get 从Redis获取元数据
-func (s *RedisMetadataStore) Get(ctx context.Context, key string) (string, error) {
-	val, err := s.client.Get(ctx, key).Result()
-	if err == redis.Nil {
-		return "", fmt.Errorf("key %s not found", key)
+// lookup 将 Get 的结果转换为 (值, 是否存在, 错误)
+func lookup(value string, err error) (string, bool, error) {
+	if errors.Is(err, ErrKeyNotFound) {
+		return "", false, nil
 	}
 	if err != nil {
-		return "", fmt.Errorf("failed to get from redis: %w", err)
+		return "", false, err
 	}
-	return val, nil
+	return value, true, nil
 }
 
-// Set 设置Redis元数据
-func (s *RedisMetadataStore) Set(ctx context.Context, key string, value string) error {
-	err := s.client.Set(ctx, key, value, 0).Err()
-	if err != nil {
-		return fmt.Errorf("failed to set to redis: %w", err)
+// casMatch 判断当前值是否满足比较条件，old 为空表示要求key不存在
+// 因此已存在的空值永远无法匹配，需要比较空值时应先 Delete 再以 old 为空写入
+func casMatch(current string, exists bool, old string) bool {
+	if old == "" {
+		return !exists
 	}
-	return nil
+	return exists && current == old
 }
 
-// Delete 删除Redis元数据
-func (s *RedisMetadataStore) Delete(ctx context.Context, key string) error {
-	err := s.client.Del(ctx, key).Err()
-	if err != nil {
-		return fmt.Errorf("failed to delete from redis: %w", err)
+// incrValue 对map中的整数值自增，key不存在时视为0
+func incrValue(data map[string]string, key string, delta int64) (int64, error) {
+	var val int64
+	if current, ok := data[key]; ok {
+		parsed, err := strconv.ParseInt(current, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("value of key %s is not an integer: %w", key, err)
+		}
+		val = parsed
 	}
-	return nil
+	val += delta
+	data[key] = strconv.FormatInt(val, 10)
+	return val, nil
 }
 
-// Close 关闭Redis连接
-func (s *RedisMetadataStore) Close() error {
-	return s.client.Close()
+// incrByCAS 对不支持原子自增的存储，通过读取加比较并交换实现自增
+func incrByCAS(ctx context.Context, store MetadataStore, key string, delta int64) (int64, error) {
+	const maxAttempts = 10
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		current, exists, err := lookup(store.Get(ctx, key))
+		if err != nil {
+			return 0, err
+		}
+		var val int64
+		if exists {
+			val, err = strconv.ParseInt(current, 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("value of key %s is not an integer: %w", key, err)
+			}
+		}
+		val += delta
+		swapped, err := store.CompareAndSwap(ctx, key, current, strconv.FormatInt(val, 10))
+		if err != nil {
+			return 0, err
+		}
+		if swapped {
+			return val, nil
+		}
+	}
+	return 0, fmt.Errorf("failed to incr key %s: too many conflicts", key)
 }
 
-// DefaultMetadataStoreFactory 默认的元数据存储工厂
-type DefaultMetadataStoreFactory struct{}
+// parseDurationOption 从配置中读取时间间隔，未设置或格式错误时返回默认值
+func parseDurationOption(data map[string]interface{}, key string, def time.Duration) time.Duration {
+	if str, ok := data[key].(string); ok {
+		if d, err := time.ParseDuration(str); err == nil && d > 0 {
+			return d
+		}
+	}
+	return def
+}
 
-// NewMetadataStoreFactory 创建默认的元数据存储工厂
-func NewMetadataStoreFactory() MetadataStoreFactory {
-	return &DefaultMetadataStoreFactory{}
+// listKeys 返回map中指定前缀的key，按字典序排序
+func listKeys(data map[string]string, prefix string) []string {
+	keys := []string{}
+	for key := range data {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
 }
 
-// Create 根据配置类型创建对应的MetadataStore实例
-func (f *DefaultMetadataStoreFactory) Create(config MetadataConfig) (MetadataStore, error) {
-	switch config.Type {
-	case "in-config":
-		return NewInConfigMetadataStore(config)
-	case "http":
-		return NewHTTPMetadataStore(config)
-	case "redis":
-		return NewRedisMetadataStore(config)
-	default:
-		return nil, fmt.Errorf("unsupported metadata store type: %s", config.Type)
+// getMany 从map中批量取值，跳过不存在的key
+func getMany(data map[string]string, keys []string) map[string]string {
+	result := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if value, ok := data[key]; ok {
+			result[key] = value
+		}
 	}
-}
\ No newline at end of file
+	return result
+}
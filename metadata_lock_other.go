@@ -0,0 +1,23 @@
+//go:build !unix
+
+package pipelinex
+
+import (
+	"os"
+	"sync"
+)
+
+// metadataFileMu 不支持 flock 的平台上退化为进程内互斥
+var metadataFileMu sync.Mutex
+
+// lockMetadataFile 对文件加锁，仅在当前进程内生效
+func lockMetadataFile(f *os.File, exclusive bool) error {
+	metadataFileMu.Lock()
+	return nil
+}
+
+// unlockMetadataFile 释放文件锁
+func unlockMetadataFile(f *os.File) error {
+	metadataFileMu.Unlock()
+	return nil
+}
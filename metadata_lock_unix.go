@@ -0,0 +1,22 @@
+//go:build unix
+
+package pipelinex
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockMetadataFile 使用 flock 对文件加锁，多进程之间生效
+func lockMetadataFile(f *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	return syscall.Flock(int(f.Fd()), how)
+}
+
+// unlockMetadataFile 释放文件锁
+func unlockMetadataFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
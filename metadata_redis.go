@@ -0,0 +1,470 @@
+package pipelinex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis 部署模式
+const (
+	RedisModeSingle   = "single"
+	RedisModeSentinel = "sentinel"
+	RedisModeCluster  = "cluster"
+)
+
+// RedisMetadataStore Redis 元数据存储，支持单节点、Sentinel 和 Cluster
+type RedisMetadataStore struct {
+	client       redis.UniversalClient
+	db           int
+	pollInterval time.Duration // 键空间通知之外的兜底轮询间隔
+}
+
+// NewRedisMetadataStore 创建基于Redis的元数据存储
+func NewRedisMetadataStore(config MetadataConfig) (*RedisMetadataStore, error) {
+	opts, err := parseRedisOptions(config.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisMetadataStore{
+		client:       redis.NewUniversalClient(opts),
+		db:           opts.DB,
+		pollInterval: parseDurationOption(config.Data, "poll_interval", defaultWatchInterval),
+	}, nil
+}
+
+// Client 返回底层的 Redis 客户端
+func (s *RedisMetadataStore) Client() redis.UniversalClient {
+	return s.client
+}
+
+// Get 从Redis获取元数据
+func (s *RedisMetadataStore) Get(ctx context.Context, key string) (string, error) {
+	val, err := s.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get from redis: %w", err)
+	}
+	return val, nil
+}
+
+// Set 设置Redis元数据
+func (s *RedisMetadataStore) Set(ctx context.Context, key string, value string) error {
+	return s.SetWithTTL(ctx, key, value, 0)
+}
+
+// SetWithTTL 设置Redis元数据并指定过期时间
+func (s *RedisMetadataStore) SetWithTTL(ctx context.Context, key string, value string, ttl time.Duration) error {
+	if ttl < 0 {
+		ttl = 0
+	}
+	err := s.client.Set(ctx, key, value, ttl).Err()
+	if err != nil {
+		return fmt.Errorf("failed to set to redis: %w", err)
+	}
+	return nil
+}
+
+// Delete 删除Redis元数据
+func (s *RedisMetadataStore) Delete(ctx context.Context, key string) error {
+	err := s.client.Del(ctx, key).Err()
+	if err != nil {
+		return fmt.Errorf("failed to delete from redis: %w", err)
+	}
+	return nil
+}
+
+// List 使用SCAN列出指定前缀的key
+func (s *RedisMetadataStore) List(ctx context.Context, prefix string) ([]string, error) {
+	pattern := escapeRedisPattern(prefix) + "*"
+	var mu sync.Mutex
+	keys := []string{}
+	scan := func(ctx context.Context, client redis.Cmdable) error {
+		iter := client.Scan(ctx, 0, pattern, 100).Iterator()
+		for iter.Next(ctx) {
+			mu.Lock()
+			keys = append(keys, iter.Val())
+			mu.Unlock()
+		}
+		return iter.Err()
+	}
+
+	var err error
+	if cluster, ok := s.client.(*redis.ClusterClient); ok {
+		// 集群模式下 SCAN 只会扫描单个节点，需要遍历所有主节点
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return scan(ctx, client)
+		})
+	} else {
+		err = scan(ctx, s.client)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan redis: %w", err)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// GetMany 使用MGET批量获取元数据，集群模式下key可能分布在不同slot，改用pipeline逐个获取
+func (s *RedisMetadataStore) GetMany(ctx context.Context, keys []string) (map[string]string, error) {
+	result := make(map[string]string, len(keys))
+	if len(keys) == 0 {
+		return result, nil
+	}
+
+	if _, ok := s.client.(*redis.ClusterClient); ok {
+		cmds := make([]*redis.StringCmd, len(keys))
+		_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, key := range keys {
+				cmds[i] = pipe.Get(ctx, key)
+			}
+			return nil
+		})
+		// Pipelined 只返回第一个命令的错误，可能是不存在的 key 的 redis.Nil，
+		// 因此逐个检查命令，合并所有分片的错误
+		var errs []error
+		for i, cmd := range cmds {
+			val, err := cmd.Result()
+			switch {
+			case err == nil:
+				result[keys[i]] = val
+			case err != redis.Nil:
+				errs = append(errs, fmt.Errorf("key %s: %w", keys[i], err))
+			}
+		}
+		if len(errs) == 0 && err != nil && err != redis.Nil {
+			errs = append(errs, err)
+		}
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("failed to get from redis: %w", errors.Join(errs...))
+		}
+		return result, nil
+	}
+
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to mget from redis: %w", err)
+	}
+	for i, value := range values {
+		if strVal, ok := value.(string); ok {
+			result[keys[i]] = strVal
+		}
+	}
+	return result, nil
+}
+
+// SetMany 使用MSET批量设置元数据，集群模式下改用pipeline逐个设置
+func (s *RedisMetadataStore) SetMany(ctx context.Context, values map[string]string) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	if _, ok := s.client.(*redis.ClusterClient); ok {
+		_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for key, value := range values {
+				pipe.Set(ctx, key, value, 0)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to set to redis: %w", err)
+		}
+		return nil
+	}
+
+	pairs := make([]interface{}, 0, len(values)*2)
+	for key, value := range values {
+		pairs = append(pairs, key, value)
+	}
+	if err := s.client.MSet(ctx, pairs...).Err(); err != nil {
+		return fmt.Errorf("failed to mset to redis: %w", err)
+	}
+	return nil
+}
+
+// Watch 通过键空间通知监听key的变化
+// 需要 Redis 开启 notify-keyspace-events（例如 K$gx），未开启时退化为按 poll_interval 轮询
+// 集群模式下通知只在key所在节点发布，可能收不到，同样依赖轮询兜底
+func (s *RedisMetadataStore) Watch(ctx context.Context, key string) (<-chan MetadataEvent, error) {
+	pubsub := s.client.Subscribe(ctx, fmt.Sprintf("__keyspace@%d__:%s", s.db, key))
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe keyspace notifications: %w", err)
+	}
+
+	signal := make(chan struct{}, 1)
+	go func() {
+		defer pubsub.Close()
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-messages:
+				if !ok {
+					return
+				}
+				select {
+				case signal <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return watchLoop(ctx, key, func() (string, bool, error) {
+		return lookup(s.Get(ctx, key))
+	}, func() <-chan struct{} {
+		changed := make(chan struct{})
+		go func() {
+			defer close(changed)
+			select {
+			case <-signal:
+			case <-time.After(s.pollInterval):
+			case <-ctx.Done():
+			}
+		}()
+		return changed
+	}), nil
+}
+
+// CompareAndSwap 使用 WATCH/MULTI 实现比较并交换
+func (s *RedisMetadataStore) CompareAndSwap(ctx context.Context, key string, old string, new string) (bool, error) {
+	swapped := false
+	err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+		current, err := tx.Get(ctx, key).Result()
+		exists := err == nil
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if !casMatch(current, exists, old) {
+			return nil
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, new, 0)
+			return nil
+		})
+		if err == nil {
+			swapped = true
+		}
+		return err
+	}, key)
+	if err == redis.TxFailedErr {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to compare and swap in redis: %w", err)
+	}
+	return swapped, nil
+}
+
+// Incr 使用 INCRBY 原子自增
+func (s *RedisMetadataStore) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	val, err := s.client.IncrBy(ctx, key, delta).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to incr in redis: %w", err)
+	}
+	return val, nil
+}
+
+// Close 关闭Redis连接
+func (s *RedisMetadataStore) Close() error {
+	return s.client.Close()
+}
+
+// parseRedisOptions 将 Metadate.data 解析为 go-redis 的 UniversalOptions
+// 未指定 mode 时：设置了 master_name 为 sentinel，addrs 多于一个为 cluster，否则为单节点
+func parseRedisOptions(data map[string]interface{}) (*redis.UniversalOptions, error) {
+	for _, key := range []string{"url", "mode", "host", "master_name", "username", "password", "sentinel_username", "sentinel_password"} {
+		if err := optionalString(data, key); err != nil {
+			return nil, err
+		}
+	}
+	str := func(key string) string {
+		value, _ := data[key].(string)
+		return value
+	}
+	opts := &redis.UniversalOptions{}
+
+	addrs, err := toStringList(data, "addrs")
+	if err != nil {
+		return nil, err
+	}
+	hasHost := data["host"] != nil || data["port"] != nil
+	switch {
+	case str("url") != "":
+		if hasHost || len(addrs) > 0 {
+			return nil, fmt.Errorf("url cannot be combined with host, port or addrs")
+		}
+		parsed, err := redis.ParseURL(str("url"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid url: %w", err)
+		}
+		opts.Addrs = []string{parsed.Addr}
+		opts.Username = parsed.Username
+		opts.Password = parsed.Password
+		opts.DB = parsed.DB
+		opts.TLSConfig = parsed.TLSConfig
+	case len(addrs) > 0:
+		if hasHost {
+			return nil, fmt.Errorf("addrs cannot be combined with host or port")
+		}
+		opts.Addrs = addrs
+	default:
+		host := str("host")
+		if host == "" {
+			host = "localhost"
+		}
+		port := 6379
+		if value, ok := data["port"]; ok {
+			p, err := toInt(value)
+			if err != nil || p < 1 || p > 65535 {
+				return nil, fmt.Errorf("port must be an integer between 1 and 65535, got %v", value)
+			}
+			port = p
+		}
+		opts.Addrs = []string{net.JoinHostPort(host, strconv.Itoa(port))}
+	}
+
+	if value, ok := data["db"]; ok {
+		db, err := toInt(value)
+		if err != nil || db < 0 {
+			return nil, fmt.Errorf("db must be a non-negative integer, got %v", value)
+		}
+		opts.DB = db
+	}
+	if username := str("username"); username != "" {
+		opts.Username = username
+	}
+	if password := str("password"); password != "" {
+		opts.Password = password
+	}
+	opts.MasterName = str("master_name")
+	opts.SentinelUsername = str("sentinel_username")
+	opts.SentinelPassword = str("sentinel_password")
+
+	mode := strings.ToLower(str("mode"))
+	if mode == "" {
+		switch {
+		case opts.MasterName != "":
+			mode = RedisModeSentinel
+		case len(opts.Addrs) > 1:
+			mode = RedisModeCluster
+		default:
+			mode = RedisModeSingle
+		}
+	}
+	switch mode {
+	case RedisModeSingle:
+		if len(opts.Addrs) > 1 {
+			return nil, fmt.Errorf("single mode accepts exactly one address, got %d", len(opts.Addrs))
+		}
+		if opts.MasterName != "" {
+			return nil, fmt.Errorf("master_name is only valid in sentinel mode")
+		}
+	case RedisModeSentinel:
+		if opts.MasterName == "" {
+			return nil, fmt.Errorf("sentinel mode requires master_name")
+		}
+	case RedisModeCluster:
+		if opts.MasterName != "" {
+			return nil, fmt.Errorf("master_name is only valid in sentinel mode")
+		}
+		if opts.DB != 0 {
+			return nil, fmt.Errorf("cluster mode only supports db 0, got %d", opts.DB)
+		}
+		opts.IsClusterMode = true
+	default:
+		return nil, fmt.Errorf("mode must be one of single, sentinel, cluster, got %q", mode)
+	}
+
+	for key, target := range map[string]*int{
+		"pool_size":      &opts.PoolSize,
+		"min_idle_conns": &opts.MinIdleConns,
+		"max_retries":    &opts.MaxRetries,
+	} {
+		value, ok := data[key]
+		if !ok {
+			continue
+		}
+		n, err := toInt(value)
+		// max_retries 为 -1 表示不重试
+		if err != nil || n < 0 && !(key == "max_retries" && n == -1) {
+			return nil, fmt.Errorf("%s must be a non-negative integer, got %v", key, value)
+		}
+		*target = n
+	}
+
+	for key, target := range map[string]*time.Duration{
+		"dial_timeout":  &opts.DialTimeout,
+		"read_timeout":  &opts.ReadTimeout,
+		"write_timeout": &opts.WriteTimeout,
+		"pool_timeout":  &opts.PoolTimeout,
+	} {
+		if err := optionalDuration(data, key); err != nil {
+			return nil, err
+		}
+		*target = parseDurationOption(data, key, 0)
+	}
+
+	tlsConfig, err := parseMetadataTLS(data["tls"])
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		opts.TLSConfig = tlsConfig
+	}
+	return opts, nil
+}
+
+// toStringList 读取字符串列表，支持 YAML 列表或逗号分隔的字符串
+func toStringList(data map[string]interface{}, key string) ([]string, error) {
+	var result []string
+	switch value := data[key].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	case []string:
+		result = value
+	case []interface{}:
+		for _, item := range value {
+			str, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must be a list of strings, got element %T", key, item)
+			}
+			result = append(result, str)
+		}
+	default:
+		return nil, fmt.Errorf("%s must be a list of strings, got %T", key, value)
+	}
+	return result, nil
+}
+
+// escapeRedisPattern 转义redis glob模式中的特殊字符
+func escapeRedisPattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
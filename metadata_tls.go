@@ -0,0 +1,102 @@
+package pipelinex
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// parseMetadataTLS 解析 Metadate.data.tls 配置
+// 值为 true 时使用系统根证书，为 map 时按 MetadataTLSConfig 的字段解析，未配置时返回 nil
+func parseMetadataTLS(value interface{}) (*tls.Config, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case bool:
+		if !v {
+			return nil, nil
+		}
+		return &tls.Config{MinVersion: tls.VersionTLS12}, nil
+	}
+
+	m, ok := toInterfaceMap(value)
+	if !ok {
+		return nil, fmt.Errorf("tls must be a bool or a map, got %T", value)
+	}
+	cfg := MetadataTLSConfig{}
+	for key, target := range map[string]*string{
+		"ca_file":     &cfg.CAFile,
+		"cert_file":   &cfg.CertFile,
+		"key_file":    &cfg.KeyFile,
+		"server_name": &cfg.ServerName,
+	} {
+		if err := optionalString(m, key); err != nil {
+			return nil, fmt.Errorf("tls.%w", err)
+		}
+		if str, ok := m[key].(string); ok {
+			*target = str
+		}
+	}
+	if v, ok := m["insecure_skip_verify"]; ok && v != nil {
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("tls.insecure_skip_verify must be a bool, got %T", v)
+		}
+		cfg.InsecureSkipVerify = b
+	}
+	return buildTLSConfig(cfg)
+}
+
+// buildTLSConfig 根据配置加载CA证书和客户端证书
+func buildTLSConfig(cfg MetadataTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls.ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls.ca_file: no certificates found in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, fmt.Errorf("tls.cert_file and tls.key_file must be set together")
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// toInterfaceMap 将 YAML 解析出的 map 转换为 map[string]interface{}
+func toInterfaceMap(value interface{}) (map[string]interface{}, bool) {
+	switch m := value.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			key, ok := k.(string)
+			if !ok {
+				return nil, false
+			}
+			result[key] = v
+		}
+		return result, true
+	default:
+		return nil, false
+	}
+}
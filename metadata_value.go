@@ -0,0 +1,160 @@
+package pipelinex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// 通过 SetMetadataValue 写入的值以 metadataValuePrefix 加 JSON 编码保存，字符串、数字、布尔、列表和 map 都可以无损往返
+// 没有前缀的值（Set 写入、外部工具写入、步骤输出）始终按原始字符串处理，不会因为恰好是合法 JSON 而改变类型
+
+// metadataValuePrefix 带类型的元数据值的前缀
+const metadataValuePrefix = "pipelinex:json:"
+
+// nativeMetadataReader 由保存了 Metadate.data 初始数据的存储实现
+// 初始数据在 Get 中按原始字符串返回，这里返回配置中的原生类型值；key 被改写后不再返回
+type nativeMetadataReader interface {
+	nativeMetadataValue(key string) (any, bool)
+}
+
+// EncodeMetadataValue 将值编码为存储中带类型前缀的 JSON 字符串
+func EncodeMetadataValue(value any) (string, error) {
+	data, err := json.Marshal(normalizeValue(value))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode metadata value: %w", err)
+	}
+	return metadataValuePrefix + string(data), nil
+}
+
+// DecodeMetadataValue 将 EncodeMetadataValue 编码的字符串解码为原生类型
+// 整数解码为 int64，小数解码为 float64；没有类型前缀或无法解码的值原样作为字符串返回
+func DecodeMetadataValue(raw string) any {
+	if !strings.HasPrefix(raw, metadataValuePrefix) {
+		return raw
+	}
+	value, ok := decodeJSONValue(strings.TrimPrefix(raw, metadataValuePrefix))
+	if !ok {
+		return raw
+	}
+	return value
+}
+
+// GetMetadataValue 获取元数据并解码为原生类型
+func GetMetadataValue(ctx context.Context, store MetadataStore, key string) (any, error) {
+	raw, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return decodeStoredValue(store, key, raw), nil
+}
+
+// SetMetadataValue 将值编码为带类型前缀的 JSON 后写入元数据
+func SetMetadataValue(ctx context.Context, store MetadataStore, key string, value any) error {
+	raw, err := EncodeMetadataValue(value)
+	if err != nil {
+		return err
+	}
+	return store.Set(ctx, key, raw)
+}
+
+// decodeStoredValue 返回存储中 key 的原生类型值，配置中的初始数据优先使用原生类型
+func decodeStoredValue(store MetadataStore, key string, raw string) any {
+	if reader, ok := store.(nativeMetadataReader); ok {
+		if value, ok := reader.nativeMetadataValue(key); ok {
+			return value
+		}
+	}
+	return DecodeMetadataValue(raw)
+}
+
+// seedMetadataData 转换配置中的初始数据，用于 in-config 与 memory 存储
+// 返回 Get 使用的原始字符串（字符串原样保存，列表和 map 保存为 JSON，其他标量按 %v 格式化）以及非字符串值的原生类型
+func seedMetadataData(data map[string]interface{}) (map[string]string, map[string]any, error) {
+	raw := make(map[string]string, len(data))
+	native := make(map[string]any)
+	for key, value := range data {
+		if str, ok := value.(string); ok {
+			raw[key] = str
+			continue
+		}
+		normalized := normalizeValue(value)
+		encoded, err := json.Marshal(normalized)
+		if err != nil {
+			return nil, nil, fmt.Errorf("key %s: failed to encode metadata value: %w", key, err)
+		}
+		switch normalized.(type) {
+		case map[string]any, []any:
+			raw[key] = string(encoded)
+		default:
+			raw[key] = fmt.Sprintf("%v", value)
+		}
+		if decoded, ok := decodeJSONValue(string(encoded)); ok {
+			native[key] = decoded
+		}
+	}
+	return raw, native, nil
+}
+
+// decodeJSONValue 按 JSON 解码，数字转换为 int64 或 float64
+func decodeJSONValue(data string) (any, bool) {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return nil, false
+	}
+	return convertNumbers(value), true
+}
+
+// normalizeValue 将 yaml.v2 解析出的 map[interface{}]interface{} 递归转换为 map[string]any
+func normalizeValue(value any) any {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]any, len(v))
+		for k, item := range v {
+			result[fmt.Sprintf("%v", k)] = normalizeValue(item)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]any, len(v))
+		for k, item := range v {
+			result[k] = normalizeValue(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]any, len(v))
+		for i, item := range v {
+			result[i] = normalizeValue(item)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// convertNumbers 将 json.Number 递归转换为 int64 或 float64
+func convertNumbers(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for k, item := range v {
+			v[k] = convertNumbers(item)
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = convertNumbers(item)
+		}
+		return v
+	default:
+		return value
+	}
+}
@@ -7,6 +7,8 @@ type Node interface {
 	PipelineId() string
 	//Status 获取节点状态
 	Status() string
+	//SetStatus 设置节点状态，流水线执行时更新
+	SetStatus(status string)
 	//Get 获取节点属性数据
 	Get(key string) string
 	// Set 设置节点属性数据
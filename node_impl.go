@@ -1,6 +1,8 @@
 package pipelinex
 
 import (
+	"sync"
+
 	"github.com/spf13/cast"
 	"github.com/thoas/go-funk"
 )
@@ -10,6 +12,7 @@ type DGANode struct {
 	state      string
 	property   map[string]any
 	pipelineId string
+	mu         sync.RWMutex
 }
 
 // NewDGANode creates a new DGANode with the specified id and state, initializing an empty property map.
@@ -30,9 +33,17 @@ func (dgaNode *DGANode) PipelineId() string {
 }
 
 func (dgaNode *DGANode) Status() string {
+	dgaNode.mu.RLock()
+	defer dgaNode.mu.RUnlock()
 	return dgaNode.state
 }
 
+func (dgaNode *DGANode) SetStatus(status string) {
+	dgaNode.mu.Lock()
+	defer dgaNode.mu.Unlock()
+	dgaNode.state = status
+}
+
 func (dgaNode *DGANode) Get(key string) string {
 	return cast.ToString(funk.Get(dgaNode.property, key))
 }
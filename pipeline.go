@@ -11,6 +11,8 @@ var (
 	PipelineExecutorPrepareDone Event = EventPipelineExecutorPrepareDone // 流水线执行器准备完毕
 	PipelineNodeStart           Event = EventPipelineNodeStart           // 节点开始
 	PipelineNodeFinish          Event = EventPipelineNodeFinish          // 节点完成
+	PipelineGroupStart          Event = EventPipelineGroupStart          // 节点组开始
+	PipelineGroupFinish         Event = EventPipelineGroupFinish         // 节点组完成
 )
 
 type TraversalFn func(ctx context.Context, node Node) error
@@ -21,6 +23,16 @@ type Graph interface {
 	AddVertex(node Node)
 	//AddEdge 添加边
 	AddEdge(edge Edge) error
+	//AddGroup 添加节点组
+	AddGroup(group NodeGroup)
+	//AddEntry 标记入口节点（[*] --> X）
+	AddEntry(node Node)
+	//AddTerminal 标记终止节点（X --> [*]）
+	AddTerminal(node Node)
+	//RemoveVertex 删除顶点及其所有的边
+	RemoveVertex(node Node) error
+	//RemoveEdge 删除一条边，同一对节点之间的其他并行边保留
+	RemoveEdge(edge Edge) error
 }
 
 type GraphReader interface {
@@ -28,10 +40,26 @@ type GraphReader interface {
 	Nodes() map[string]Node
 	//Edges 返回所有的边
 	Edges() []Edge
+	//Groups 返回所有的节点组
+	Groups() map[string]NodeGroup
+	//Entries 返回排序后的入口节点ID，为空时入度为0的节点都是入口
+	Entries() []string
+	//Terminals 返回排序后的终止节点ID
+	Terminals() []string
 	//Traversal 遍历图结构
 	Traversal(ctx context.Context, evalCtx EvaluationContext, fn TraversalFn) error
 }
 
+// NodeGroup 节点组，对应状态图中的复合状态
+// 进入组时开始执行 Entries 中的节点，离开组的边等待 Exits 中的节点完成
+type NodeGroup struct {
+	Name    string   `json:"name"`
+	Parent  string   `json:"parent,omitempty"`  // 外层组的名称，顶层组为空
+	Nodes   []string `json:"nodes"`             // 组内的全部节点，包括嵌套组中的节点
+	Entries []string `json:"entries,omitempty"` // 组内 [*] 的后继节点
+	Exits   []string `json:"exits,omitempty"`   // 组内转换到 [*] 的节点
+}
+
 // 流水线事件
 type Event string
 
@@ -44,6 +72,12 @@ type Listener interface {
 	Events() []Event
 }
 
+// GroupListener 可选接口，监听器实现后会收到节点组的状态变化
+// status 为 StatusRunning、StatusSuccess、StatusFailed 或 StatusCancelled
+type GroupListener interface {
+	HandleGroup(p Pipeline, group NodeGroup, status string)
+}
+
 // PipelineListeningFn 流水线监听函数
 type ListeningFn func(p Pipeline)
 type Metadata map[string]any
@@ -61,6 +95,14 @@ type Pipeline interface {
 	SetMetadata(store MetadataStore)
 	//Metadata 获取元数据
 	Metadata() Metadata
+	//SetPusher 设置日志推送器
+	SetPusher(pusher Pusher)
+	//SetConfig 设置流水线配置，节点的步骤和执行器从配置中读取
+	SetConfig(config *PipelineConfig)
+	//SetStepRunner 设置步骤执行器，未设置时节点只模拟执行
+	SetStepRunner(runner StepRunner)
+	//SetTemplateEngine 设置模板引擎，用于在执行前渲染步骤命令和节点配置
+	SetTemplateEngine(engine TemplateEngine)
 	//Listening 流水线执行事件监听设置
 	Listening(listener Listener)
 	//Done流水线是否执行完成
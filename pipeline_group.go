@@ -0,0 +1,270 @@
+package pipelinex
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// groupChange 节点组的一次状态变化
+type groupChange struct {
+	group  NodeGroup
+	status string
+}
+
+// groupTracker 跟踪流水线执行过程中节点组的状态
+// 组内第一个节点开始时组开始运行；Exits 中的节点都成功后组成功（没有 Exits 时为组内全部节点）；
+// 组内节点失败时组失败；流水线结束时仍在运行的组按流水线的结果结束
+type groupTracker struct {
+	groups    map[string]NodeGroup
+	nodeGroup map[string][]string // 节点 -> 所属的组，外层在前
+	depth     map[string]int      // 组的嵌套深度，顶层为0
+	status    map[string]string
+	succeeded map[string]bool
+	mu        sync.Mutex
+}
+
+// newGroupTracker 创建节点组状态跟踪器，没有节点组时返回nil
+func newGroupTracker(groups map[string]NodeGroup) *groupTracker {
+	if len(groups) == 0 {
+		return nil
+	}
+
+	t := &groupTracker{
+		groups:    groups,
+		nodeGroup: make(map[string][]string),
+		depth:     make(map[string]int, len(groups)),
+		status:    make(map[string]string, len(groups)),
+		succeeded: make(map[string]bool),
+	}
+	for name, group := range groups {
+		for parent := group.Parent; parent != ""; parent = groups[parent].Parent {
+			t.depth[name]++
+		}
+		for _, node := range group.Nodes {
+			t.nodeGroup[node] = append(t.nodeGroup[node], name)
+		}
+	}
+	for _, names := range t.nodeGroup {
+		sort.Slice(names, func(i, j int) bool { return t.depth[names[i]] < t.depth[names[j]] })
+	}
+	return t
+}
+
+// nodeStarted 节点开始时启动其所在的、尚未开始的组，外层组先开始
+func (t *groupTracker) nodeStarted(node string) []groupChange {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var changes []groupChange
+	for _, name := range t.nodeGroup[node] {
+		if t.status[name] == "" {
+			changes = append(changes, t.set(name, StatusRunning))
+		}
+	}
+	return changes
+}
+
+// nodeFinished 节点结束时更新其所在组的状态，内层组先结束
+func (t *groupTracker) nodeFinished(node string, err error) []groupChange {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err == nil {
+		t.succeeded[node] = true
+	}
+
+	var changes []groupChange
+	names := t.nodeGroup[node]
+	for i := len(names) - 1; i >= 0; i-- {
+		name := names[i]
+		if t.status[name] != StatusRunning {
+			continue
+		}
+		switch {
+		case err != nil:
+			changes = append(changes, t.set(name, failureStatus(err)))
+		case t.completed(t.groups[name]):
+			changes = append(changes, t.set(name, StatusSuccess))
+		}
+	}
+	return changes
+}
+
+// finish 流水线结束时结束仍在运行的组，内层组先结束
+func (t *groupTracker) finish(err error) []groupChange {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var running []string
+	for name, status := range t.status {
+		if status == StatusRunning {
+			running = append(running, name)
+		}
+	}
+	sort.Slice(running, func(i, j int) bool {
+		if t.depth[running[i]] != t.depth[running[j]] {
+			return t.depth[running[i]] > t.depth[running[j]]
+		}
+		return running[i] < running[j]
+	})
+
+	status := StatusSuccess
+	if err != nil {
+		status = failureStatus(err)
+	}
+	changes := make([]groupChange, len(running))
+	for i, name := range running {
+		changes[i] = t.set(name, status)
+	}
+	return changes
+}
+
+// groupStatus 返回组的当前状态，尚未开始的组返回空字符串
+func (t *groupTracker) groupStatus(name string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status[name]
+}
+
+// completed 判断组的出口节点是否都已成功
+func (t *groupTracker) completed(group NodeGroup) bool {
+	exits := group.Exits
+	if len(exits) == 0 {
+		exits = group.Nodes
+	}
+	for _, node := range exits {
+		if !t.succeeded[node] {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *groupTracker) set(name, status string) groupChange {
+	t.status[name] = status
+	return groupChange{group: t.groups[name], status: status}
+}
+
+// inheritGroupConfig 从节点所在的最内层组开始逐层向外继承组配置
+// executor、image 只在节点未设置时继承，Config 按 key 合并，内层的值优先
+func inheritGroupConfig(config NodeConfig, node string, groups map[string]NodeGroup, groupConfigs map[string]GroupConfig) NodeConfig {
+	innermost, depth := "", -1
+	for name, group := range groups {
+		if !containsString(group.Nodes, node) {
+			continue
+		}
+		d := 0
+		for parent := group.Parent; parent != ""; parent = groups[parent].Parent {
+			d++
+		}
+		if d > depth {
+			innermost, depth = name, d
+		}
+	}
+
+	merged := false
+	for name := innermost; name != ""; name = groups[name].Parent {
+		group, ok := groupConfigs[name]
+		if !ok {
+			continue
+		}
+		if config.Executor == "" {
+			config.Executor = group.Executor
+		}
+		if config.Image == "" {
+			config.Image = group.Image
+		}
+		for key, value := range group.Config {
+			if _, exists := config.Config[key]; exists {
+				continue
+			}
+			if !merged {
+				// 复制节点的 Config，避免修改流水线配置
+				copied := make(map[string]interface{}, len(config.Config)+len(group.Config))
+				for k, v := range config.Config {
+					copied[k] = v
+				}
+				config.Config = copied
+				merged = true
+			}
+			config.Config[key] = value
+		}
+	}
+	return config
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// removeString 返回删除 value 后的新切片，不修改 values
+func removeString(values []string, value string) []string {
+	if !containsString(values, value) {
+		return values
+	}
+	result := make([]string, 0, len(values)-1)
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// failureStatus 取消导致的失败返回 StatusCancelled，其余返回 StatusFailed
+func failureStatus(err error) string {
+	if errors.Is(err, context.Canceled) {
+		return StatusCancelled
+	}
+	return StatusFailed
+}
+
+// GroupStatus 返回节点组的状态，尚未开始或不存在的组返回空字符串
+func (p *PipelineImpl) GroupStatus(name string) string {
+	p.mu.RLock()
+	tracker := p.groups
+	p.mu.RUnlock()
+
+	if tracker == nil {
+		return ""
+	}
+	return tracker.groupStatus(name)
+}
+
+// notifyGroups 通知监听器节点组的状态变化
+func (p *PipelineImpl) notifyGroups(changes []groupChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	p.mu.RLock()
+	listener := p.listener
+	p.mu.RUnlock()
+
+	for _, change := range changes {
+		event := PipelineGroupFinish
+		if change.status == StatusRunning {
+			event = PipelineGroupStart
+		}
+		if groupListener, ok := listener.(GroupListener); ok {
+			groupListener.HandleGroup(p, change.group, change.status)
+		}
+		p.notifyEvent(event)
+	}
+}
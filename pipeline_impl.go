@@ -3,6 +3,7 @@ package pipelinex
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,22 +17,23 @@ var _ Graph = (*DGAGraph)(nil)
 
 // 保存了流水线的图结构
 type DGAGraph struct {
-	mu       sync.RWMutex
-	nodes    map[string]Node
-	edges    map[string]Edge            // edgeID -> Edge
-	graph    map[string][]string        // src -> [dest1, dest2, ...] (保持兼容性)
-	edgeMap  map[string]map[string]Edge // src -> dest -> Edge (快速查找)
-	sequence []string
-	hasCycle bool
+	mu        sync.RWMutex
+	nodes     map[string]Node
+	graph     map[string][]string          // src -> [dest1, dest2, ...]，每对节点只出现一次
+	edgeMap   map[string]map[string][]Edge // src -> dest -> 并行边（按添加顺序）
+	groups    map[string]NodeGroup
+	entries   map[string]bool
+	terminals map[string]bool
 }
 
 func NewDGAGraph() *DGAGraph {
 	return &DGAGraph{
-		nodes:    map[string]Node{},
-		edges:    map[string]Edge{},
-		graph:    map[string][]string{},
-		edgeMap:  map[string]map[string]Edge{},
-		sequence: []string{},
+		nodes:     map[string]Node{},
+		graph:     map[string][]string{},
+		edgeMap:   map[string]map[string][]Edge{},
+		groups:    map[string]NodeGroup{},
+		entries:   map[string]bool{},
+		terminals: map[string]bool{},
 	}
 }
 
@@ -44,28 +46,80 @@ func (dga *DGAGraph) Nodes() map[string]Node {
 	}).(map[string]Node)
 }
 
-// Edges 返回所有的边
+// Edges 返回所有的边，同一对节点之间的并行边按添加顺序相邻
 func (dga *DGAGraph) Edges() []Edge {
 	dga.mu.RLock()
 	defer dga.mu.RUnlock()
-	edges := make([]Edge, 0, len(dga.edges))
-	for _, edge := range dga.edges {
-		edges = append(edges, edge)
+	var edges []Edge
+	for _, targets := range dga.edgeMap {
+		for _, parallel := range targets {
+			edges = append(edges, parallel...)
+		}
 	}
 	return edges
 }
 
+// Groups 返回所有的节点组
+func (dga *DGAGraph) Groups() map[string]NodeGroup {
+	dga.mu.RLock()
+	defer dga.mu.RUnlock()
+	groups := make(map[string]NodeGroup, len(dga.groups))
+	for name, group := range dga.groups {
+		groups[name] = group
+	}
+	return groups
+}
+
+// AddGroup 向图中添加节点组
+func (dga *DGAGraph) AddGroup(group NodeGroup) {
+	dga.mu.Lock()
+	defer dga.mu.Unlock()
+	dga.groups[group.Name] = group
+}
+
+// Entries 返回排序后的入口节点ID
+func (dga *DGAGraph) Entries() []string {
+	dga.mu.RLock()
+	defer dga.mu.RUnlock()
+	return sortedKeys(dga.entries)
+}
+
+// AddEntry 标记入口节点，标记后遍历只从入口节点开始
+func (dga *DGAGraph) AddEntry(node Node) {
+	dga.mu.Lock()
+	defer dga.mu.Unlock()
+	dga.entries[node.Id()] = true
+}
+
+// Terminals 返回排序后的终止节点ID
+func (dga *DGAGraph) Terminals() []string {
+	dga.mu.RLock()
+	defer dga.mu.RUnlock()
+	return sortedKeys(dga.terminals)
+}
+
+// AddTerminal 标记终止节点，标记后遍历结束时至少要有一个终止节点执行成功
+func (dga *DGAGraph) AddTerminal(node Node) {
+	dga.mu.Lock()
+	defer dga.mu.Unlock()
+	dga.terminals[node.Id()] = true
+}
+
 // AddVertex 向图中添加顶点（节点）
-// 检查是否存在循环；如果存在循环，则返回 ErrHasCycle
-// 否则返回 nil
+// 顶点已存在时替换节点，保留已有的边
 func (dga *DGAGraph) AddVertex(node Node) {
 	dga.mu.Lock()
 	defer dga.mu.Unlock()
 	dga.nodes[node.Id()] = node
-	dga.graph[node.Id()] = []string{}
+	if _, ok := dga.graph[node.Id()]; !ok {
+		dga.graph[node.Id()] = []string{}
+	}
 }
 
 // AddEdge 向图中添加边
+// 添加前检查目标节点能否到达源节点，能到达时这条边会形成环，不添加并返回 *CycleError
+// 只搜索目标节点的下游，构建图的总开销与逐条全量检查相比从 O(E·(V+E)) 降到实际可达的范围
+// 同一对节点之间可以有多条并行边，遍历时任意一条边满足条件即可，目标节点只等待源节点一次
 func (dga *DGAGraph) AddEdge(edge Edge) error {
 	dga.mu.Lock()
 	defer dga.mu.Unlock()
@@ -79,30 +133,117 @@ func (dga *DGAGraph) AddEdge(edge Edge) error {
 	if _, ok := dga.nodes[dest.Id()]; !ok {
 		return fmt.Errorf("dest vertex %s not found", dest.Id())
 	}
+	if path := dga.path(dest.Id(), src.Id()); path != nil {
+		return &CycleError{Path: append([]string{src.Id()}, path...)}
+	}
 
-	// 添加到edges映射
-	dga.edges[edge.ID()] = edge
+	if dga.edgeMap[src.Id()] == nil {
+		dga.edgeMap[src.Id()] = make(map[string][]Edge)
+	}
+	// 第一条边才加入邻接表，并行边不重复计算入度
+	if len(dga.edgeMap[src.Id()][dest.Id()]) == 0 {
+		dga.graph[src.Id()] = append(dga.graph[src.Id()], dest.Id())
+	}
+	dga.edgeMap[src.Id()][dest.Id()] = append(dga.edgeMap[src.Id()][dest.Id()], edge)
+	return nil
+}
 
-	// 添加到graph映射（保持兼容性）
-	dga.graph[src.Id()] = append(dga.graph[src.Id()], dest.Id())
+// RemoveEdge 从图中删除一条边
+// 优先删除同一个 Edge，没有时删除同一对节点之间表达式相同的第一条并行边；找不到时返回 ErrEdgeNotFound
+func (dga *DGAGraph) RemoveEdge(edge Edge) error {
+	dga.mu.Lock()
+	defer dga.mu.Unlock()
 
-	// 添加到edgeMap（快速查找）
-	if dga.edgeMap[src.Id()] == nil {
-		dga.edgeMap[src.Id()] = make(map[string]Edge)
+	src, dest := edge.Source().Id(), edge.Target().Id()
+	parallel := dga.edgeMap[src][dest]
+	index := -1
+	for i, e := range parallel {
+		if e == edge {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		for i, e := range parallel {
+			if e.Expression() == edge.Expression() {
+				index = i
+				break
+			}
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("%w: %s", ErrEdgeNotFound, edge.ID())
 	}
-	dga.edgeMap[src.Id()][dest.Id()] = edge
 
-	dga.hasCycle = dga.cycleCheck()
-	if dga.hasCycle {
-		return ErrHasCycle
+	parallel = append(parallel[:index:index], parallel[index+1:]...)
+	if len(parallel) > 0 {
+		dga.edgeMap[src][dest] = parallel
+		return nil
+	}
+	dga.removePair(src, dest)
+	return nil
+}
+
+// RemoveVertex 从图中删除顶点及其所有的边，同时取消入口、终止标记并从节点组中移除
+// 顶点不存在时返回 ErrVertexNotFound
+func (dga *DGAGraph) RemoveVertex(node Node) error {
+	dga.mu.Lock()
+	defer dga.mu.Unlock()
+
+	id := node.Id()
+	if _, ok := dga.nodes[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrVertexNotFound, id)
+	}
+	for src := range dga.edgeMap {
+		if _, ok := dga.edgeMap[src][id]; ok {
+			dga.removePair(src, id)
+		}
+	}
+	delete(dga.nodes, id)
+	delete(dga.graph, id)
+	delete(dga.edgeMap, id)
+	delete(dga.entries, id)
+	delete(dga.terminals, id)
+	for name, group := range dga.groups {
+		group.Nodes = removeString(group.Nodes, id)
+		group.Entries = removeString(group.Entries, id)
+		group.Exits = removeString(group.Exits, id)
+		dga.groups[name] = group
 	}
 	return nil
 }
 
+// removePair 删除两个节点之间的全部并行边，调用方需持有写锁
+func (dga *DGAGraph) removePair(src, dest string) {
+	delete(dga.edgeMap[src], dest)
+	if len(dga.edgeMap[src]) == 0 {
+		delete(dga.edgeMap, src)
+	}
+	dga.graph[src] = removeString(dga.graph[src], dest)
+}
+
+// pairPasses 判断两个节点之间的并行边是否可以遍历：任意一条边无条件或条件满足即可，调用方需持有锁
+func (dga *DGAGraph) pairPasses(src, dest string, evalCtx EvaluationContext) (bool, error) {
+	for _, edge := range dga.edgeMap[src][dest] {
+		if edge.Expression() == "" {
+			return true, nil
+		}
+		result, err := edge.Evaluate(evalCtx)
+		if err != nil {
+			return false, err
+		}
+		if result {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // Traversal 对DAG执行广度优先遍历
 // 为图中的每个节点执行提供的 TraversalFn 函数
 // 支持多个起始节点并发执行
 // 支持条件边：如果边有表达式，会评估表达式决定是否遍历该边
+// 标记了入口节点时只从入口节点开始；标记了终止节点时，没有任何终止节点执行则返回 ErrNoTerminal
 func (dga *DGAGraph) Traversal(ctx context.Context, evalCtx EvaluationContext, fn TraversalFn) error {
 	dga.mu.RLock()
 	defer dga.mu.RUnlock()
@@ -115,10 +256,10 @@ func (dga *DGAGraph) Traversal(ctx context.Context, evalCtx EvaluationContext, f
 	// 计算所有节点的入度（基于原始图结构）
 	indeg := dga.getIndegrees()
 
-	// 收集所有入度为0的起始节点
+	// 收集所有入度为0的起始节点，标记了入口时只收集入口节点
 	startNodes := make([]string, 0)
 	for v, d := range indeg {
-		if d == 0 {
+		if d == 0 && (len(dga.entries) == 0 || dga.entries[v]) {
 			startNodes = append(startNodes, v)
 		}
 	}
@@ -174,15 +315,11 @@ func (dga *DGAGraph) Traversal(ctx context.Context, evalCtx EvaluationContext, f
 				continue
 			}
 
-			// 获取边并评估条件
-			shouldTraverse := true
-			if edge, ok := dga.edgeMap[vertexFocus][neighbor]; ok && edge.Expression() != "" {
-				result, err := edge.Evaluate(evalCtx)
-				if err != nil {
-					return fmt.Errorf("failed to evaluate edge condition %s->%s: %w",
-						vertexFocus, neighbor, err)
-				}
-				shouldTraverse = result
+			// 评估并行边的条件，任意一条满足即可
+			shouldTraverse, err := dga.pairPasses(vertexFocus, neighbor, evalCtx)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate edge condition %s->%s: %w",
+					vertexFocus, neighbor, err)
 			}
 
 			// 条件不满足，跳过此边（不减少入度）
@@ -220,32 +357,16 @@ func (dga *DGAGraph) Traversal(ctx context.Context, evalCtx EvaluationContext, f
 		}
 	}
 
-	return nil
-}
-
-// cycleCheck 检查有向无环图（DAG）中是否存在循环
-// 如果找到循环则返回 true，否则返回 false
-func (dga *DGAGraph) cycleCheck() bool {
-	indeg := dga.getIndegrees()
-	q := make([]string, 0)
-	for v, d := range indeg {
-		if d == 0 {
-			q = append(q, v)
-		}
-	}
-	visited := 0
-	for len(q) > 0 {
-		v := q[0]
-		q = q[1:]
-		visited++
-		for _, n := range dga.graph[v] {
-			indeg[n]--
-			if indeg[n] == 0 {
-				q = append(q, n)
+	// 条件边跳过了所有通往终止节点的路径
+	if len(dga.terminals) > 0 {
+		for id := range dga.terminals {
+			if visited[id] {
+				return nil
 			}
 		}
+		return fmt.Errorf("%w: %s", ErrNoTerminal, strings.Join(sortedKeys(dga.terminals), ", "))
 	}
-	return visited != len(dga.nodes)
+	return nil
 }
 
 // getIndegrees 计算所有节点的入度
@@ -264,23 +385,30 @@ func (dga *DGAGraph) getIndegrees() map[string]int {
 }
 
 // HasCycle 检查图中是否存在循环
+// AddEdge 会拒绝形成环的边，通过 AddEdge 构建的图总是返回 false
 func (dga *DGAGraph) HasCycle() bool {
 	dga.mu.RLock()
 	defer dga.mu.RUnlock()
-	return dga.hasCycle
+	return dga.findCycle() != nil
 }
 
 type PipelineImpl struct {
-	id             string
-	graph          Graph
-	status         string
-	metadata       Metadata
-	metadataStore  MetadataStore
-	listening      ListeningFn
-	listener       Listener
-	doneChan       <-chan struct{}
-	cancelFunc     context.CancelFunc
-	mu             sync.RWMutex
+	id            string
+	graph         Graph
+	status        string
+	config        *PipelineConfig
+	metadata      Metadata
+	metadataStore MetadataStore
+	outputs       map[string]map[string]string // 节点ID -> 步骤输出
+	pusher        Pusher
+	stepRunner    StepRunner
+	engine        TemplateEngine
+	listening     ListeningFn
+	listener      Listener
+	groups        *groupTracker
+	doneChan      <-chan struct{}
+	cancelFunc    context.CancelFunc
+	mu            sync.RWMutex
 }
 
 func NewPipeline(ctx context.Context) Pipeline {
@@ -320,28 +448,108 @@ func (p *PipelineImpl) SetMetadata(store MetadataStore) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.metadataStore = store
+	p.metadata = nil
 }
 
 // Metadata 获取流水线的元数据
+// 返回最近一次从 metadataStore 加载的快照，尚未加载时会先加载一次
 func (p *PipelineImpl) Metadata() Metadata {
 	p.mu.RLock()
-	defer p.mu.RUnlock()
+	metadata := p.metadata
+	p.mu.RUnlock()
 
-	if p.metadata == nil {
-		p.metadata = make(Metadata)
+	if metadata == nil {
+		loaded, err := p.loadMetadata(context.Background())
+		if err != nil {
+			fmt.Printf("Pipeline %s load metadata failed: %v\n", p.id, err)
+		}
+		metadata = loaded
 	}
 
-	// 如果有 metadataStore，从 store 加载数据
-	if p.metadataStore != nil {
-		// 从 InConfigMetadataStore 加载所有数据
-		if inConfigStore, ok := p.metadataStore.(*InConfigMetadataStore); ok {
-			for k, v := range inConfigStore.data {
-				p.metadata[k] = v
-			}
+	result := make(Metadata, len(metadata))
+	for k, v := range metadata {
+		result[k] = v
+	}
+	return result
+}
+
+// loadMetadata 从 metadataStore 加载全部元数据并解码为原生类型，更新快照
+func (p *PipelineImpl) loadMetadata(ctx context.Context) (Metadata, error) {
+	p.mu.RLock()
+	store := p.metadataStore
+	p.mu.RUnlock()
+
+	metadata := make(Metadata)
+	if store != nil {
+		keys, err := store.List(ctx, "")
+		if err != nil {
+			return metadata, fmt.Errorf("failed to list metadata: %w", err)
+		}
+		values, err := store.GetMany(ctx, keys)
+		if err != nil {
+			return metadata, fmt.Errorf("failed to get metadata: %w", err)
+		}
+		for k, v := range values {
+			metadata[k] = decodeStoredValue(store, k, v)
 		}
 	}
 
-	return p.metadata
+	p.mu.Lock()
+	p.metadata = metadata
+	p.mu.Unlock()
+	return metadata, nil
+}
+
+// SetPusher 设置流水线的日志推送器
+func (p *PipelineImpl) SetPusher(pusher Pusher) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pusher = pusher
+}
+
+// SetConfig 设置流水线配置
+func (p *PipelineImpl) SetConfig(config *PipelineConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.config = config
+}
+
+// SetStepRunner 设置步骤执行器
+func (p *PipelineImpl) SetStepRunner(runner StepRunner) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stepRunner = runner
+}
+
+// SetTemplateEngine 设置模板引擎
+func (p *PipelineImpl) SetTemplateEngine(engine TemplateEngine) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.engine = engine
+}
+
+// templateEngine 返回当前使用的模板引擎，未设置时使用默认的Pongo2模板引擎
+func (p *PipelineImpl) templateEngine() TemplateEngine {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.engine == nil {
+		return defaultTemplateEngine
+	}
+	return p.engine
+}
+
+// nodeConfig 返回节点的配置，节点位于复合状态中时合并 Groups 中的组配置
+func (p *PipelineImpl) nodeConfig(id string) (NodeConfig, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.config == nil {
+		return NodeConfig{}, false
+	}
+	config, ok := p.config.Nodes[id]
+	if !ok || len(p.config.Groups) == 0 || p.graph == nil {
+		return config, ok
+	}
+	return inheritGroupConfig(config, id, p.graph.Groups(), p.config.Groups), true
 }
 
 // Listening 设置流水线执行事件监听器
@@ -363,6 +571,8 @@ func (p *PipelineImpl) Run(ctx context.Context) error {
 	p.mu.Lock()
 	ctx, cancel := context.WithCancel(ctx)
 	p.cancelFunc = cancel
+	p.groups = newGroupTracker(p.graph.Groups())
+	groups := p.groups
 	p.mu.Unlock()
 
 	done := make(chan struct{})
@@ -381,24 +591,24 @@ func (p *PipelineImpl) Run(ctx context.Context) error {
 	// 创建求值上下文
 	evalCtx := NewEvaluationContext().WithPipeline(p)
 
-	// 如果有元数据存储，加载数据到求值上下文
-	if p.metadataStore != nil {
-		params := make(map[string]any)
-		// 这里可以根据需求加载特定的元数据键
-		// 目前加载所有配置中的数据（仅in-config类型适用）
-		if inConfigStore, ok := p.metadataStore.(*InConfigMetadataStore); ok {
-			for key := range inConfigStore.data {
-				if val, err := inConfigStore.Get(ctx, key); err == nil {
-					params[key] = val
-				}
-			}
-		}
-		if len(params) > 0 {
-			evalCtx = evalCtx.WithParams(params)
-		}
+	// 从元数据存储加载数据到求值上下文，所有存储类型均适用
+	metadata, err := p.loadMetadata(ctx)
+	if err != nil {
+		p.notifyEvent(PipelineFinish)
+		return err
+	}
+	if len(metadata) > 0 {
+		evalCtx = evalCtx.WithParams(metadata)
+	}
+
+	// 配置中的 Param 以 Param.xxx 的形式引用
+	p.mu.RLock()
+	if p.config != nil && p.config.Param != nil {
+		evalCtx = evalCtx.WithParams(map[string]any{"Param": normalizeValue(p.config.Param)})
 	}
+	p.mu.RUnlock()
 
-	err := p.graph.Traversal(ctx, evalCtx, func(ctx context.Context, node Node) error {
+	err = p.graph.Traversal(ctx, evalCtx, func(ctx context.Context, node Node) (err error) {
 		// 检查context是否已取消
 		select {
 		case <-ctx.Done():
@@ -407,26 +617,55 @@ func (p *PipelineImpl) Run(ctx context.Context) error {
 		}
 
 		// 通知节点开始
+		node.SetStatus(StatusRunning)
 		p.notifyEvent(PipelineNodeStart)
 		fmt.Println(node.Id())
-
-		// 模拟工作执行，检查context取消
-		for i := 0; i < 10; i++ {
-			select {
-			case <-ctx.Done():
-				fmt.Printf("Pipeline cancelled for node %s\n", node.Id())
-				return ctx.Err()
-			case <-time.After(100 * time.Millisecond):
-				// 继续执行
+		p.pushLog(ctx, node.Id(), LevelInfo, "node started")
+		defer p.nodeDone(node.Id())
+		p.notifyGroups(groups.nodeStarted(node.Id()))
+		defer func() {
+			if err != nil {
+				node.SetStatus(failureStatus(err))
+			}
+			p.notifyGroups(groups.nodeFinished(node.Id(), err))
+		}()
+
+		p.mu.RLock()
+		runner := p.stepRunner
+		p.mu.RUnlock()
+
+		if config, ok := p.nodeConfig(node.Id()); ok && runner != nil && len(config.Steps) > 0 {
+			if err := p.runSteps(ctx, evalCtx, node, config, runner); err != nil {
+				if ctx.Err() != nil {
+					p.pushLog(context.WithoutCancel(ctx), node.Id(), LevelWarn, "node cancelled")
+					return ctx.Err()
+				}
+				p.pushLog(ctx, node.Id(), LevelError, err.Error())
+				return err
+			}
+		} else {
+			// 模拟工作执行，检查context取消
+			for i := 0; i < 10; i++ {
+				select {
+				case <-ctx.Done():
+					fmt.Printf("Pipeline cancelled for node %s\n", node.Id())
+					p.pushLog(context.WithoutCancel(ctx), node.Id(), LevelWarn, "node cancelled")
+					return ctx.Err()
+				case <-time.After(100 * time.Millisecond):
+					// 继续执行
+				}
 			}
 		}
 
 		// 通知节点完成
+		p.pushLog(ctx, node.Id(), LevelInfo, "node finished")
+		node.SetStatus(StatusSuccess)
 		p.notifyEvent(PipelineNodeFinish)
 		return nil
 	})
 
-	// 通知流水线完成
+	// 结束仍在运行的节点组，通知流水线完成
+	p.notifyGroups(groups.finish(err))
 	p.notifyEvent(PipelineFinish)
 	return err
 }
@@ -473,6 +712,37 @@ func (p *PipelineImpl) Cancel() {
 	}
 }
 
+// pushLog 推送节点日志，推送失败不影响流水线执行
+func (p *PipelineImpl) pushLog(ctx context.Context, node string, level Level, message string) {
+	p.mu.RLock()
+	pusher := p.pusher
+	p.mu.RUnlock()
+
+	if pusher == nil {
+		return
+	}
+	if err := pusher.Push(ctx, Entry{
+		Pipeline:  p.id,
+		Node:      node,
+		Timestamp: time.Now(),
+		Level:     level,
+		Message:   message,
+	}); err != nil {
+		fmt.Printf("Pipeline %s push log failed: %v\n", p.id, err)
+	}
+}
+
+// nodeDone 通知日志流节点已结束，结束该节点的日志订阅
+func (p *PipelineImpl) nodeDone(node string) {
+	p.mu.RLock()
+	pusher := p.pusher
+	p.mu.RUnlock()
+
+	if stream, ok := pusher.(LogStream); ok {
+		stream.NodeDone(node)
+	}
+}
+
 // notifyEvent 通知监听器特定事件
 func (p *PipelineImpl) notifyEvent(event Event) {
 	p.mu.RLock()
@@ -0,0 +1,259 @@
+package pipelinex
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 预检查ProgressRenderer是否实现了Listener和GroupListener接口
+var (
+	_ Listener      = (*ProgressRenderer)(nil)
+	_ GroupListener = (*ProgressRenderer)(nil)
+)
+
+// progressGlyphs 终端中各节点状态的符号
+var progressGlyphs = map[string]string{
+	StatusRunning:   "●",
+	StatusSuccess:   "✔",
+	StatusFailed:    "✘",
+	StatusCancelled: "⊘",
+	StatusTerminate: "⊘",
+	StatusPaused:    "‖",
+}
+
+// ProgressRenderer 在终端中显示流水线的执行进度，作为 Listener 传给 RunSync/RunAsync 使用
+// 输出到终端时按拓扑层绘制所有节点的状态和耗时，每个事件重绘一次；
+// 输出不是终端时（重定向到文件、CI 日志）每次状态变化输出一行
+type ProgressRenderer struct {
+	out         io.Writer
+	interactive bool
+	now         func() time.Time
+	started     time.Time
+	status      map[string]string
+	startedAt   map[string]time.Time
+	finishedAt  map[string]time.Time
+	lines       int // 上次绘制的行数，重绘时先清除
+	mu          sync.Mutex
+}
+
+// NewProgressRenderer 创建进度显示，out 为终端时使用重绘模式
+func NewProgressRenderer(out io.Writer) *ProgressRenderer {
+	return &ProgressRenderer{
+		out:         out,
+		interactive: isTerminal(out),
+		now:         time.Now,
+		status:      map[string]string{},
+		startedAt:   map[string]time.Time{},
+		finishedAt:  map[string]time.Time{},
+	}
+}
+
+// SetInteractive 指定是否使用重绘模式，覆盖自动检测的结果
+func (r *ProgressRenderer) SetInteractive(interactive bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactive = interactive
+}
+
+// Events 返回监听的事件
+func (r *ProgressRenderer) Events() []Event {
+	return []Event{
+		PipelineStart,
+		PipelineFinish,
+		PipelineNodeStart,
+		PipelineNodeFinish,
+		PipelineGroupStart,
+		PipelineGroupFinish,
+		EventPipelineCancelled,
+		EventPipelineStatusUpdate,
+	}
+}
+
+// Handle 比较节点状态的变化并更新显示
+func (r *ProgressRenderer) Handle(p Pipeline, event Event) {
+	graph := p.GetGraph()
+	if graph == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	if event == PipelineStart || r.started.IsZero() {
+		r.reset(now)
+	}
+
+	nodes := graph.Nodes()
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		status := nodes[id].Status()
+		if r.status[id] == status {
+			continue
+		}
+		r.status[id] = status
+		switch {
+		case status == StatusRunning:
+			r.startedAt[id] = now
+		case progressGlyphs[status] != "":
+			r.finishedAt[id] = now
+		}
+		// 未开始的节点（UNKNOWN 等）不算一次状态变化
+		if !r.interactive && progressGlyphs[status] != "" {
+			fmt.Fprintf(r.out, "[%s] %s %s %s%s\n", formatElapsed(now.Sub(r.started)), progressGlyph(status), id, status, r.nodeElapsed(id, now, " "))
+		}
+	}
+
+	switch {
+	case r.interactive:
+		r.draw(p, graph, now, event == PipelineFinish)
+	case event == PipelineFinish:
+		fmt.Fprintf(r.out, "[%s] pipeline %s finished\n", formatElapsed(now.Sub(r.started)), p.Id())
+	}
+}
+
+// HandleGroup 非重绘模式下输出节点组的状态变化，重绘模式下节点组不单独显示
+func (r *ProgressRenderer) HandleGroup(p Pipeline, group NodeGroup, status string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.interactive || r.started.IsZero() {
+		return
+	}
+	fmt.Fprintf(r.out, "[%s] %s group %s %s\n", formatElapsed(r.now().Sub(r.started)), progressGlyph(status), group.Name, status)
+}
+
+// reset 开始新的流水线，之前绘制的内容保留在屏幕上
+func (r *ProgressRenderer) reset(now time.Time) {
+	r.started = now
+	r.status = map[string]string{}
+	r.startedAt = map[string]time.Time{}
+	r.finishedAt = map[string]time.Time{}
+	r.lines = 0
+}
+
+// draw 清除上次绘制的内容，按拓扑层重新绘制所有节点
+func (r *ProgressRenderer) draw(p Pipeline, graph GraphReader, now time.Time, finished bool) {
+	if r.lines > 0 {
+		fmt.Fprintf(r.out, "\033[%dA\033[J", r.lines)
+	}
+
+	state := "running"
+	if finished {
+		state = "finished"
+	}
+	lines := []string{fmt.Sprintf("pipeline %s %s %s", p.Id(), state, formatElapsed(now.Sub(r.started)))}
+	for i, layer := range progressLayers(graph) {
+		parts := make([]string, len(layer))
+		for j, id := range layer {
+			parts[j] = progressGlyph(r.status[id]) + " " + id + r.nodeElapsed(id, now, " ")
+		}
+		lines = append(lines, fmt.Sprintf("%3d  %s", i+1, strings.Join(parts, "   ")))
+	}
+	fmt.Fprintln(r.out, strings.Join(lines, "\n"))
+	r.lines = len(lines)
+}
+
+// nodeElapsed 返回节点的耗时，未开始的节点返回空字符串
+func (r *ProgressRenderer) nodeElapsed(id string, now time.Time, prefix string) string {
+	start, ok := r.startedAt[id]
+	if !ok {
+		return ""
+	}
+	if end, ok := r.finishedAt[id]; ok && !end.Before(start) {
+		now = end
+	}
+	return prefix + formatElapsed(now.Sub(start))
+}
+
+// progressLayers 按拓扑层分组节点，节点所在的层为从入度为0的节点出发的最长路径长度
+// 存在环时剩余的节点放在最后一层
+func progressLayers(graph GraphReader) [][]string {
+	nodes := graph.Nodes()
+	indeg := make(map[string]int, len(nodes))
+	successors := make(map[string][]string)
+	for id := range nodes {
+		indeg[id] = 0
+	}
+	for _, edge := range graph.Edges() {
+		source, target := edge.Source().Id(), edge.Target().Id()
+		successors[source] = append(successors[source], target)
+		indeg[target]++
+	}
+
+	level := make(map[string]int, len(nodes))
+	var queue []string
+	for id, d := range indeg {
+		if d == 0 {
+			queue = append(queue, id)
+		}
+	}
+	depth := 0
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if level[id] > depth {
+			depth = level[id]
+		}
+		for _, next := range successors[id] {
+			if level[id]+1 > level[next] {
+				level[next] = level[id] + 1
+			}
+			indeg[next]--
+			if indeg[next] == 0 {
+				queue = append(queue, next)
+			}
+		}
+	}
+	for id, d := range indeg {
+		if d > 0 {
+			level[id] = depth + 1
+		}
+	}
+
+	var layers [][]string
+	for id := range nodes {
+		for len(layers) <= level[id] {
+			layers = append(layers, nil)
+		}
+		layers[level[id]] = append(layers[level[id]], id)
+	}
+	for _, layer := range layers {
+		sort.Strings(layer)
+	}
+	return layers
+}
+
+// progressGlyph 返回状态对应的符号，未开始的节点为 ○
+func progressGlyph(status string) string {
+	if glyph, ok := progressGlyphs[status]; ok {
+		return glyph
+	}
+	return "○"
+}
+
+// formatElapsed 格式化耗时，精确到0.1秒
+func formatElapsed(d time.Duration) string {
+	return d.Round(100 * time.Millisecond).String()
+}
+
+// isTerminal 判断输出是否为终端
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
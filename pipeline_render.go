@@ -0,0 +1,77 @@
+package pipelinex
+
+import (
+	"fmt"
+	"strings"
+)
+
+// templateData 构造渲染节点时使用的模板数据
+// 包含求值上下文中的 Param 和元数据、当前节点信息，以及 nodes.{节点ID}.outputs 形式的上游节点输出
+func (p *PipelineImpl) templateData(evalCtx EvaluationContext, node Node) map[string]any {
+	p.mu.RLock()
+	nodes := make(map[string]any, len(p.outputs))
+	for id, outputs := range p.outputs {
+		values := make(map[string]any, len(outputs))
+		for k, v := range outputs {
+			values[k] = v
+		}
+		nodes[id] = map[string]any{"outputs": values}
+	}
+	p.mu.RUnlock()
+
+	return evalCtx.WithNode(node).WithParams(map[string]any{"nodes": nodes}).All()
+}
+
+// renderNodeConfig 渲染节点的镜像和配置，步骤命令在执行前单独渲染
+func renderNodeConfig(engine TemplateEngine, config NodeConfig, data map[string]any) (NodeConfig, error) {
+	image, err := renderTemplate(engine, config.Image, data)
+	if err != nil {
+		return config, fmt.Errorf("failed to render image: %w", err)
+	}
+	config.Image = image
+
+	if config.Config != nil {
+		rendered, err := renderValue(engine, config.Config, data)
+		if err != nil {
+			return config, fmt.Errorf("failed to render config: %w", err)
+		}
+		config.Config = rendered.(map[string]any)
+	}
+	return config, nil
+}
+
+// renderValue 递归渲染配置中的字符串，map 会被转换为 map[string]any
+func renderValue(engine TemplateEngine, value any, data map[string]any) (any, error) {
+	switch v := normalizeValue(value).(type) {
+	case string:
+		return renderTemplate(engine, v, data)
+	case map[string]any:
+		for k, item := range v {
+			rendered, err := renderValue(engine, item, data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+			v[k] = rendered
+		}
+		return v, nil
+	case []any:
+		for i, item := range v {
+			rendered, err := renderValue(engine, item, data)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			v[i] = rendered
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
+
+// renderTemplate 渲染包含 {{ 或 {% 标记的字符串，其余字符串原样返回
+func renderTemplate(engine TemplateEngine, text string, data map[string]any) (string, error) {
+	if !strings.Contains(text, "{{") && !strings.Contains(text, "{%") {
+		return text, nil
+	}
+	return engine.EvaluateString(text, data)
+}
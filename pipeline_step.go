@@ -0,0 +1,271 @@
+package pipelinex
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// StepOutputEnv 步骤输出文件路径的环境变量，步骤向该文件写入 key=value 行记录输出
+	StepOutputEnv = "PIPELINEX_OUTPUT"
+	// StepOutputMarker 日志中的输出标记，例如 ::set-metadata version=1.2.3
+	StepOutputMarker = "::set-metadata "
+)
+
+// outputKeyPattern 步骤输出 key 的合法格式
+var outputKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-]*$`)
+
+// ParseStepOutputs 解析步骤输出文件
+// 每行一个 key=value；多行值使用 key<<EOF 开始，单独一行 EOF 结束；空行和 # 开头的行会被忽略
+func ParseStepOutputs(data []byte) (map[string]string, error) {
+	outputs := map[string]string{}
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if key, delimiter, ok := strings.Cut(line, "<<"); ok && !strings.Contains(key, "=") {
+			if !outputKeyPattern.MatchString(key) {
+				return nil, fmt.Errorf("line %d: invalid output key %q", i+1, key)
+			}
+			if delimiter == "" {
+				return nil, fmt.Errorf("line %d: empty delimiter for key %s", i+1, key)
+			}
+			end := i + 1
+			for end < len(lines) && lines[end] != delimiter {
+				end++
+			}
+			if end == len(lines) {
+				return nil, fmt.Errorf("line %d: missing delimiter %q for key %s", i+1, delimiter, key)
+			}
+			outputs[key] = strings.Join(lines[i+1:end], "\n")
+			i = end
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key=value, got %q", i+1, line)
+		}
+		if !outputKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("line %d: invalid output key %q", i+1, key)
+		}
+		outputs[key] = value
+	}
+	return outputs, nil
+}
+
+// ParseStepOutputMarker 解析日志中的输出标记，不是输出标记时 ok 为 false
+func ParseStepOutputMarker(line string) (key string, value string, ok bool) {
+	rest, found := strings.CutPrefix(strings.TrimSpace(line), StepOutputMarker)
+	if !found {
+		return "", "", false
+	}
+	key, value, found = strings.Cut(rest, "=")
+	if !found || !outputKeyPattern.MatchString(key) {
+		return "", "", false
+	}
+	return key, value, true
+}
+
+// runSteps 依次执行节点的步骤，每个步骤结束后保存其输出
+// 节点的镜像和配置在节点开始时渲染，步骤命令在该步骤执行前渲染，因此可以引用前面步骤的输出
+func (p *PipelineImpl) runSteps(ctx context.Context, evalCtx EvaluationContext, node Node, config NodeConfig, runner StepRunner) error {
+	p.mu.RLock()
+	executor := p.config.Executors[config.Executor]
+	p.mu.RUnlock()
+
+	engine := p.templateEngine()
+	config, err := renderNodeConfig(engine, config, p.templateData(evalCtx, node))
+	if err != nil {
+		return fmt.Errorf("node %s: %w", node.Id(), err)
+	}
+
+	for _, step := range config.Steps {
+		step.Run, err = renderTemplate(engine, step.Run, p.templateData(evalCtx, node))
+		if err != nil {
+			return fmt.Errorf("node %s step %s: failed to render run: %w", node.Id(), step.Name, err)
+		}
+
+		outputs, err := p.runStep(ctx, node, StepExecution{
+			Pipeline: p.id,
+			Node:     node.Id(),
+			Step:     step,
+			Image:    config.Image,
+			Executor: executor,
+			Config:   config.Config,
+		}, runner)
+		if err != nil {
+			return fmt.Errorf("node %s step %s failed: %w", node.Id(), step.Name, err)
+		}
+		if err := p.saveOutputs(ctx, node, outputs); err != nil {
+			return fmt.Errorf("node %s step %s: %w", node.Id(), step.Name, err)
+		}
+	}
+	return nil
+}
+
+// runStep 执行单个步骤，返回输出文件和日志标记中记录的输出，输出文件中的值优先
+func (p *PipelineImpl) runStep(ctx context.Context, node Node, execution StepExecution, runner StepRunner) (map[string]string, error) {
+	file, err := os.CreateTemp("", "pipelinex-output-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	path := file.Name()
+	file.Close()
+	defer os.Remove(path)
+
+	execution.Env = map[string]string{
+		StepOutputEnv:        path,
+		"PIPELINEX_PIPELINE": execution.Pipeline,
+		"PIPELINEX_NODE":     execution.Node,
+		"PIPELINEX_STEP":     execution.Step.Name,
+	}
+
+	outputs := map[string]string{}
+	writer := newLineWriter(func(line string) {
+		if key, value, ok := ParseStepOutputMarker(line); ok {
+			outputs[key] = value
+			return
+		}
+		p.pushStepOutput(ctx, node.Id(), execution.Step.Name, line)
+	})
+	err = runner.RunStep(ctx, execution, writer)
+	writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output file: %w", err)
+	}
+	fileOutputs, err := ParseStepOutputs(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output file: %w", err)
+	}
+	for k, v := range fileOutputs {
+		outputs[k] = v
+	}
+	return outputs, nil
+}
+
+// saveOutputs 将步骤输出写入元数据存储，并更新元数据快照和节点输出，供后续的边和节点使用
+func (p *PipelineImpl) saveOutputs(ctx context.Context, node Node, outputs map[string]string) error {
+	if len(outputs) == 0 {
+		return nil
+	}
+
+	p.mu.RLock()
+	store := p.metadataStore
+	p.mu.RUnlock()
+
+	// 输出是字符串，按原始值保存，外部工具读到的值与步骤写出的一致
+	if store != nil {
+		if err := store.SetMany(ctx, outputs); err != nil {
+			return fmt.Errorf("failed to save step outputs: %w", err)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// 快照可能正在被读取，复制后替换
+	metadata := make(Metadata, len(p.metadata)+len(outputs))
+	for k, v := range p.metadata {
+		metadata[k] = v
+	}
+	nodeOutputs := make(map[string]string, len(p.outputs[node.Id()])+len(outputs))
+	for k, v := range p.outputs[node.Id()] {
+		nodeOutputs[k] = v
+	}
+	for k, v := range outputs {
+		metadata[k] = v
+		nodeOutputs[k] = v
+	}
+	p.metadata = metadata
+	if p.outputs == nil {
+		p.outputs = map[string]map[string]string{}
+	}
+	p.outputs[node.Id()] = nodeOutputs
+	return nil
+}
+
+// Outputs 返回节点的步骤已记录的输出
+func (p *PipelineImpl) Outputs(node string) map[string]string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	result := make(map[string]string, len(p.outputs[node]))
+	for k, v := range p.outputs[node] {
+		result[k] = v
+	}
+	return result
+}
+
+// pushStepOutput 推送步骤输出的一行
+func (p *PipelineImpl) pushStepOutput(ctx context.Context, node string, step string, line string) {
+	p.mu.RLock()
+	pusher := p.pusher
+	p.mu.RUnlock()
+
+	if pusher == nil {
+		return
+	}
+	if err := pusher.Push(ctx, Entry{
+		Pipeline: p.id,
+		Node:     node,
+		Step:     step,
+		Level:    LevelInfo,
+		Output:   line,
+	}); err != nil {
+		fmt.Printf("Pipeline %s push log failed: %v\n", p.id, err)
+	}
+}
+
+// lineWriter 按行切分写入的数据，Close 时处理最后不完整的一行
+type lineWriter struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	onLine func(line string)
+}
+
+var _ io.WriteCloser = (*lineWriter)(nil)
+
+func newLineWriter(onLine func(line string)) *lineWriter {
+	return &lineWriter{onLine: onLine}
+}
+
+// Write 写入数据，每遇到一个换行符回调一次
+func (w *lineWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(data)
+	for {
+		line, err := w.buf.ReadString('\n')
+		if err != nil {
+			// 不完整的一行放回缓冲区
+			w.buf.Reset()
+			w.buf.WriteString(line)
+			return len(data), nil
+		}
+		w.onLine(strings.TrimRight(line, "\r\n"))
+	}
+}
+
+// Close 处理缓冲区中剩余的数据
+func (w *lineWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.buf.Len() > 0 {
+		w.onLine(strings.TrimRight(w.buf.String(), "\r\n"))
+		w.buf.Reset()
+	}
+	return nil
+}
@@ -28,4 +28,14 @@ type Runtime interface {
 	SetPusher(pusher Pusher)
 	// 设置模板引擎
 	SetTemplateEngine(engine TemplateEngine)
+	// 设置步骤执行器，未设置时节点只模拟执行
+	SetStepRunner(runner StepRunner)
+	// 设置元数据存储工厂，用于支持自定义的 Metadate.type
+	SetMetadataStoreFactory(factory MetadataStoreFactory)
+	// 订阅流水线日志，先回放已产生的日志再持续跟随
+	// node 为空时订阅所有节点直到流水线结束，否则只订阅该节点直到节点结束
+	// 流水线结束后日志只保留一段时间，之后订阅返回错误
+	SubscribeLogs(ctx context.Context, id string, node string) (<-chan Entry, error)
+	// 检查配置中的模板表达式，返回所有引用了未定义变量的位置
+	Lint(config string) ([]LintIssue, error)
 }
@@ -0,0 +1,344 @@
+package pipelinex
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GraphError Graph 定义中的错误
+// Line 和 Column 从 1 开始计数，行号相对于 Graph 的第一行（图类型声明）
+// 与具体位置无关的错误（例如未被引用的节点）Line 和 Column 为 0
+type GraphError struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *GraphError) Error() string {
+	if e.Line == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *GraphError) Unwrap() error {
+	return e.Err
+}
+
+// BuildGraph 构建图结构
+// 图语法错误、引用未定义的节点、未被图引用的节点、环以及条件表达式的编译错误合并后返回，
+// 出错时仍然返回已构建的部分图，便于调用方检查
+func (r *RuntimeImpl) BuildGraph(config *PipelineConfig) (Graph, error) {
+	graph := NewDGAGraph()
+	engine, err := r.conditionEngine(config)
+	if err != nil {
+		return graph, err
+	}
+
+	// 创建节点
+	nodeMap := make(map[string]Node)
+	for nodeName := range config.Nodes {
+		node := NewDGANode(nodeName, StatusUnknown)
+		nodeMap[nodeName] = node
+		graph.AddVertex(node)
+	}
+
+	// 解析图关系并添加边，未定义 Graph 时节点之间没有依赖
+	if strings.TrimSpace(config.Graph) != "" {
+		if err := r.parseGraphEdges(graph, nodeMap, config, engine); err != nil {
+			return graph, err
+		}
+	} else if errs := checkGroupConfig(config, nil); len(errs) > 0 {
+		return graph, joinGraphErrors(errs)
+	}
+
+	return graph, nil
+}
+
+// graphTerminal Mermaid 中表示开始和结束的伪状态
+const graphTerminal = "[*]"
+
+// graphNodeRef Graph 中对节点的一次引用
+type graphNodeRef struct {
+	Name   string
+	Line   int
+	Column int
+}
+
+// graphTransition Graph 中的一条转换，起点或终点可以是 [*]
+type graphTransition struct {
+	From        graphNodeRef
+	To          graphNodeRef
+	Label       string
+	LabelColumn int
+	Line        int
+	Scope       string // 转换所在的复合状态，顶层为空
+}
+
+// stateDiagram 中的伪状态类型
+const (
+	pseudoFork   = "fork"
+	pseudoJoin   = "join"
+	pseudoChoice = "choice"
+)
+
+// choiceElse choice 分支的默认标签，其他分支的条件都不满足时通过
+const choiceElse = "else"
+
+// graphPseudoState fork/join/choice 伪状态，不对应 Nodes 中的节点
+type graphPseudoState struct {
+	Kind string
+	Ref  graphNodeRef
+}
+
+// graphGroup stateDiagram 中的复合状态
+type graphGroup struct {
+	Ref    graphNodeRef
+	Parent string
+}
+
+// graphDefinition 从 Graph 中解析出的转换，与具体的图语法无关
+type graphDefinition struct {
+	transitions []graphTransition
+	mentions    []graphNodeRef // 注释、节点定义等不产生边的引用
+	pseudo      map[string]graphPseudoState
+	groups      map[string]graphGroup
+	errs        []*GraphError
+}
+
+// graphEdge 展开伪状态后两个节点（或 [*]）之间的边
+// From 的位置为路径第一段转换的位置
+type graphEdge struct {
+	From       graphNodeRef
+	To         graphNodeRef
+	Conditions []EdgeCondition
+}
+
+// parseGraphDefinition 按图类型声明选择解析器
+// 支持 stateDiagram / stateDiagram-v2 和 flowchart / graph 两种语法，
+// 无法识别类型声明时返回错误
+func parseGraphDefinition(source string) (*graphDefinition, error) {
+	lines := strings.Split(source, "\n")
+	header := strings.Fields(lines[0])
+	switch {
+	case len(header) > 0 && strings.HasPrefix(header[0], "stateDiagram"):
+		return parseStateGraph(source, lines)
+	case len(header) > 0 && (header[0] == "flowchart" || header[0] == "graph"):
+		return parseFlowchartGraph(lines)
+	default:
+		return nil, &GraphError{
+			Line:   1,
+			Column: indentColumn(lines[0]),
+			Err:    fmt.Errorf("%w: unknown diagram type %q, expected stateDiagram-v2, flowchart or graph", ErrGraphSyntax, strings.TrimSpace(lines[0])),
+		}
+	}
+}
+
+// parseGraphEdges 解析图边关系
+// 边标签中的条件表达式在此时编译并缓存，编译失败的表达式作为错误返回
+// 复合状态作为节点组添加到图中
+func (r *RuntimeImpl) parseGraphEdges(graph Graph, nodeMap map[string]Node, config *PipelineConfig, engine TemplateEngine) error {
+	def, err := parseGraphDefinition(config.Graph)
+	if err != nil {
+		return err
+	}
+
+	edges, groups, errs := resolveGraphEdges(def, nodeMap, engine)
+	errs = append(def.errs, errs...)
+	for _, group := range groups {
+		graph.AddGroup(group)
+	}
+	referenced := make(map[string]graphNodeRef) // 节点第一次出现的位置
+	successors := make(map[string][]string)
+	var entries []string
+
+	for _, e := range edges {
+		for _, ref := range []graphNodeRef{e.From, e.To} {
+			if _, ok := referenced[ref.Name]; !ok && ref.Name != graphTerminal {
+				referenced[ref.Name] = ref
+			}
+		}
+
+		switch {
+		case e.From.Name == graphTerminal && e.To.Name == graphTerminal:
+		case e.From.Name == graphTerminal:
+			// [*] --> X 标记入口节点，遍历时不评估条件，因此入口不能带条件
+			if len(e.Conditions) > 0 {
+				errs = append(errs, &GraphError{
+					Line:   e.From.Line,
+					Column: e.From.Column,
+					Err:    fmt.Errorf("%w: condition on entry transition [*]->%s is not supported", ErrInvalidGraph, e.To.Name),
+				})
+			}
+			graph.AddEntry(nodeMap[e.To.Name])
+			entries = append(entries, e.To.Name)
+		case e.To.Name == graphTerminal:
+			// X --> [*] 标记终止节点，节点执行成功即到达终点，边上的条件不影响结果
+			graph.AddTerminal(nodeMap[e.From.Name])
+		default:
+			successors[e.From.Name] = append(successors[e.From.Name], e.To.Name)
+
+			// 添加边关系（有条件表达式则创建条件边，与runtime共用模板引擎以复用编译缓存）
+			edge := newGraphEdge(nodeMap[e.From.Name], nodeMap[e.To.Name], e.Conditions, engine)
+			// 形成环的边不会加入图中，每个环只在闭合它的边上报告一次
+			if err := graph.AddEdge(edge); err != nil {
+				errs = append(errs, &GraphError{
+					Line:   e.From.Line,
+					Column: e.From.Column,
+					Err:    fmt.Errorf("edge %s->%s: %w", e.From.Name, e.To.Name, err),
+				})
+			}
+		}
+	}
+
+	// 声明了 [*] 入口时，从入口无法到达的节点不会执行，视为配置错误
+	if len(entries) > 0 {
+		reached := make(map[string]bool)
+		for queue := append([]string(nil), entries...); len(queue) > 0; queue = queue[1:] {
+			if reached[queue[0]] {
+				continue
+			}
+			reached[queue[0]] = true
+			queue = append(queue, successors[queue[0]]...)
+		}
+		for name, ref := range referenced {
+			if !reached[name] {
+				errs = append(errs, &GraphError{Line: ref.Line, Column: ref.Column, Err: fmt.Errorf("%w: %q", ErrUnreachableNode, name)})
+			}
+		}
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+		return errs[i].Column < errs[j].Column
+	})
+
+	errs = append(errs, checkGroupConfig(config, def.groups)...)
+
+	// 没有出现在任何转换中的节点不会按图的顺序执行，视为配置错误
+	names := make([]string, 0, len(nodeMap))
+	for name := range nodeMap {
+		if _, ok := referenced[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		errs = append(errs, &GraphError{Err: fmt.Errorf("%w: %q", ErrUnreferencedNode, name)})
+	}
+
+	return joinGraphErrors(errs)
+}
+
+// joinGraphErrors 合并多个图错误，没有错误时返回nil
+func joinGraphErrors(errs []*GraphError) error {
+	joined := make([]error, len(errs))
+	for i, err := range errs {
+		joined[i] = err
+	}
+	return errors.Join(joined...)
+}
+
+// checkGroupConfig 检查 Groups 中的每个组都是 Graph 中的复合状态
+func checkGroupConfig(config *PipelineConfig, groups map[string]graphGroup) []*GraphError {
+	names := make([]string, 0, len(config.Groups))
+	for name := range config.Groups {
+		if _, ok := groups[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	errs := make([]*GraphError, len(names))
+	for i, name := range names {
+		errs[i] = &GraphError{Err: fmt.Errorf("%w: group %q in Groups is not a composite state in Graph", ErrInvalidGraph, name)}
+	}
+	return errs
+}
+
+// newGraphEdge 按条件数量创建边，只有一个条件时与普通条件边相同
+func newGraphEdge(source, target Node, conditions []EdgeCondition, engine TemplateEngine) Edge {
+	switch {
+	case len(conditions) == 0:
+		return NewDGAEdge(source, target)
+	case len(conditions) == 1 && !conditions[0].Negate:
+		return NewConditionalEdgeWithEngine(source, target, conditions[0].Expression, engine)
+	default:
+		return NewGuardedEdge(source, target, conditions, engine)
+	}
+}
+
+// indentColumn 返回行中第一个非空白字符的列号
+func indentColumn(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t")) + 1
+}
+
+// conditionEngine 返回条件边使用的表达式引擎，由配置中的 Expression.engine 选择
+// cel 引擎的字符串渲染仍然使用runtime的模板引擎
+func (r *RuntimeImpl) conditionEngine(config *PipelineConfig) (TemplateEngine, error) {
+	engine := r.getTemplateEngine()
+	switch config.Expression.Engine {
+	case "", ExpressionEnginePongo2:
+		if len(config.Expression.Schema) > 0 {
+			return nil, fmt.Errorf("Expression.schema requires engine %q", ExpressionEngineCEL)
+		}
+		return engine, nil
+	case ExpressionEngineCEL:
+		return NewCELTemplateEngine(config.Expression.Schema, engine)
+	default:
+		return nil, fmt.Errorf("unknown expression engine %q", config.Expression.Engine)
+	}
+}
+
+// ExtractExpression 从边标签中提取条件表达式（公共函数供测试使用）
+// 使用模板引擎的 Validate 方法验证表达式语法
+// 先检查是否包含模板标记 {{ 或 {%，再使用模板引擎验证
+func ExtractExpression(label string) string {
+	if label == "" {
+		return ""
+	}
+
+	// 检查是否包含模板表达式标记 {{ 或 {%
+	if !strings.Contains(label, "{{") && !strings.Contains(label, "{%") {
+		return ""
+	}
+
+	// 使用模板引擎验证表达式语法
+	if err := defaultTemplateEngine.Validate(label); err == nil {
+		return label
+	}
+	return ""
+}
+
+// extractExpression 从边标签中提取条件表达式（内部使用）
+// 使用模板引擎的Validate方法验证并预编译表达式
+// 标签包含完整的 {{ }} 或 {% %} 标记却无法编译时返回错误，其余标签视为普通标签
+func extractExpression(engine TemplateEngine, label string) (string, error) {
+	if label == "" {
+		return "", nil
+	}
+
+	// 检查是否包含模板表达式标记 {{ 或 {%
+	if !strings.Contains(label, "{{") && !strings.Contains(label, "{%") {
+		return "", nil
+	}
+
+	err := engine.Validate(label)
+	if err == nil {
+		return label, nil
+	}
+	if isTemplateLabel(label) {
+		return "", err
+	}
+	return "", nil
+}
+
+// isTemplateLabel 判断标签是否包含成对的模板标记
+func isTemplateLabel(label string) bool {
+	return (strings.Contains(label, "{{") && strings.Contains(label, "}}")) ||
+		(strings.Contains(label, "{%") && strings.Contains(label, "%}"))
+}
@@ -3,42 +3,51 @@ package pipelinex
 import (
 	"context"
 	"fmt"
-	"strings"
 	"sync"
 	"time"
 
-	"github.com/tetrafolium/mermaid-check/ast"
-	"github.com/tetrafolium/mermaid-check/parser"
 	"gopkg.in/yaml.v2"
 )
 
 // 预检查RuntimeImpl是否实现了Runtime接口
 var _ Runtime = (*RuntimeImpl)(nil)
 
+// DefaultLogRetention 流水线结束后日志默认保留的时间
+const DefaultLogRetention = time.Hour
+
 // RuntimeImpl Runtime接口的实现
 type RuntimeImpl struct {
-	pipelines      map[string]Pipeline // 存储所有流水线
-	pipelineIds    map[string]bool     // 跟踪所有使用过的流水线ID
-	mu             sync.RWMutex        // 读写锁
-	ctx            context.Context     // 上下文
-	cancel         context.CancelFunc  // 取消函数
-	doneChan       chan struct{}       // 完成通道
-	background     chan struct{}       // 后台处理完成通道
-	pusher         Pusher              // 日志推送器
-	templateEngine TemplateEngine      // 模板引擎
+	pipelines       map[string]Pipeline  // 存储所有流水线
+	pipelineIds     map[string]bool      // 跟踪所有使用过的流水线ID
+	logStreams      map[string]LogStream // 流水线日志流
+	logFinished     map[string]time.Time // 日志流对应的流水线结束时间
+	logRetention    time.Duration        // 流水线结束后日志保留的时间，不大于0时一直保留
+	mu              sync.RWMutex         // 读写锁
+	ctx             context.Context      // 上下文
+	cancel          context.CancelFunc   // 取消函数
+	doneChan        chan struct{}        // 完成通道
+	background      chan struct{}        // 后台处理完成通道
+	pusher          Pusher               // 日志推送器
+	templateEngine  TemplateEngine       // 模板引擎
+	metadataFactory MetadataStoreFactory // 元数据存储工厂
+	stepRunner      StepRunner           // 步骤执行器
 }
 
 // NewRuntime 创建新的Runtime实例
 func NewRuntime(ctx context.Context) Runtime {
 	ctx, cancel := context.WithCancel(ctx)
 	return &RuntimeImpl{
-		pipelines:      make(map[string]Pipeline),
-		pipelineIds:    make(map[string]bool),
-		ctx:            ctx,
-		cancel:         cancel,
-		doneChan:       make(chan struct{}),
-		background:     make(chan struct{}),
-		templateEngine: NewPongo2TemplateEngine(), // 默认引擎
+		pipelines:       make(map[string]Pipeline),
+		pipelineIds:     make(map[string]bool),
+		logStreams:      make(map[string]LogStream),
+		logFinished:     make(map[string]time.Time),
+		logRetention:    DefaultLogRetention,
+		ctx:             ctx,
+		cancel:          cancel,
+		doneChan:        make(chan struct{}),
+		background:      make(chan struct{}),
+		templateEngine:  NewPongo2TemplateEngine(), // 默认引擎
+		metadataFactory: NewMetadataStoreFactory(),
 	}
 }
 
@@ -74,46 +83,19 @@ func (r *RuntimeImpl) Cancel(ctx context.Context, id string) error {
 
 // RunAsync 执行异步流水线
 func (r *RuntimeImpl) RunAsync(ctx context.Context, id string, config string, listener Listener) (Pipeline, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	// 检查是否已存在相同ID的流水线
-	if _, exists := r.pipelineIds[id]; exists {
-		return nil, fmt.Errorf("pipeline with id %s already exists", id)
-	}
-
-	// 解析配置
-	pipelineConfig, err := r.parseConfig(config)
+	pipeline, stream, store, err := r.preparePipeline(ctx, id, config, listener)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
-	}
-
-	// 创建流水线
-	pipeline := NewPipeline(ctx)
-
-	// 设置监听器
-	if listener != nil {
-		pipeline.Listening(listener)
-	}
-
-	// 构建图结构
-	graph := r.BuildGraph(pipelineConfig)
-	pipeline.SetGraph(graph)
-
-	// 设置metadata
-	if err := r.setupMetadata(ctx, pipeline, pipelineConfig); err != nil {
-		return nil, fmt.Errorf("failed to setup metadata: %w", err)
+		return nil, err
 	}
 
-	// 存储流水线并标记ID为已使用
-	r.pipelines[id] = pipeline
-	r.pipelineIds[id] = true
-
-	// 异步执行流水线
+	// 异步执行流水线，结束后关闭日志流和元数据存储
 	go func() {
 		defer func() {
+			stream.Close()
+			closeMetadataStore(store)
 			r.mu.Lock()
 			delete(r.pipelines, id)
+			r.finishLogsLocked(id)
 			r.mu.Unlock()
 		}()
 
@@ -127,50 +109,112 @@ func (r *RuntimeImpl) RunAsync(ctx context.Context, id string, config string, li
 
 // RunSync 执行同步流水线
 func (r *RuntimeImpl) RunSync(ctx context.Context, id string, config string, listener Listener) (Pipeline, error) {
+	pipeline, stream, store, err := r.preparePipeline(ctx, id, config, listener)
+	if err != nil {
+		return nil, err
+	}
+
+	// 执行期间不持有锁，便于其他协程获取流水线或订阅日志
+	err = pipeline.Run(ctx)
+	stream.Close()
+	closeMetadataStore(store)
+
+	// 清理已完成的流水线，但保留ID记录
 	r.mu.Lock()
-	defer r.mu.Unlock()
+	delete(r.pipelines, id)
+	r.finishLogsLocked(id)
+	r.mu.Unlock()
+
+	if err != nil {
+		return nil, fmt.Errorf("pipeline execution failed: %w", err)
+	}
+
+	return pipeline, nil
+}
+
+// closeMetadataStore 关闭流水线使用的元数据存储，释放连接和监听协程
+func closeMetadataStore(store MetadataStore) {
+	if store != nil {
+		store.Close()
+	}
+}
+
+// preparePipeline 解析配置并创建流水线，最后在持有写锁时登记
+// 构建图时需要读取模板引擎，因此解析和构建期间不持有锁
+// 返回的元数据存储由调用方在流水线执行结束后关闭，未配置元数据时为 nil
+func (r *RuntimeImpl) preparePipeline(ctx context.Context, id string, config string, listener Listener) (_ Pipeline, _ LogStream, _ MetadataStore, err error) {
+	r.mu.RLock()
+	_, exists := r.pipelineIds[id]
+	pusher := r.pusher
+	stepRunner := r.stepRunner
+	engine := r.templateEngine
+	r.mu.RUnlock()
 
 	// 检查是否已存在相同ID的流水线
-	if _, exists := r.pipelineIds[id]; exists {
-		return nil, fmt.Errorf("pipeline with id %s already exists", id)
+	if exists {
+		return nil, nil, nil, fmt.Errorf("pipeline with id %s already exists", id)
 	}
 
 	// 解析配置
 	pipelineConfig, err := r.parseConfig(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
 	// 创建流水线
 	pipeline := NewPipeline(ctx)
+	pipeline.SetConfig(pipelineConfig)
+	if stepRunner != nil {
+		pipeline.SetStepRunner(stepRunner)
+	}
+	if engine != nil {
+		pipeline.SetTemplateEngine(engine)
+	}
 
 	// 设置监听器
 	if listener != nil {
 		pipeline.Listening(listener)
 	}
 
-	// 构建图结构
-	graph := r.BuildGraph(pipelineConfig)
+	// 构建图结构，边上的条件表达式在此时预编译，语法错误在执行前返回
+	graph, err := r.BuildGraph(pipelineConfig)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to build graph: %w", err)
+	}
 	pipeline.SetGraph(graph)
 
-	// 设置metadata
-	if err := r.setupMetadata(ctx, pipeline, pipelineConfig); err != nil {
-		return nil, fmt.Errorf("failed to setup metadata: %w", err)
+	// 设置metadata，之后的步骤出错时关闭已打开的存储，避免连接和监听协程泄漏
+	store, err := r.setupMetadata(ctx, id, pipeline, pipelineConfig)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to setup metadata: %w", err)
 	}
+	defer func() {
+		if err != nil {
+			closeMetadataStore(store)
+		}
+	}()
 
-	// 存储流水线并标记ID为已使用
-	r.pipelines[id] = pipeline
-	r.pipelineIds[id] = true
+	// 设置日志流，日志同时转发给runtime的推送器
+	stream := NewLogBroker(id, pusher)
+	pipeline.SetPusher(stream)
 
-	err = pipeline.Run(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("pipeline execution failed: %w", err)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 解析期间可能有相同ID的流水线完成登记
+	if _, exists := r.pipelineIds[id]; exists {
+		return nil, nil, nil, fmt.Errorf("pipeline with id %s already exists", id)
 	}
 
-	// 清理已完成的流水线，但保留ID记录
-	delete(r.pipelines, id)
+	// 登记新流水线时顺便移除超过保留时间的日志，未启动后台处理时日志也不会无限增长
+	r.evictLogsLocked(time.Now())
 
-	return pipeline, nil
+	// 存储流水线并标记ID为已使用
+	r.pipelines[id] = pipeline
+	r.pipelineIds[id] = true
+	r.logStreams[id] = stream
+
+	return pipeline, stream, store, nil
 }
 
 // Rm 移除流水线记录
@@ -179,6 +223,35 @@ func (r *RuntimeImpl) Rm(id string) {
 	defer r.mu.Unlock()
 
 	delete(r.pipelines, id)
+	delete(r.logStreams, id)
+	delete(r.logFinished, id)
+}
+
+// SetLogRetention 设置流水线结束后日志保留的时间，不大于0时一直保留直到调用 Rm
+func (r *RuntimeImpl) SetLogRetention(retention time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logRetention = retention
+}
+
+// finishLogsLocked 记录流水线日志流的结束时间，调用方需持有写锁
+func (r *RuntimeImpl) finishLogsLocked(id string) {
+	if _, ok := r.logStreams[id]; ok {
+		r.logFinished[id] = time.Now()
+	}
+}
+
+// evictLogsLocked 移除结束时间超过保留时间的日志流，调用方需持有写锁
+func (r *RuntimeImpl) evictLogsLocked(now time.Time) {
+	if r.logRetention <= 0 {
+		return
+	}
+	for id, finished := range r.logFinished {
+		if now.Sub(finished) >= r.logRetention {
+			delete(r.logStreams, id)
+			delete(r.logFinished, id)
+		}
+	}
 }
 
 // Done runtime已经执行完成
@@ -219,23 +292,45 @@ func (r *RuntimeImpl) StopBackground() {
 	}
 }
 
-// setupMetadata 设置流水线的metadata
-func (r *RuntimeImpl) setupMetadata(ctx context.Context, pipeline Pipeline, config *PipelineConfig) error {
+// setupMetadata 设置流水线的metadata，返回设置到流水线的存储，未配置时返回nil
+// 按照 Metadate.scope 为 key 增加命名空间，并应用 Metadate.ttl 默认过期时间
+func (r *RuntimeImpl) setupMetadata(ctx context.Context, id string, pipeline Pipeline, config *PipelineConfig) (MetadataStore, error) {
 	// 检查是否有metadata配置（注意配置中是Metadate）
 	if config.Metadate.Type == "" {
-		return nil
+		return nil, nil
+	}
+
+	namespace, err := MetadataNamespace(config.Metadate, config.Name, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var ttl time.Duration
+	if config.Metadate.TTL != "" {
+		ttl, err = time.ParseDuration(config.Metadate.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metadata ttl %q: %w", config.Metadate.TTL, err)
+		}
 	}
 
 	// 创建metadata store
-	factory := NewMetadataStoreFactory()
+	r.mu.RLock()
+	factory := r.metadataFactory
+	r.mu.RUnlock()
+	if factory == nil {
+		factory = NewMetadataStoreFactory()
+	}
 	store, err := factory.Create(config.Metadate)
 	if err != nil {
-		return fmt.Errorf("failed to create metadata store: %w", err)
+		return nil, fmt.Errorf("failed to create metadata store: %w", err)
 	}
 
 	// 设置到pipeline
+	if namespace != "" || ttl > 0 {
+		store = NewScopedMetadataStore(store, namespace, ttl)
+	}
 	pipeline.SetMetadata(store)
-	return nil
+	return store, nil
 }
 
 // parseConfig 解析流水线配置
@@ -250,116 +345,6 @@ func (r *RuntimeImpl) parseConfig(config string) (*PipelineConfig, error) {
 	return &pipelineConfig, nil
 }
 
-// BuildGraph 构建图结构
-func (r *RuntimeImpl) BuildGraph(config *PipelineConfig) Graph {
-	graph := NewDGAGraph()
-
-	// 创建节点
-	nodeMap := make(map[string]Node)
-	for nodeName := range config.Nodes {
-		node := NewDGANode(nodeName, StatusUnknown)
-		nodeMap[nodeName] = node
-		graph.AddVertex(node)
-	}
-
-	// 解析图关系并添加边
-	if config.Graph != "" {
-		r.parseGraphEdges(graph, nodeMap, config.Graph)
-	}
-
-	return graph
-}
-
-// parseGraphEdges 解析图边关系
-// 使用 mermaid-check 库解析 stateDiagram-v2 语法
-// 支持从边标签中解析条件表达式，例如：A --> B: label[{eq .Param}]
-func (r *RuntimeImpl) parseGraphEdges(graph Graph, nodeMap map[string]Node, graphStr string) {
-	stateParser := parser.NewStateParser()
-	diagram, err := stateParser.Parse(graphStr)
-	if err != nil {
-		// 解析失败时静默返回，不建立边关系
-		return
-	}
-
-	// 转换为状态图
-	stateDiagram, ok := diagram.(*ast.StateDiagram)
-	if !ok {
-		return
-	}
-
-	// 遍历所有语句，提取转换关系
-	for _, stmt := range stateDiagram.Statements {
-		// 尝试转换为 Transition
-		if transition, ok := stmt.(*ast.Transition); ok {
-			// 跳过 [*] 开始/结束节点
-			if transition.From == "[*]" || transition.To == "[*]" {
-				continue
-			}
-
-			srcNode, srcExists := nodeMap[transition.From]
-			destNode, destExists := nodeMap[transition.To]
-
-			if !srcExists || !destExists {
-				continue
-			}
-
-			// 从 Label 中提取条件表达式
-			expression := r.extractExpression(transition.Label)
-
-			// 添加边关系（有条件表达式则创建条件边）
-			var edge Edge
-			if expression != "" {
-				edge = NewConditionalEdge(srcNode, destNode, expression)
-			} else {
-				edge = NewDGAEdge(srcNode, destNode)
-			}
-			_ = graph.AddEdge(edge)
-		}
-	}
-}
-
-// ExtractExpression 从边标签中提取条件表达式（公共函数供测试使用）
-// 使用模板引擎的 Validate 方法验证表达式语法
-// 先检查是否包含模板标记 {{ 或 {%，再使用模板引擎验证
-func ExtractExpression(label string) string {
-	if label == "" {
-		return ""
-	}
-
-	// 检查是否包含模板表达式标记 {{ 或 {%
-	if !strings.Contains(label, "{{") && !strings.Contains(label, "{%") {
-		return ""
-	}
-
-	// 使用模板引擎验证表达式语法
-	engine := NewPongo2TemplateEngine()
-	if err := engine.Validate(label); err == nil {
-		return label
-	}
-	return ""
-}
-
-// extractExpression 从边标签中提取条件表达式（内部使用）
-// 使用模板引擎的Validate方法验证label是否是有效的模板表达式
-func (r *RuntimeImpl) extractExpression(label string) string {
-	if label == "" {
-		return ""
-	}
-
-	// 检查是否包含模板表达式标记 {{ 或 {%
-	if !strings.Contains(label, "{{") && !strings.Contains(label, "{%") {
-		return ""
-	}
-
-	engine := r.getTemplateEngine()
-
-	// 使用模板引擎验证label是否是有效的模板表达式
-	if err := engine.Validate(label); err == nil {
-		return label
-	}
-	return ""
-}
-
 // StartBackground 启动后台处理
 func (r *RuntimeImpl) StartBackground() {
 	go func() {
@@ -380,11 +365,13 @@ func (r *RuntimeImpl) StartBackground() {
 	}()
 }
 
-// cleanupCompletedPipelines 清理已完成的流水线
+// cleanupCompletedPipelines 清理已完成的流水线和超过保留时间的日志
 func (r *RuntimeImpl) cleanupCompletedPipelines() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	r.evictLogsLocked(time.Now())
+
 	for id, pipeline := range r.pipelines {
 		select {
 		case <-pipeline.Done():
@@ -410,12 +397,40 @@ func (r *RuntimeImpl) SetTemplateEngine(engine TemplateEngine) {
 	r.templateEngine = engine
 }
 
+// SubscribeLogs 订阅流水线日志
+// 流水线执行完成后日志保留 SetLogRetention 设置的时间（默认 DefaultLogRetention），
+// 超时后由后台处理或下一次登记流水线时移除，调用 Rm 立即移除
+func (r *RuntimeImpl) SubscribeLogs(ctx context.Context, id string, node string) (<-chan Entry, error) {
+	r.mu.RLock()
+	stream, exists := r.logStreams[id]
+	r.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("pipeline with id %s not found", id)
+	}
+	return stream.Subscribe(ctx, node)
+}
+
+// SetStepRunner 设置步骤执行器
+func (r *RuntimeImpl) SetStepRunner(runner StepRunner) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stepRunner = runner
+}
+
+// SetMetadataStoreFactory 设置元数据存储工厂
+func (r *RuntimeImpl) SetMetadataStoreFactory(factory MetadataStoreFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metadataFactory = factory
+}
+
 // getTemplateEngine 获取当前使用的模板引擎（内部使用）
 func (r *RuntimeImpl) getTemplateEngine() TemplateEngine {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	if r.templateEngine == nil {
-		return NewPongo2TemplateEngine()
+		return defaultTemplateEngine
 	}
 	return r.templateEngine
 }
@@ -0,0 +1,211 @@
+package pipelinex
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LintIssue 配置检查发现的问题
+type LintIssue struct {
+	Location string // 问题所在位置，例如 edge Build->Deploy、node Deploy image、node Build step version
+	Variable string // 未定义的变量，语法错误时为空
+	Message  string
+}
+
+// String 返回便于阅读的问题描述
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%s: %s", i.Location, i.Message)
+}
+
+var (
+	// outputMarkerPattern 日志中的输出标记
+	outputMarkerPattern = regexp.MustCompile(`::set-metadata\s+([A-Za-z_][A-Za-z0-9_.\-]*)=`)
+	// outputFilePattern 写入输出文件的 key=value 或 key<<EOF
+	outputFilePattern = regexp.MustCompile(`(?:^|[\s"'])([A-Za-z_][A-Za-z0-9_.\-]*)(?:=|<<)`)
+)
+
+// Lint 检查配置中边条件、节点镜像、节点配置和步骤命令引用的变量是否都有定义
+// 已知的变量包括 Param、in-config/memory 元数据的 data、步骤输出（steps[].outputs 声明的 key，
+// 以及命令中写入 $PIPELINEX_OUTPUT 或 ::set-metadata 标记的 key）和 nodes.{节点}.outputs
+// 元数据存储为其他类型时无法静态获取 key，只检查 Param 和 nodes 下的引用
+func (r *RuntimeImpl) Lint(config string) ([]LintIssue, error) {
+	pipelineConfig, err := r.parseConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	engine := r.getTemplateEngine()
+	referencer, ok := engine.(TemplateReferencer)
+	if !ok {
+		return nil, fmt.Errorf("template engine %T does not support reference analysis", engine)
+	}
+
+	linter := newConfigLinter(pipelineConfig, referencer)
+	graph, err := r.BuildGraph(pipelineConfig)
+	if err != nil {
+		// 图的多个错误逐条报告
+		errs := []error{err}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			errs = joined.Unwrap()
+		}
+		for _, e := range errs {
+			linter.issues = append(linter.issues, LintIssue{Location: "graph", Message: e.Error()})
+		}
+	}
+
+	// 边条件在遍历时求值，上下文中没有当前节点信息和 nodes，上游输出通过元数据中的 key 引用
+	// 其他表达式引擎（例如 cel）的语法和类型错误已在构建图时返回
+	if name := pipelineConfig.Expression.Engine; name == "" || name == ExpressionEnginePongo2 {
+		edges := graph.Edges()
+		sort.Slice(edges, func(i, j int) bool { return edges[i].ID() < edges[j].ID() })
+		for _, edge := range edges {
+			linter.check("edge "+edge.ID(), edge.Expression(), linter.scope)
+		}
+	}
+
+	names := make([]string, 0, len(pipelineConfig.Nodes))
+	for name := range pipelineConfig.Nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		node := pipelineConfig.Nodes[name]
+		scope := linter.nodeScope(name)
+		linter.check("node "+name+" image", node.Image, scope)
+		linter.checkValue("node "+name+" Config", node.Config, scope)
+		for i, step := range node.Steps {
+			stepName := step.Name
+			if stepName == "" {
+				stepName = fmt.Sprintf("#%d", i+1)
+			}
+			linter.check("node "+name+" step "+stepName, step.Run, scope)
+		}
+	}
+	return linter.issues, nil
+}
+
+// configLinter 配置检查的状态
+type configLinter struct {
+	referencer TemplateReferencer
+	scope      map[string]any // 边条件可以使用的变量
+	nodes      map[string]any // 节点模板中 nodes.{节点}.outputs 形式的上游输出，边条件中不可用
+	open       bool           // 元数据 key 无法静态获取，不检查未知的顶层变量
+	issues     []LintIssue
+}
+
+// newConfigLinter 根据配置构造已知变量
+func newConfigLinter(config *PipelineConfig, referencer TemplateReferencer) *configLinter {
+	scope := map[string]any{
+		"Param":          normalizeValue(config.Param),
+		"pipelineId":     "",
+		"pipelineStatus": "",
+	}
+	if config.Param == nil {
+		scope["Param"] = map[string]any{}
+	}
+
+	l := &configLinter{referencer: referencer, scope: scope}
+	switch config.Metadate.Type {
+	case "":
+	case "in-config", "memory":
+		for k, v := range config.Metadate.Data {
+			scope[k] = normalizeValue(v)
+		}
+	default:
+		l.open = true
+	}
+
+	nodes := make(map[string]any, len(config.Nodes))
+	for name, node := range config.Nodes {
+		outputs := make(map[string]any)
+		for _, key := range stepOutputKeys(node.Steps) {
+			outputs[key] = ""
+			scope[key] = ""
+		}
+		nodes[name] = map[string]any{"outputs": outputs}
+	}
+	l.nodes = nodes
+	return l
+}
+
+// nodeScope 返回渲染节点时可以使用的变量
+func (l *configLinter) nodeScope(node string) map[string]any {
+	scope := make(map[string]any, len(l.scope)+3)
+	for k, v := range l.scope {
+		scope[k] = v
+	}
+	scope["nodes"] = l.nodes
+	scope["nodeId"] = node
+	scope["nodeStatus"] = ""
+	return scope
+}
+
+// check 检查单个模板字符串
+func (l *configLinter) check(location, text string, scope map[string]any) {
+	if !strings.Contains(text, "{{") && !strings.Contains(text, "{%") {
+		return
+	}
+	refs, err := l.referencer.References(text)
+	if err != nil {
+		l.issues = append(l.issues, LintIssue{Location: location, Message: err.Error()})
+		return
+	}
+	for _, ref := range refs {
+		path := strings.Split(ref, ".")
+		if resolveTemplatePath(scope, path) {
+			continue
+		}
+		// nodes 不来自元数据，边条件中引用时总是报告
+		if _, known := scope[path[0]]; l.open && !known && path[0] != "nodes" {
+			continue
+		}
+		l.issues = append(l.issues, LintIssue{
+			Location: location,
+			Variable: ref,
+			Message:  (&UndefinedVariableError{Variable: ref}).Error(),
+		})
+	}
+}
+
+// checkValue 递归检查配置中的字符串
+func (l *configLinter) checkValue(location string, value any, scope map[string]any) {
+	switch v := normalizeValue(value).(type) {
+	case string:
+		l.check(location, v, scope)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			l.checkValue(location+"."+k, v[k], scope)
+		}
+	case []any:
+		for i, item := range v {
+			l.checkValue(fmt.Sprintf("%s[%d]", location, i), item, scope)
+		}
+	}
+}
+
+// stepOutputKeys 返回步骤声明或在命令中写入的输出 key
+func stepOutputKeys(steps []Step) []string {
+	var keys []string
+	for _, step := range steps {
+		keys = append(keys, step.Outputs...)
+		for _, match := range outputMarkerPattern.FindAllStringSubmatch(step.Run, -1) {
+			keys = append(keys, match[1])
+		}
+		for _, line := range strings.Split(step.Run, "\n") {
+			if !strings.Contains(line, StepOutputEnv) {
+				continue
+			}
+			for _, match := range outputFilePattern.FindAllStringSubmatch(line, -1) {
+				keys = append(keys, match[1])
+			}
+		}
+	}
+	return keys
+}
@@ -9,3 +9,16 @@ type TemplateEngine interface {
 	// Validate 验证表达式语法是否正确
 	Validate(expression string) error
 }
+
+// TemplateFilter 自定义过滤器，in 为过滤器输入，param 为冒号后的参数（未传时为 nil）
+type TemplateFilter func(in any, param any) (any, error)
+
+// TemplateExtension 支持按引擎实例注册过滤器和全局函数的模板引擎
+// 注册的过滤器和函数只对当前引擎生效，应在使用引擎求值前完成注册
+type TemplateExtension interface {
+	// RegisterFilter 注册过滤器，表达式中通过 {{ value|name:param }} 使用，同名时覆盖内置过滤器
+	RegisterFilter(name string, filter TemplateFilter) error
+	// RegisterFunction 注册全局函数，表达式中通过 {{ name(args) }} 调用，fn 必须是函数
+	// 函数返回一个值，或者返回值和 error
+	RegisterFunction(name string, fn any) error
+}
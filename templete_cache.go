@@ -0,0 +1,96 @@
+package pipelinex
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/flosch/pongo2/v6"
+)
+
+// defaultTemplateCacheSize 默认缓存的已编译模板数量
+const defaultTemplateCacheSize = 1024
+
+// TemplateCacheStats 模板缓存统计
+type TemplateCacheStats struct {
+	Size   int    // 当前缓存的模板数量
+	Hits   uint64 // 命中次数
+	Misses uint64 // 未命中次数
+}
+
+// templateCache 按表达式缓存编译结果的LRU缓存，并发安全
+type templateCache[V any] struct {
+	mu     sync.Mutex
+	size   int
+	items  map[string]*list.Element
+	order  *list.List // 最近使用的在前
+	hits   uint64
+	misses uint64
+}
+
+// templateCacheEntry 缓存项
+type templateCacheEntry[V any] struct {
+	expression string
+	value      V
+}
+
+// compiledTemplate 已编译的模板及其引用的变量
+type compiledTemplate struct {
+	template *pongo2.Template
+	refs     []templateRef
+}
+
+// newTemplateCache 创建最多保存 size 个模板的缓存
+func newTemplateCache[V any](size int) *templateCache[V] {
+	return &templateCache[V]{
+		size:  size,
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+// get 获取编译结果
+func (c *templateCache[V]) get(expression string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.items[expression]
+	if !ok {
+		c.misses++
+		var zero V
+		return zero, false
+	}
+	c.hits++
+	c.order.MoveToFront(element)
+	return element.Value.(*templateCacheEntry[V]).value, true
+}
+
+// add 保存编译结果，超出容量时淘汰最久未使用的项
+func (c *templateCache[V]) add(expression string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.items[expression]; ok {
+		element.Value.(*templateCacheEntry[V]).value = value
+		c.order.MoveToFront(element)
+		return
+	}
+	c.items[expression] = c.order.PushFront(&templateCacheEntry[V]{expression: expression, value: value})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*templateCacheEntry[V]).expression)
+	}
+}
+
+// clear 清空缓存
+func (c *templateCache[V]) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// stats 返回缓存统计
+func (c *templateCache[V]) stats() TemplateCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return TemplateCacheStats{Size: c.order.Len(), Hits: c.hits, Misses: c.misses}
+}
@@ -0,0 +1,241 @@
+package pipelinex
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// 条件表达式引擎类型，对应配置中的 Expression.engine
+const (
+	ExpressionEnginePongo2 = "pongo2"
+	ExpressionEngineCEL    = "cel"
+)
+
+// 预检查CELTemplateEngine是否实现了TemplateEngine接口
+var _ TemplateEngine = (*CELTemplateEngine)(nil)
+
+// celSchemaTypes schema 中支持的类型名称
+var celSchemaTypes = map[string]*cel.Type{
+	"bool":      cel.BoolType,
+	"int":       cel.IntType,
+	"uint":      cel.UintType,
+	"double":    cel.DoubleType,
+	"string":    cel.StringType,
+	"bytes":     cel.BytesType,
+	"dyn":       cel.DynType,
+	"list":      cel.ListType(cel.DynType),
+	"map":       cel.MapType(cel.StringType, cel.DynType),
+	"timestamp": cel.TimestampType,
+	"duration":  cel.DurationType,
+}
+
+// celListType 转换 CEL 列表时使用的目标类型
+var celListType = reflect.TypeOf([]any{})
+
+// CELTemplateEngine 使用 CEL 求值条件表达式的模板引擎
+// 表达式可以写成 {{ Param.env == "prod" && retries < 3 }}，外层的 {{ }} 会被去掉
+// 结果必须是 bool，不再根据文本猜测真假；EvaluateString 交给字符串模板引擎（默认pongo2）渲染
+//
+// 声明了 schema 时表达式在编译时做类型检查，引用未声明的变量或类型不匹配都会报错；
+// 未声明 schema 时只做语法检查，变量在求值时按实际类型解析
+type CELTemplateEngine struct {
+	env     *cel.Env
+	checked bool
+	schema  map[string]string
+	render  TemplateEngine
+	cache   *templateCache[cel.Program]
+}
+
+// NewCELTemplateEngine 创建 CEL 模板引擎
+// schema 为顶层变量名到类型的映射，类型可以是 bool、int、uint、double、string、bytes、dyn、list、map、timestamp、duration
+// render 用于 EvaluateString，为 nil 时使用默认的Pongo2模板引擎
+func NewCELTemplateEngine(schema map[string]string, render TemplateEngine) (*CELTemplateEngine, error) {
+	opts := []cel.EnvOption{cel.CrossTypeNumericComparisons(true)}
+	opts = append(opts, celStdFunctions()...)
+
+	names := make([]string, 0, len(schema))
+	for name := range schema {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t, ok := celSchemaTypes[schema[name]]
+		if !ok {
+			return nil, fmt.Errorf("unsupported type %q for %s in expression schema", schema[name], name)
+		}
+		opts = append(opts, cel.Variable(name, t))
+	}
+
+	env, err := cel.NewEnv(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cel environment: %w", err)
+	}
+	if render == nil {
+		render = defaultTemplateEngine
+	}
+	return &CELTemplateEngine{
+		env:     env,
+		checked: len(schema) > 0,
+		schema:  schema,
+		render:  render,
+		cache:   newTemplateCache[cel.Program](defaultTemplateCacheSize),
+	}, nil
+}
+
+// EvaluateBool 求值条件表达式，结果必须是 bool
+func (e *CELTemplateEngine) EvaluateBool(expression string, ctx map[string]any) (bool, error) {
+	program, err := e.compile(expression)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse expression '%s': %w", expression, err)
+	}
+
+	out, _, err := program.Eval(e.activation(ctx))
+	if err != nil {
+		return false, fmt.Errorf("failed to execute expression '%s': %w", expression, err)
+	}
+	result, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("expression '%s' must evaluate to bool, got %s", expression, out.Type().TypeName())
+	}
+	return result, nil
+}
+
+// EvaluateString 使用字符串模板引擎渲染
+func (e *CELTemplateEngine) EvaluateString(expression string, ctx map[string]any) (string, error) {
+	return e.render.EvaluateString(expression, ctx)
+}
+
+// Validate 编译表达式，声明了 schema 时同时做类型检查，编译结果会被缓存
+func (e *CELTemplateEngine) Validate(expression string) error {
+	if _, err := e.compile(expression); err != nil {
+		return fmt.Errorf("invalid expression syntax: %w", err)
+	}
+	return nil
+}
+
+// CacheStats 返回已编译表达式缓存的统计
+func (e *CELTemplateEngine) CacheStats() TemplateCacheStats {
+	return e.cache.stats()
+}
+
+// compile 编译表达式，优先使用缓存
+func (e *CELTemplateEngine) compile(expression string) (cel.Program, error) {
+	if program, ok := e.cache.get(expression); ok {
+		return program, nil
+	}
+
+	source, err := celSource(expression)
+	if err != nil {
+		return nil, err
+	}
+	var ast *cel.Ast
+	var issues *cel.Issues
+	if e.checked {
+		ast, issues = e.env.Compile(source)
+	} else {
+		ast, issues = e.env.Parse(source)
+	}
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if e.checked && ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, fmt.Errorf("expression must evaluate to bool, got %s", ast.OutputType())
+	}
+
+	program, err := e.env.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+	e.cache.add(expression, program)
+	return program, nil
+}
+
+// activation 构造求值使用的变量
+// JSON 中的数字都是 double，schema 声明为 int 或 uint 的整数值会转换为对应类型
+func (e *CELTemplateEngine) activation(ctx map[string]any) map[string]any {
+	vars := make(map[string]any, len(ctx))
+	for k, v := range ctx {
+		v = normalizeValue(v)
+		if f, ok := v.(float64); ok && f == math.Trunc(f) {
+			switch e.schema[k] {
+			case "int":
+				v = int64(f)
+			case "uint":
+				if f >= 0 {
+					v = uint64(f)
+				}
+			}
+		}
+		vars[k] = v
+	}
+	return vars
+}
+
+// celSource 去掉表达式外层的 {{ }}，CEL 表达式不支持 {% %} 标签
+func celSource(expression string) (string, error) {
+	source := strings.TrimSpace(expression)
+	if strings.HasPrefix(source, "{{") && strings.HasSuffix(source, "}}") {
+		source = strings.TrimSpace(source[2 : len(source)-2])
+	}
+	if strings.Contains(source, "{%") || strings.Contains(source, "{{") {
+		return "", fmt.Errorf("cel expression must be a single {{ }} block")
+	}
+	if source == "" {
+		return "", fmt.Errorf("empty expression")
+	}
+	return source, nil
+}
+
+// celStdFunctions 与 StdTemplateFunctions 对应的 CEL 函数
+// 正则匹配使用 CEL 内置的 s.matches(pattern)
+func celStdFunctions() []cel.EnvOption {
+	semver := func(name string, cmp func(int) bool) cel.EnvOption {
+		return cel.Function(name, cel.Overload(name+"_string_string",
+			[]*cel.Type{cel.StringType, cel.StringType}, cel.BoolType,
+			cel.BinaryBinding(func(lhs, rhs ref.Val) ref.Val {
+				c, err := compareSemverValues(lhs.Value(), rhs.Value())
+				if err != nil {
+					return types.NewErr("%s: %v", name, err)
+				}
+				return types.Bool(cmp(c))
+			})))
+	}
+	return []cel.EnvOption{
+		semver("semver_gt", func(c int) bool { return c > 0 }),
+		semver("semver_lt", func(c int) bool { return c < 0 }),
+		semver("semver_eq", func(c int) bool { return c == 0 }),
+		cel.Function("changed_files_contains", cel.Overload("changed_files_contains_list_string",
+			[]*cel.Type{cel.ListType(cel.DynType), cel.StringType}, cel.BoolType,
+			cel.BinaryBinding(func(lhs, rhs ref.Val) ref.Val {
+				files, err := lhs.ConvertToNative(celListType)
+				if err != nil {
+					return types.NewErr("changed_files_contains: %v", err)
+				}
+				ok, err := changedFilesContains(files, rhs.Value())
+				if err != nil {
+					return types.NewErr("changed_files_contains: %v", err)
+				}
+				return types.Bool(ok)
+			}))),
+		cel.Function("env",
+			cel.Overload("env_string", []*cel.Type{cel.StringType}, cel.StringType,
+				cel.UnaryBinding(func(name ref.Val) ref.Val {
+					return types.String(os.Getenv(toString(name.Value())))
+				})),
+			cel.Overload("env_string_string", []*cel.Type{cel.StringType, cel.StringType}, cel.StringType,
+				cel.BinaryBinding(func(name, fallback ref.Val) ref.Val {
+					if value, ok := os.LookupEnv(toString(name.Value())); ok {
+						return types.String(value)
+					}
+					return fallback
+				}))),
+	}
+}
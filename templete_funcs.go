@@ -0,0 +1,313 @@
+package pipelinex
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/flosch/pongo2/v6"
+)
+
+// StdTemplateFunctions 返回标准辅助函数，每个 Pongo2TemplateEngine 默认都会注册
+//
+//	semver_gt(a, b)  semver_lt(a, b)  semver_eq(a, b)  按语义化版本比较
+//	matches(s, pattern)                 正则匹配
+//	changed_files_contains(files, glob) 文件列表中是否有匹配的路径，支持 * ? ** 和以 / 结尾的目录前缀
+//	env(name, default)                  读取环境变量，未设置时返回 default
+func StdTemplateFunctions() map[string]any {
+	return map[string]any{
+		"semver_gt": func(a, b any) (bool, error) {
+			c, err := compareSemverValues(a, b)
+			return c > 0, err
+		},
+		"semver_lt": func(a, b any) (bool, error) {
+			c, err := compareSemverValues(a, b)
+			return c < 0, err
+		},
+		"semver_eq": func(a, b any) (bool, error) {
+			c, err := compareSemverValues(a, b)
+			return c == 0, err
+		},
+		"matches":                matchesValue,
+		"changed_files_contains": changedFilesContains,
+		"env": func(name any, fallback ...any) string {
+			if value, ok := os.LookupEnv(toString(name)); ok {
+				return value
+			}
+			if len(fallback) > 0 {
+				return toString(fallback[0])
+			}
+			return ""
+		},
+	}
+}
+
+// StdTemplateFilters 返回标准过滤器，与同名的标准函数行为一致
+//
+//	{{ version|semver_gt:"1.2.0" }}  {{ branch|matches:"^release/" }}
+//	{{ changed_files|changed_files_contains:"docs/**" }}  {{ "CI"|env:"false" }}
+func StdTemplateFilters() map[string]TemplateFilter {
+	functions := StdTemplateFunctions()
+	semverFilter := func(name string) TemplateFilter {
+		fn := functions[name].(func(a, b any) (bool, error))
+		return func(in, param any) (any, error) {
+			if param == nil {
+				return nil, fmt.Errorf("%s requires a version parameter", name)
+			}
+			return fn(in, param)
+		}
+	}
+	return map[string]TemplateFilter{
+		"semver_gt": semverFilter("semver_gt"),
+		"semver_lt": semverFilter("semver_lt"),
+		"semver_eq": semverFilter("semver_eq"),
+		"matches": func(in, param any) (any, error) {
+			return matchesValue(in, param)
+		},
+		"changed_files_contains": func(in, param any) (any, error) {
+			return changedFilesContains(in, param)
+		},
+		"env": func(in, param any) (any, error) {
+			if value, ok := os.LookupEnv(toString(in)); ok {
+				return value, nil
+			}
+			if param == nil {
+				return "", nil
+			}
+			return toString(param), nil
+		},
+	}
+}
+
+// templateFilterFuncs 将过滤器表适配为可以在模板中调用的函数表
+func templateFilterFuncs(filters map[string]TemplateFilter) map[string]any {
+	result := make(map[string]any, len(filters))
+	for name, filter := range filters {
+		result[name] = templateFilterFunc(name, filter)
+	}
+	return result
+}
+
+// templateFilterFunc 将 TemplateFilter 适配为pongo2可以调用的函数，未传参数时 param 为 nil
+func templateFilterFunc(name string, filter TemplateFilter) func(in any, param ...any) (any, error) {
+	return func(in any, param ...any) (any, error) {
+		var p any
+		if len(param) > 0 {
+			p = param[0]
+		}
+		out, err := filter(in, p)
+		if err != nil {
+			return nil, fmt.Errorf("filter %s: %w", name, err)
+		}
+		return out, nil
+	}
+}
+
+// compareSemverValues 比较两个语义化版本，a 大于 b 时返回 1
+func compareSemverValues(a, b any) (int, error) {
+	va, err := parseSemver(toString(a))
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseSemver(toString(b))
+	if err != nil {
+		return 0, err
+	}
+	return va.compare(vb), nil
+}
+
+// semver 语义化版本，忽略构建信息
+type semver struct {
+	core       [3]int
+	prerelease []string
+}
+
+// parseSemver 解析 v1.2.3-rc.1+build 格式的版本，缺失的次版本号和修订号视为 0
+func parseSemver(version string) (semver, error) {
+	var v semver
+	s := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		if s[i+1:] == "" {
+			return v, fmt.Errorf("invalid semantic version %q", version)
+		}
+		v.prerelease = strings.Split(s[i+1:], ".")
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("invalid semantic version %q", version)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid semantic version %q", version)
+		}
+		v.core[i] = n
+	}
+	return v, nil
+}
+
+// compare 按语义化版本规则比较，预发布版本低于正式版本
+func (v semver) compare(o semver) int {
+	for i := range v.core {
+		if v.core[i] != o.core[i] {
+			return compareInt(v.core[i], o.core[i])
+		}
+	}
+	switch {
+	case len(v.prerelease) == 0 && len(o.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(o.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.prerelease) && i < len(o.prerelease); i++ {
+		a, b := v.prerelease[i], o.prerelease[i]
+		na, errA := strconv.Atoi(a)
+		nb, errB := strconv.Atoi(b)
+		switch {
+		case errA == nil && errB == nil:
+			if na != nb {
+				return compareInt(na, nb)
+			}
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		default:
+			if c := strings.Compare(a, b); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInt(len(v.prerelease), len(o.prerelease))
+}
+
+// compareInt 比较两个整数
+func compareInt(a, b int) int {
+	switch {
+	case a > b:
+		return 1
+	case a < b:
+		return -1
+	}
+	return 0
+}
+
+// regexpCacheSize 缓存的正则表达式数量，模式可能来自元数据，需要限制大小
+const regexpCacheSize = 256
+
+// regexpCache 按模式缓存编译后的正则表达式，与模板缓存使用同样的LRU
+var regexpCache = newTemplateCache[*regexp.Regexp](regexpCacheSize)
+
+// cachedRegexp 编译并缓存正则表达式
+func cachedRegexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexpCache.get(pattern); ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	regexpCache.add(pattern, re)
+	return re, nil
+}
+
+// matchesValue 判断值是否匹配正则表达式
+func matchesValue(value, pattern any) (bool, error) {
+	re, err := cachedRegexp(toString(pattern))
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(toString(value)), nil
+}
+
+// changedFilesContains 判断文件列表中是否有路径匹配 glob
+// files 可以是列表，也可以是以空白分隔的字符串
+func changedFilesContains(files, glob any) (bool, error) {
+	pattern := toString(glob)
+	if pattern == "" {
+		return false, fmt.Errorf("changed_files_contains requires a pattern")
+	}
+	re, err := cachedRegexp(globToRegexp(pattern))
+	if err != nil {
+		return false, err
+	}
+	for _, file := range toFileList(files) {
+		if re.MatchString(path.Clean(strings.TrimPrefix(file, "./"))) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// globToRegexp 将 glob 转换为正则表达式
+// * 和 ? 不匹配路径分隔符，** 匹配任意层目录，以 / 结尾的模式匹配目录下的所有文件
+func globToRegexp(glob string) string {
+	if strings.HasSuffix(glob, "/") {
+		glob += "**"
+	}
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// toFileList 将文件列表转换为字符串切片
+func toFileList(files any) []string {
+	switch v := files.(type) {
+	case nil:
+		return nil
+	case []string:
+		return v
+	case []any:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			result = append(result, toString(item))
+		}
+		return result
+	default:
+		return strings.Fields(toString(v))
+	}
+}
+
+// toString 将模板中的值转换为字符串
+func toString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case *pongo2.Value:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
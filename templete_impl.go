@@ -2,32 +2,459 @@ package pipelinex
 
 import (
 	"fmt"
+	"reflect"
+	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/flosch/pongo2/v6"
 )
 
-// 预检查Pongo2TemplateEngine是否实现了TemplateEngine接口
-var _ TemplateEngine = (*Pongo2TemplateEngine)(nil)
+// 预检查Pongo2TemplateEngine是否实现了模板引擎相关接口
+var (
+	_ TemplateEngine     = (*Pongo2TemplateEngine)(nil)
+	_ TemplateExtension  = (*Pongo2TemplateEngine)(nil)
+	_ TemplateReferencer = (*Pongo2TemplateEngine)(nil)
+)
+
+// filterIdentPattern 过滤器和函数名称格式
+var filterIdentPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// templateFiltersKey 求值上下文中保存引擎过滤器的变量名
+const templateFiltersKey = "__pipelinex_filters"
 
 // Pongo2TemplateEngine 使用pongo2作为模板引擎的实现
-type Pongo2TemplateEngine struct{}
+// 编译后的模板按表达式缓存在LRU中，同一个引擎可以被多个协程同时使用
+//
+// pongo2的过滤器表是全局的，引擎的过滤器（包括标准过滤器）不注册到pongo2，
+// 而是保存在引擎自己的过滤器表中：编译时把 value|name:param 改写为对上下文中过滤器表的调用
+// __pipelinex_filters.name(value, param)，因此不同引擎的同名过滤器互不影响，也不影响进程中其他使用pongo2的代码
+type Pongo2TemplateEngine struct {
+	cache *templateCache[*compiledTemplate]
+
+	mu        sync.RWMutex
+	filters   map[string]any // 过滤器名 -> 适配为pongo2函数的过滤器，写时复制
+	filterGen uint64         // 过滤器表的版本，注册过滤器时递增，按旧版本改写的模板不再写入缓存
+	functions map[string]any
+	strict    bool
+}
+
+// defaultTemplateEngine 边和表达式提取未指定引擎时共享的默认引擎，便于复用编译缓存
+var defaultTemplateEngine = NewPongo2TemplateEngine()
+
+// stdTemplateFunctions 未注册函数的引擎使用的标准函数
+var stdTemplateFunctions = StdTemplateFunctions()
+
+// stdTemplateFilters 未注册过滤器的引擎使用的标准过滤器
+var stdTemplateFilters = templateFilterFuncs(StdTemplateFilters())
 
 // NewPongo2TemplateEngine 创建一个新的Pongo2模板引擎实例
 func NewPongo2TemplateEngine() TemplateEngine {
-	return &Pongo2TemplateEngine{}
+	return NewPongo2TemplateEngineWithCache(defaultTemplateCacheSize)
+}
+
+// NewPongo2TemplateEngineWithCache 创建最多缓存 size 个已编译模板的Pongo2模板引擎，size <= 0 时不缓存
+// 引擎默认带有 StdTemplateFunctions 和 StdTemplateFilters 中的辅助函数和过滤器
+func NewPongo2TemplateEngineWithCache(size int) *Pongo2TemplateEngine {
+	engine := &Pongo2TemplateEngine{
+		filters:   templateFilterFuncs(StdTemplateFilters()),
+		functions: StdTemplateFunctions(),
+	}
+	if size > 0 {
+		engine.cache = newTemplateCache[*compiledTemplate](size)
+	}
+	return engine
+}
+
+// CacheStats 返回已编译模板缓存的统计
+func (e *Pongo2TemplateEngine) CacheStats() TemplateCacheStats {
+	if e.cache == nil {
+		return TemplateCacheStats{}
+	}
+	return e.cache.stats()
+}
+
+// RegisterFilter 注册只对当前引擎生效的过滤器
+func (e *Pongo2TemplateEngine) RegisterFilter(name string, filter TemplateFilter) error {
+	if !filterIdentPattern.MatchString(name) {
+		return fmt.Errorf("invalid filter name %q", name)
+	}
+	if filter == nil {
+		return fmt.Errorf("filter %s is nil", name)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// 写时复制，正在执行的模板继续使用旧的过滤器表
+	filters := make(map[string]any, len(e.filters)+1)
+	for k, v := range e.filtersLocked() {
+		filters[k] = v
+	}
+	filters[name] = templateFilterFunc(name, filter)
+	e.filters = filters
+	e.filterGen++
+	// 已编译的模板按旧的过滤器表改写，需要重新编译
+	if e.cache != nil {
+		e.cache.clear()
+	}
+	return nil
+}
+
+// RegisterFunction 注册只对当前引擎生效的全局函数，上下文中的同名变量优先
+func (e *Pongo2TemplateEngine) RegisterFunction(name string, fn any) error {
+	if !filterIdentPattern.MatchString(name) {
+		return fmt.Errorf("invalid function name %q", name)
+	}
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func {
+		return fmt.Errorf("function %s must be a func, got %T", name, fn)
+	}
+	if t.NumOut() != 1 && !(t.NumOut() == 2 && t.Out(1) == reflect.TypeOf((*error)(nil)).Elem()) {
+		return fmt.Errorf("function %s must return a value or a value and an error", name)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// 写时复制，正在执行的模板继续使用旧的函数表
+	functions := make(map[string]any, len(e.functions)+1)
+	for k, v := range e.functionsLocked() {
+		functions[k] = v
+	}
+	functions[name] = fn
+	e.functions = functions
+	return nil
+}
+
+// functionsLocked 返回当前函数表，调用方需持有锁
+func (e *Pongo2TemplateEngine) functionsLocked() map[string]any {
+	if e.functions == nil {
+		return stdTemplateFunctions
+	}
+	return e.functions
+}
+
+// filtersLocked 返回当前过滤器表，调用方需持有锁
+func (e *Pongo2TemplateEngine) filtersLocked() map[string]any {
+	if e.filters == nil {
+		return stdTemplateFilters
+	}
+	return e.filters
+}
+
+// context 合并引擎函数、过滤器表和求值上下文，上下文中的同名变量覆盖函数
+func (e *Pongo2TemplateEngine) context(ctx map[string]any) pongo2.Context {
+	e.mu.RLock()
+	functions := e.functionsLocked()
+	filters := e.filtersLocked()
+	e.mu.RUnlock()
+
+	merged := make(pongo2.Context, len(functions)+len(ctx)+1)
+	for k, v := range functions {
+		merged[k] = v
+	}
+	for k, v := range ctx {
+		merged[k] = v
+	}
+	merged[templateFiltersKey] = filters
+	return merged
+}
+
+// compile 编译表达式，优先使用缓存
+func (e *Pongo2TemplateEngine) compile(expression string) (*compiledTemplate, error) {
+	if e.cache != nil {
+		if template, ok := e.cache.get(expression); ok {
+			return template, nil
+		}
+	}
+
+	e.mu.RLock()
+	source := rewriteFilters(expression, e.filtersLocked())
+	generation := e.filterGen
+	e.mu.RUnlock()
+
+	template, err := pongo2.FromString(source)
+	if err != nil {
+		return nil, err
+	}
+	compiled := &compiledTemplate{template: template, refs: templateReferences(expression)}
+	if e.cache != nil {
+		// 编译期间注册了过滤器时不缓存，避免清空缓存后又写入按旧过滤器表改写的模板
+		e.mu.RLock()
+		if e.filterGen == generation {
+			e.cache.add(expression, compiled)
+		}
+		e.mu.RUnlock()
+	}
+	return compiled, nil
+}
+
+// execute 执行已编译的模板，严格模式下执行到未定义的变量时返回 UndefinedVariableError
+func (e *Pongo2TemplateEngine) execute(template *compiledTemplate, ctx map[string]any) (string, error) {
+	merged := e.context(ctx)
+	if !e.Strict() {
+		return template.template.Execute(merged)
+	}
+
+	tracker, err := markUndefinedRefs(template.refs, merged)
+	if err != nil {
+		return "", err
+	}
+	result, err := template.template.Execute(merged)
+	// pongo2的错误不支持 errors.Is，直接返回记录的错误
+	if tracker.err != nil {
+		return "", tracker.err
+	}
+	return result, err
+}
+
+// SetStrict 设置严格模式，严格模式下求值到未定义的变量会返回 UndefinedVariableError
+// 未执行的分支中的变量和使用 default 过滤器的变量不受影响，例如 {{ retries|default:3 }}
+func (e *Pongo2TemplateEngine) SetStrict(strict bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.strict = strict
+}
+
+// Strict 返回是否启用了严格模式
+func (e *Pongo2TemplateEngine) Strict() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.strict
+}
+
+// References 返回表达式引用的变量路径，不包括引擎注册的函数和使用了 default 过滤器的变量
+func (e *Pongo2TemplateEngine) References(expression string) ([]string, error) {
+	template, err := e.compile(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression syntax: %w", err)
+	}
+	e.mu.RLock()
+	functions := e.functionsLocked()
+	e.mu.RUnlock()
+
+	var names []string
+	for _, ref := range template.refs {
+		if ref.hasDefault {
+			continue
+		}
+		if _, ok := functions[ref.path[0]]; ok && ref.call {
+			continue
+		}
+		if ref.call {
+			names = append(names, ref.path[0])
+		} else {
+			names = append(names, ref.name())
+		}
+	}
+	return names, nil
+}
+
+// rewriteFilters 将模板标签中对 filters 中过滤器的调用改写为对上下文中过滤器表的函数调用
+// value|name:param 改写为 __pipelinex_filters.name(value, param)，pongo2 内置的过滤器保持不变，
+// 字符串字面量和标签外的文本保持不变
+func rewriteFilters(expression string, filters map[string]any) string {
+	if len(filters) == 0 || !strings.Contains(expression, "|") {
+		return expression
+	}
+
+	var b strings.Builder
+	i := 0
+	for i < len(expression) {
+		start := strings.Index(expression[i:], "{")
+		if start < 0 {
+			break
+		}
+		start += i
+		if start+1 >= len(expression) || (expression[start+1] != '{' && expression[start+1] != '%') {
+			b.WriteString(expression[i : start+1])
+			i = start + 1
+			continue
+		}
+		closing := "}}"
+		if expression[start+1] == '%' {
+			closing = "%}"
+		}
+		end := tagEnd(expression, start+2, closing)
+		b.WriteString(expression[i : start+2])
+		r := &filterRewriter{src: expression[start+2 : end], filters: filters}
+		b.WriteString(r.sequence(0))
+		i = end
+	}
+	b.WriteString(expression[i:])
+	return b.String()
+}
+
+// tagEnd 返回从 from 开始、字符串字面量之外第一个 closing 的位置，没有时返回表达式的长度
+func tagEnd(expression string, from int, closing string) int {
+	var quote byte
+	for i := from; i < len(expression); i++ {
+		c := expression[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case strings.HasPrefix(expression[i:], closing):
+			return i
+		}
+	}
+	return len(expression)
+}
+
+// filterRewriter 改写一个模板标签的内容
+type filterRewriter struct {
+	src     string
+	pos     int
+	filters map[string]any
+}
+
+// sequence 改写到 end 字符（不包括）或内容结束为止的表达式
+func (r *filterRewriter) sequence(end byte) string {
+	var b strings.Builder
+	for r.pos < len(r.src) && (end == 0 || r.src[r.pos] != end) {
+		switch c := r.src[r.pos]; {
+		case c == '"' || c == '\'' || isIdentByte(c):
+			b.WriteString(r.filtered())
+		case c == '(' || c == '[':
+			b.WriteString(r.group())
+		default:
+			b.WriteByte(c)
+			r.pos++
+		}
+	}
+	return b.String()
+}
+
+// group 改写括号内的表达式，包括括号本身
+func (r *filterRewriter) group() string {
+	open := r.src[r.pos]
+	closing := byte(')')
+	if open == '[' {
+		closing = ']'
+	}
+	r.pos++
+	inner := r.sequence(closing)
+	if r.pos < len(r.src) {
+		r.pos++
+		return string(open) + inner + string(closing)
+	}
+	return string(open) + inner
+}
+
+// filtered 改写一个变量或字面量及其后的过滤器链
+func (r *filterRewriter) filtered() string {
+	value := r.operand()
+	for {
+		save := r.pos
+		r.skipSpaces()
+		// || 是逻辑或
+		if r.pos+1 >= len(r.src) || r.src[r.pos] != '|' || r.src[r.pos+1] == '|' {
+			r.pos = save
+			return value
+		}
+		r.pos++
+		r.skipSpaces()
+		name := r.ident()
+		if name == "" {
+			r.pos = save
+			return value
+		}
+		param := ""
+		beforeParam := r.pos
+		r.skipSpaces()
+		if r.pos < len(r.src) && r.src[r.pos] == ':' {
+			r.pos++
+			r.skipSpaces()
+			param = r.operand()
+		} else {
+			r.pos = beforeParam
+		}
+
+		switch _, ok := r.filters[name]; {
+		case ok && param != "":
+			value = templateFiltersKey + "." + name + "(" + value + ", " + param + ")"
+		case ok:
+			value = templateFiltersKey + "." + name + "(" + value + ")"
+		case param != "":
+			value += "|" + name + ":" + param
+		default:
+			value += "|" + name
+		}
+	}
+}
+
+// operand 读取一个变量（包括属性、下标和函数调用）、数字或字符串字面量，变量中的参数会被改写
+func (r *filterRewriter) operand() string {
+	start := r.pos
+	if r.pos >= len(r.src) {
+		return ""
+	}
+	if c := r.src[r.pos]; c == '"' || c == '\'' {
+		r.pos++
+		for r.pos < len(r.src) && r.src[r.pos] != c {
+			if r.src[r.pos] == '\\' {
+				r.pos++
+			}
+			r.pos++
+		}
+		if r.pos < len(r.src) {
+			r.pos++
+		}
+		return r.src[start:r.pos]
+	}
+
+	var b strings.Builder
+	b.WriteString(r.ident())
+	for r.pos < len(r.src) {
+		switch c := r.src[r.pos]; {
+		case c == '.' && r.pos+1 < len(r.src) && isIdentByte(r.src[r.pos+1]):
+			r.pos++
+			b.WriteByte('.')
+			b.WriteString(r.ident())
+		case c == '(' || c == '[':
+			b.WriteString(r.group())
+		default:
+			return b.String()
+		}
+	}
+	return b.String()
+}
+
+// ident 读取标识符或数字
+func (r *filterRewriter) ident() string {
+	start := r.pos
+	for r.pos < len(r.src) && isIdentByte(r.src[r.pos]) {
+		r.pos++
+	}
+	return r.src[start:r.pos]
+}
+
+// skipSpaces 跳过空白字符
+func (r *filterRewriter) skipSpaces() {
+	for r.pos < len(r.src) && strings.IndexByte(" \t\r\n", r.src[r.pos]) >= 0 {
+		r.pos++
+	}
+}
+
+// isIdentByte 判断字符是否可以出现在标识符中
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
 }
 
 // EvaluateBool 评估模板表达式，返回布尔值
 func (e *Pongo2TemplateEngine) EvaluateBool(expression string, ctx map[string]any) (bool, error) {
 	// 确保表达式使用pongo2模板语法
-	template, err := pongo2.FromString(expression)
+	template, err := e.compile(expression)
 	if err != nil {
 		return false, fmt.Errorf("failed to parse expression '%s': %w", expression, err)
 	}
 
 	// 执行模板
-	result, err := template.Execute(ctx)
+	result, err := e.execute(template, ctx)
 	if err != nil {
 		return false, fmt.Errorf("failed to execute expression '%s': %w", expression, err)
 	}
@@ -56,12 +483,12 @@ func (e *Pongo2TemplateEngine) EvaluateBool(expression string, ctx map[string]an
 
 // EvaluateString 评估模板表达式，返回字符串
 func (e *Pongo2TemplateEngine) EvaluateString(expression string, ctx map[string]any) (string, error) {
-	template, err := pongo2.FromString(expression)
+	template, err := e.compile(expression)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse expression '%s': %w", expression, err)
 	}
 
-	result, err := template.Execute(ctx)
+	result, err := e.execute(template, ctx)
 	if err != nil {
 		return "", fmt.Errorf("failed to execute expression '%s': %w", expression, err)
 	}
@@ -69,9 +496,9 @@ func (e *Pongo2TemplateEngine) EvaluateString(expression string, ctx map[string]
 	return strings.TrimSpace(result), nil
 }
 
-// Validate 验证表达式语法是否正确
+// Validate 验证表达式语法是否正确，编译结果会被缓存，可用于预编译
 func (e *Pongo2TemplateEngine) Validate(expression string) error {
-	_, err := pongo2.FromString(expression)
+	_, err := e.compile(expression)
 	if err != nil {
 		return fmt.Errorf("invalid expression syntax: %w", err)
 	}
@@ -0,0 +1,422 @@
+package pipelinex
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrUndefinedVariable 严格模式下表达式引用了未定义的变量
+var ErrUndefinedVariable = errors.New("undefined variable")
+
+// UndefinedVariableError 未定义变量错误，Variable 为完整的变量路径，例如 Param.env
+type UndefinedVariableError struct {
+	Variable string
+}
+
+// Error 实现 error 接口
+func (e *UndefinedVariableError) Error() string {
+	return fmt.Sprintf("undefined variable %q", e.Variable)
+}
+
+// Is 支持 errors.Is(err, ErrUndefinedVariable)
+func (e *UndefinedVariableError) Is(target error) bool {
+	return target == ErrUndefinedVariable
+}
+
+// TemplateReferencer 可以分析表达式引用了哪些变量的模板引擎，用于配置检查
+type TemplateReferencer interface {
+	// References 返回表达式引用的变量路径，例如 Param.env、nodes.Build.outputs.version
+	References(expression string) ([]string, error)
+}
+
+// templateRef 表达式中引用的变量
+type templateRef struct {
+	path       []string
+	call       bool // 函数调用，只检查函数名
+	hasDefault bool // 使用了 default 过滤器，未定义时不报错
+}
+
+// name 返回变量的完整路径
+func (r templateRef) name() string {
+	return strings.Join(r.path, ".")
+}
+
+// templateKeywords pongo2表达式中的关键字
+var templateKeywords = map[string]bool{
+	"in": true, "and": true, "or": true, "not": true, "is": true, "as": true, "export": true,
+	"true": true, "false": true, "True": true, "False": true, "None": true, "nil": true,
+	"reversed": true, "sorted": true, "forloop": true,
+}
+
+// templateExpressionTags 参数为表达式的标签，其他标签不做分析
+var templateExpressionTags = map[string]bool{
+	"if": true, "elif": true, "for": true, "set": true, "with": true,
+	"ifequal": true, "ifnotequal": true, "firstof": true, "widthratio": true,
+}
+
+// templateToken 标签中的词法单元
+type templateToken struct {
+	kind byte // i 标识符，n 数字，s 字符串，p 符号
+	val  string
+}
+
+// templateReferences 分析模板中引用的变量，for/set/with 定义的局部变量不计入
+func templateReferences(expression string) []templateRef {
+	var refs []templateRef
+	locals := make(map[string]bool)
+	var expressions [][]templateToken
+
+	for _, tag := range splitTemplateTags(expression) {
+		tokens := tokenizeTemplateTag(tag.body)
+		if !tag.block {
+			expressions = append(expressions, tokens)
+			continue
+		}
+		if len(tokens) == 0 || tokens[0].kind != 'i' || !templateExpressionTags[tokens[0].val] {
+			continue
+		}
+		tokens, name := tokens[1:], tokens[0].val
+		switch name {
+		case "for":
+			// for k, v in items
+			for i, tok := range tokens {
+				if tok.kind == 'i' && tok.val == "in" {
+					tokens = tokens[i+1:]
+					break
+				}
+				if tok.kind == 'i' {
+					locals[tok.val] = true
+				}
+			}
+		case "set":
+			// set name = expr
+			if len(tokens) > 0 && tokens[0].kind == 'i' {
+				locals[tokens[0].val] = true
+				tokens = tokens[1:]
+			}
+		case "with":
+			// with name=expr 或 with expr as name
+			for i, tok := range tokens {
+				if tok.kind != 'i' {
+					continue
+				}
+				if i+1 < len(tokens) && tokens[i+1].val == "=" {
+					locals[tok.val] = true
+				}
+				if i > 0 && tokens[i-1].kind == 'i' && tokens[i-1].val == "as" {
+					locals[tok.val] = true
+				}
+			}
+		}
+		expressions = append(expressions, tokens)
+	}
+
+	seen := make(map[string]bool)
+	for _, tokens := range expressions {
+		for _, ref := range tokenReferences(tokens) {
+			if locals[ref.path[0]] {
+				continue
+			}
+			key := fmt.Sprintf("%s/%v/%v", ref.name(), ref.call, ref.hasDefault)
+			if !seen[key] {
+				seen[key] = true
+				refs = append(refs, ref)
+			}
+		}
+	}
+	return refs
+}
+
+// tokenReferences 从表达式的词法单元中提取变量路径
+func tokenReferences(tokens []templateToken) []templateRef {
+	var refs []templateRef
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.kind != 'i' || templateKeywords[tok.val] {
+			continue
+		}
+		if i > 0 && (tokens[i-1].val == "." || tokens[i-1].val == "|") {
+			// 属性访问和过滤器名
+			continue
+		}
+		if i+1 < len(tokens) && tokens[i+1].val == "=" {
+			// with name=expr 的局部变量
+			continue
+		}
+
+		ref := templateRef{path: []string{tok.val}}
+		if i+1 < len(tokens) && tokens[i+1].val == "(" {
+			ref.call = true
+		}
+		for !ref.call && i+2 < len(tokens) && tokens[i+1].val == "." && (tokens[i+2].kind == 'i' || tokens[i+2].kind == 'n') {
+			ref.path = append(ref.path, tokens[i+2].val)
+			i += 2
+		}
+		if i+2 < len(tokens) && tokens[i+1].val == "|" && (tokens[i+2].val == "default" || tokens[i+2].val == "default_if_none") {
+			ref.hasDefault = true
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// templateTag 模板中的一个标签
+type templateTag struct {
+	body  string
+	block bool // {% %} 标签
+}
+
+// splitTemplateTags 找出模板中的 {{ }} 和 {% %} 标签，跳过 {# #} 注释
+func splitTemplateTags(expression string) []templateTag {
+	var tags []templateTag
+	for i := 0; i+1 < len(expression); i++ {
+		if expression[i] != '{' {
+			continue
+		}
+		var end string
+		switch expression[i+1] {
+		case '{':
+			end = "}}"
+		case '%':
+			end = "%}"
+		case '#':
+			end = "#}"
+		default:
+			continue
+		}
+		j := indexTagEnd(expression, i+2, end)
+		if j < 0 {
+			break
+		}
+		if end != "#}" {
+			tags = append(tags, templateTag{body: expression[i+2 : j], block: end == "%}"})
+		}
+		i = j + 1
+	}
+	return tags
+}
+
+// indexTagEnd 查找标签结束标记，跳过字符串字面量
+func indexTagEnd(expression string, start int, end string) int {
+	var quote byte
+	for i := start; i+1 < len(expression); i++ {
+		c := expression[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case expression[i:i+2] == end:
+			return i
+		}
+	}
+	return -1
+}
+
+// tokenizeTemplateTag 将标签内容切分为词法单元
+func tokenizeTemplateTag(body string) []templateToken {
+	var tokens []templateToken
+	for i := 0; i < len(body); {
+		c := body[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '-' && (i == 0 || i == len(body)-1):
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(body) && body[j] != c {
+				if body[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			tokens = append(tokens, templateToken{kind: 's', val: body[i:min(j+1, len(body))]})
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(body) && (body[j] >= '0' && body[j] <= '9') {
+				j++
+			}
+			tokens = append(tokens, templateToken{kind: 'n', val: body[i:j]})
+			i = j
+		case isIdentByte(c):
+			j := i
+			for j < len(body) && isIdentByte(body[j]) {
+				j++
+			}
+			tokens = append(tokens, templateToken{kind: 'i', val: body[i:j]})
+			i = j
+		default:
+			if i+1 < len(body) {
+				switch body[i : i+2] {
+				case "==", "!=", "<=", ">=", "&&", "||", "<>":
+					tokens = append(tokens, templateToken{kind: 'p', val: body[i : i+2]})
+					i += 2
+					continue
+				}
+			}
+			tokens = append(tokens, templateToken{kind: 'p', val: string(c)})
+			i++
+		}
+	}
+	return tokens
+}
+
+// resolveTemplatePath 判断变量路径在上下文中是否存在
+// 遇到 map 和列表以外的值时无法静态判断，视为存在
+func resolveTemplatePath(ctx map[string]any, path []string) bool {
+	current, ok := ctx[path[0]]
+	if !ok {
+		return false
+	}
+	for _, segment := range path[1:] {
+		switch v := current.(type) {
+		case map[string]any:
+			if current, ok = v[segment]; !ok {
+				return false
+			}
+		case map[interface{}]interface{}:
+			if current, ok = v[segment]; !ok {
+				return false
+			}
+		case []any:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return false
+			}
+			current = v[index]
+		default:
+			return true
+		}
+	}
+	return true
+}
+
+// undefinedTracker 记录严格模式下执行模板时第一个被求值的未定义变量
+type undefinedTracker struct {
+	err error
+}
+
+// sentinel 返回放在未定义变量位置上的哨兵函数，pongo2求值到函数类型的变量时会调用它
+func (t *undefinedTracker) sentinel(name string) func(...any) (any, error) {
+	return func(...any) (any, error) {
+		if t.err == nil {
+			t.err = &UndefinedVariableError{Variable: name}
+		}
+		return nil, t.err
+	}
+}
+
+// undefinedLocation 上下文中第一个缺失的路径，以及经过它的引用
+type undefinedLocation struct {
+	path  []string
+	names map[string]bool
+}
+
+// markUndefinedRefs 在上下文中未定义的引用位置放置哨兵，模板执行到该变量时才返回 UndefinedVariableError，
+// 只出现在未执行的分支（例如 {% if false %}）中的未定义变量不报错
+// 列表下标越界时无法放置哨兵，在执行前直接报错；ctx 的顶层会被修改，嵌套的 map 和列表按需复制
+func markUndefinedRefs(refs []templateRef, ctx map[string]any) (*undefinedTracker, error) {
+	var locations []*undefinedLocation
+	index := make(map[string]*undefinedLocation)
+	for _, ref := range refs {
+		if ref.hasDefault {
+			continue
+		}
+		path := ref.path
+		if ref.call {
+			path = path[:1]
+		}
+		if resolveTemplatePath(ctx, path) {
+			continue
+		}
+		missing := 1
+		for missing < len(path) && resolveTemplatePath(ctx, path[:missing]) {
+			missing++
+		}
+		key := strings.Join(path[:missing], ".")
+		location := index[key]
+		if location == nil {
+			location = &undefinedLocation{path: path[:missing], names: make(map[string]bool)}
+			index[key] = location
+			locations = append(locations, location)
+		}
+		location.names[strings.Join(path, ".")] = true
+	}
+
+	tracker := &undefinedTracker{}
+	for _, location := range locations {
+		// 只有一个引用经过时报告完整路径，否则报告缺失的部分
+		name := strings.Join(location.path, ".")
+		if len(location.names) == 1 {
+			for n := range location.names {
+				name = n
+			}
+		}
+		if len(location.path) == 1 {
+			ctx[location.path[0]] = tracker.sentinel(name)
+			continue
+		}
+		child, ok := withTemplatePath(ctx[location.path[0]], location.path[1:], tracker.sentinel(name))
+		if !ok {
+			return nil, &UndefinedVariableError{Variable: name}
+		}
+		ctx[location.path[0]] = child
+	}
+	return tracker, nil
+}
+
+// withTemplatePath 返回在 current 的 path 位置设置了 value 的副本，无法设置时返回 false
+func withTemplatePath(current any, path []string, value any) (any, bool) {
+	switch v := current.(type) {
+	case map[string]any:
+		copied := make(map[string]any, len(v)+1)
+		for k, item := range v {
+			copied[k] = item
+		}
+		if len(path) == 1 {
+			copied[path[0]] = value
+			return copied, true
+		}
+		child, ok := withTemplatePath(v[path[0]], path[1:], value)
+		if !ok {
+			return nil, false
+		}
+		copied[path[0]] = child
+		return copied, true
+	case map[interface{}]interface{}:
+		copied := make(map[interface{}]interface{}, len(v)+1)
+		for k, item := range v {
+			copied[k] = item
+		}
+		if len(path) == 1 {
+			copied[path[0]] = value
+			return copied, true
+		}
+		child, ok := withTemplatePath(v[path[0]], path[1:], value)
+		if !ok {
+			return nil, false
+		}
+		copied[path[0]] = child
+		return copied, true
+	case []any:
+		index, err := strconv.Atoi(path[0])
+		if len(path) == 1 || err != nil || index < 0 || index >= len(v) {
+			return nil, false
+		}
+		child, ok := withTemplatePath(v[index], path[1:], value)
+		if !ok {
+			return nil, false
+		}
+		copied := append([]any(nil), v...)
+		copied[index] = child
+		return copied, true
+	default:
+		return nil, false
+	}
+}
@@ -156,3 +156,28 @@ func TestDGAEdge_ID_SpecialChars(t *testing.T) {
 		t.Errorf("Expected edge ID '%s', got '%s'", expectedID, edge.ID())
 	}
 }
+
+func TestGuardedEdge_Evaluate(t *testing.T) {
+	node1 := pipelinex.NewDGANode("node1", "RUNNING")
+	node2 := pipelinex.NewDGANode("node2", "UNKNOWN")
+
+	edge := pipelinex.NewGuardedEdge(node1, node2, []pipelinex.EdgeCondition{
+		{Expression: "{{ env == 'prod' }}", Negate: true},
+		{Expression: "{{ env == 'staging' }}"},
+	}, nil)
+	if edge.Expression() != "!{{ env == 'prod' }} && {{ env == 'staging' }}" {
+		t.Errorf("Unexpected expression %q", edge.Expression())
+	}
+
+	tests := map[string]bool{"prod": false, "staging": true, "dev": false}
+	for env, expected := range tests {
+		evalCtx := pipelinex.NewEvaluationContext().WithParams(map[string]any{"env": env})
+		result, err := edge.Evaluate(evalCtx)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result != expected {
+			t.Errorf("env=%s: expected %v, got %v", env, expected, result)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/chenyingqiao/pipelinex"
+)
+
+// newAnalysisGraph 构建分析测试使用的图
+// a -> b -> d -> e, a -> c -> d, a -> d（冗余）, c -> e（条件边）
+func newAnalysisGraph(t *testing.T) *pipelinex.DGAGraph {
+	t.Helper()
+	graph := pipelinex.NewDGAGraph()
+	nodes := map[string]pipelinex.Node{}
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		nodes[id] = pipelinex.NewDGANode(id, pipelinex.StatusUnknown)
+		graph.AddVertex(nodes[id])
+	}
+	edges := []pipelinex.Edge{
+		pipelinex.NewDGAEdge(nodes["a"], nodes["b"]),
+		pipelinex.NewDGAEdge(nodes["a"], nodes["c"]),
+		pipelinex.NewDGAEdge(nodes["a"], nodes["d"]),
+		pipelinex.NewDGAEdge(nodes["b"], nodes["d"]),
+		pipelinex.NewDGAEdge(nodes["c"], nodes["d"]),
+		pipelinex.NewDGAEdge(nodes["d"], nodes["e"]),
+		pipelinex.NewConditionalEdge(nodes["c"], nodes["e"], "{{ Param.fast }}"),
+	}
+	for _, edge := range edges {
+		if err := graph.AddEdge(edge); err != nil {
+			t.Fatalf("AddEdge(%s): %v", edge.ID(), err)
+		}
+	}
+	return graph
+}
+
+// TestDGAGraph_TopologicalOrder 测试拓扑序
+func TestDGAGraph_TopologicalOrder(t *testing.T) {
+	order, err := newAnalysisGraph(t).TopologicalOrder()
+	if err != nil {
+		t.Fatalf("TopologicalOrder failed: %v", err)
+	}
+	if expected := []string{"a", "b", "c", "d", "e"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("Order = %v, expected %v", order, expected)
+	}
+}
+
+// TestDGAGraph_AncestorsDescendants 测试上游和下游节点
+func TestDGAGraph_AncestorsDescendants(t *testing.T) {
+	graph := newAnalysisGraph(t)
+
+	tests := []struct {
+		id          string
+		ancestors   []string
+		descendants []string
+	}{
+		{id: "a", ancestors: []string{}, descendants: []string{"b", "c", "d", "e"}},
+		{id: "c", ancestors: []string{"a"}, descendants: []string{"d", "e"}},
+		{id: "d", ancestors: []string{"a", "b", "c"}, descendants: []string{"e"}},
+		{id: "missing", ancestors: nil, descendants: nil},
+	}
+	for _, tt := range tests {
+		if got := graph.Ancestors(tt.id); !reflect.DeepEqual(got, tt.ancestors) {
+			t.Errorf("Ancestors(%s) = %v, expected %v", tt.id, got, tt.ancestors)
+		}
+		if got := graph.Descendants(tt.id); !reflect.DeepEqual(got, tt.descendants) {
+			t.Errorf("Descendants(%s) = %v, expected %v", tt.id, got, tt.descendants)
+		}
+	}
+}
+
+// TestDGAGraph_CriticalPath 测试关键路径
+func TestDGAGraph_CriticalPath(t *testing.T) {
+	graph := newAnalysisGraph(t)
+
+	path, total, err := graph.CriticalPath(nil)
+	if err != nil {
+		t.Fatalf("CriticalPath failed: %v", err)
+	}
+	if expected := []string{"a", "b", "d", "e"}; !reflect.DeepEqual(path, expected) || total != 4 {
+		t.Errorf("CriticalPath = %v (%v), expected %v (4)", path, total, expected)
+	}
+
+	durations := map[string]float64{"a": 1, "b": 2, "c": 5, "d": 1, "e": 1}
+	path, total, err = graph.CriticalPath(func(node pipelinex.Node) float64 { return durations[node.Id()] })
+	if err != nil {
+		t.Fatalf("CriticalPath failed: %v", err)
+	}
+	if expected := []string{"a", "c", "d", "e"}; !reflect.DeepEqual(path, expected) || total != 8 {
+		t.Errorf("CriticalPath = %v (%v), expected %v (8)", path, total, expected)
+	}
+}
+
+// TestDGAGraph_TransitiveReduction 测试冗余边和传递规约
+func TestDGAGraph_TransitiveReduction(t *testing.T) {
+	graph := newAnalysisGraph(t)
+
+	var redundant []string
+	for _, edge := range graph.RedundantEdges() {
+		redundant = append(redundant, edge.ID())
+	}
+	if expected := []string{"a->d"}; !reflect.DeepEqual(redundant, expected) {
+		t.Errorf("RedundantEdges = %v, expected %v", redundant, expected)
+	}
+
+	reduced, err := graph.TransitiveReduction()
+	if err != nil {
+		t.Fatalf("TransitiveReduction failed: %v", err)
+	}
+	expected := []string{"a->b", "a->c", "b->d", "c->d", "c->e", "d->e"}
+	if ids := edgeIDs(reduced); !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Reduced edges = %v, expected %v", ids, expected)
+	}
+	if len(edgeIDs(graph)) != 7 {
+		t.Errorf("Expected original graph to keep its edges, got %v", edgeIDs(graph))
+	}
+}
+
+// TestDGAGraph_CyclePath 测试形成环的边被拒绝并报告环的路径
+func TestDGAGraph_CyclePath(t *testing.T) {
+	graph := newAnalysisGraph(t)
+	nodes := graph.Nodes()
+
+	err := graph.AddEdge(pipelinex.NewDGAEdge(nodes["e"], nodes["b"]))
+	var cycleErr *pipelinex.CycleError
+	if !errors.As(err, &cycleErr) || !errors.Is(err, pipelinex.ErrHasCycle) {
+		t.Fatalf("Expected CycleError, got %v", err)
+	}
+	if expected := []string{"e", "b", "d", "e"}; !reflect.DeepEqual(cycleErr.Path, expected) {
+		t.Errorf("Cycle path = %v, expected %v", cycleErr.Path, expected)
+	}
+
+	// 形成环的边不会留在图中
+	if len(graph.Edges()) != 7 || graph.HasCycle() || graph.FindCycle() != nil {
+		t.Errorf("Expected cyclic edge to be rolled back, got edges %v", edgeIDs(graph))
+	}
+	if _, err := graph.TopologicalOrder(); err != nil {
+		t.Errorf("TopologicalOrder failed after rejected edge: %v", err)
+	}
+}
+
+// TestDGAGraph_AddEdge_LargeGraph 测试大图中的环检测
+func TestDGAGraph_AddEdge_LargeGraph(t *testing.T) {
+	const size = 3000
+	graph := pipelinex.NewDGAGraph()
+	nodes := make([]pipelinex.Node, size)
+	for i := range nodes {
+		nodes[i] = pipelinex.NewDGANode(fmt.Sprintf("n%04d", i), pipelinex.StatusUnknown)
+		graph.AddVertex(nodes[i])
+	}
+	// 链 n0000 -> n0001 -> ... 以及每个节点到后面第10个节点的边
+	for i := 0; i+1 < size; i++ {
+		if err := graph.AddEdge(pipelinex.NewDGAEdge(nodes[i], nodes[i+1])); err != nil {
+			t.Fatalf("AddEdge failed: %v", err)
+		}
+		if i+10 < size {
+			if err := graph.AddEdge(pipelinex.NewDGAEdge(nodes[i], nodes[i+10])); err != nil {
+				t.Fatalf("AddEdge failed: %v", err)
+			}
+		}
+	}
+
+	err := graph.AddEdge(pipelinex.NewDGAEdge(nodes[size-1], nodes[0]))
+	var cycleErr *pipelinex.CycleError
+	if !errors.As(err, &cycleErr) {
+		t.Fatalf("Expected CycleError, got %v", err)
+	}
+	path := cycleErr.Path
+	if path[0] != "n2999" || path[1] != "n0000" || path[len(path)-1] != "n2999" {
+		t.Errorf("Unexpected cycle path: %v ... %v", path[:3], path[len(path)-3:])
+	}
+	if order, err := graph.TopologicalOrder(); err != nil || len(order) != size {
+		t.Errorf("TopologicalOrder = %d nodes, %v", len(order), err)
+	}
+}
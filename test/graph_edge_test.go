@@ -2,6 +2,7 @@ package test
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/chenyingqiao/pipelinex"
@@ -79,8 +80,8 @@ func TestDGAGraph_AddEdge_Cycle(t *testing.T) {
 		t.Error("Expected error for cycle detection")
 	}
 
-	if err != pipelinex.ErrHasCycle {
-		t.Errorf("Expected ErrHasCycle, got: %v", err)
+	if !errors.Is(err, pipelinex.ErrHasCycle) || err.Error() != "has cycle: c -> a -> b -> c" {
+		t.Errorf("Expected ErrHasCycle with path c -> a -> b -> c, got: %v", err)
 	}
 }
 
@@ -136,7 +137,7 @@ func TestDGAGraph_AddEdge_SelfLoop(t *testing.T) {
 		t.Error("Expected error for self-loop")
 	}
 
-	if err != pipelinex.ErrHasCycle {
+	if !errors.Is(err, pipelinex.ErrHasCycle) || err.Error() != "has cycle: a -> a" {
 		t.Errorf("Expected ErrHasCycle for self-loop, got: %v", err)
 	}
 }
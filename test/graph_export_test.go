@@ -0,0 +1,301 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/chenyingqiao/pipelinex"
+)
+
+// buildExportGraph 构建导出测试使用的图，Build 执行成功，Deploy 正在执行
+func buildExportGraph(t *testing.T) pipelinex.Graph {
+	t.Helper()
+	config := &pipelinex.PipelineConfig{
+		Nodes: map[string]pipelinex.NodeConfig{
+			"Build":  {},
+			"Deploy": {},
+			"Notify": {},
+		},
+		Graph: `stateDiagram-v2
+    [*] --> Build
+    Build --> Deploy: {{ Param.env == "prod" }}
+    Build --> Notify
+    Deploy --> [*]
+    Notify --> [*]`,
+	}
+
+	runtime := pipelinex.NewRuntime(context.Background()).(*pipelinex.RuntimeImpl)
+	graph, err := runtime.BuildGraph(config)
+	if err != nil {
+		t.Fatalf("BuildGraph failed: %v", err)
+	}
+	graph.Nodes()["Build"].SetStatus(pipelinex.StatusSuccess)
+	graph.Nodes()["Deploy"].SetStatus(pipelinex.StatusRunning)
+	return graph
+}
+
+// TestExportMermaid 测试导出 Mermaid 状态图
+func TestExportMermaid(t *testing.T) {
+	output := pipelinex.ExportMermaid(buildExportGraph(t))
+
+	for _, line := range []string{
+		"stateDiagram-v2",
+		"    classDef success fill:#c8e6c9,stroke:#43a047",
+		"    [*] --> Build",
+		`    Build --> Deploy: {{ Param.env == "prod" }}`,
+		"    Build --> Notify",
+		"    Notify --> [*]",
+		"    class Build success",
+		"    class Deploy running",
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Expected line %q in output:\n%s", line, output)
+		}
+	}
+	if strings.Contains(output, "class Notify") {
+		t.Errorf("Expected node with unknown status not to be styled:\n%s", output)
+	}
+
+	// 导出的图可以重新解析
+	config := &pipelinex.PipelineConfig{
+		Nodes: map[string]pipelinex.NodeConfig{"Build": {}, "Deploy": {}, "Notify": {}},
+		Graph: output,
+	}
+	runtime := pipelinex.NewRuntime(context.Background()).(*pipelinex.RuntimeImpl)
+	if _, err := runtime.BuildGraph(config); err != nil {
+		t.Errorf("Exported graph is not valid: %v", err)
+	}
+}
+
+// buildGraph 用节点列表和状态图构建图
+func buildGraph(t *testing.T, graph string, nodes ...string) pipelinex.Graph {
+	t.Helper()
+	config := &pipelinex.PipelineConfig{Nodes: map[string]pipelinex.NodeConfig{}, Graph: graph}
+	for _, node := range nodes {
+		config.Nodes[node] = pipelinex.NodeConfig{}
+	}
+	runtime := pipelinex.NewRuntime(context.Background()).(*pipelinex.RuntimeImpl)
+	g, err := runtime.BuildGraph(config)
+	if err != nil {
+		t.Fatalf("BuildGraph failed: %v\n%s", err, graph)
+	}
+	return g
+}
+
+// graphEdgeLabels 返回图中的边和条件表达式
+func graphEdgeLabels(graph pipelinex.GraphReader) []string {
+	var edges []string
+	for _, edge := range graph.Edges() {
+		edges = append(edges, edge.ID()+": "+edge.Expression())
+	}
+	sort.Strings(edges)
+	return edges
+}
+
+// TestExportMermaid_Groups 测试节点组导出为复合状态，重新解析后的组、边、入口和终止节点不变
+func TestExportMermaid_Groups(t *testing.T) {
+	nodes := []string{"Checkout", "Compile", "Unit", "Lint", "Deploy", "Tag"}
+	graph := buildGraph(t, `stateDiagram-v2
+    [*] --> Checkout
+    Checkout --> Build: {{ Param.build }}
+    state Build {
+        [*] --> Compile
+        Compile --> Test
+        state Test {
+            [*] --> Unit
+            [*] --> Lint
+            Unit --> [*]
+            Lint --> [*]
+        }
+        Test --> [*]
+    }
+    Build --> Deploy
+    Deploy --> [*]
+    state Release {
+        [*] --> Publish
+        state Publish {
+            [*] --> Tag
+            Tag --> [*]
+        }
+    }`, nodes...)
+
+	output := pipelinex.ExportMermaid(graph)
+	for _, line := range []string{
+		"    Checkout --> Build: {{ Param.build }}",
+		"    state Build {",
+		"        Compile --> Test",
+		"        state Test {",
+		"            [*] --> Lint",
+		"            Unit --> [*]",
+		"        [*] --> Publish",
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Expected line %q in output:\n%s", line, output)
+		}
+	}
+
+	exported := buildGraph(t, output, nodes...)
+	if !reflect.DeepEqual(exported.Groups(), graph.Groups()) {
+		t.Errorf("Groups = %+v, expected %+v", exported.Groups(), graph.Groups())
+	}
+	if got, expected := graphEdgeLabels(exported), graphEdgeLabels(graph); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Edges = %v, expected %v", got, expected)
+	}
+	if !reflect.DeepEqual(exported.Entries(), graph.Entries()) || !reflect.DeepEqual(exported.Terminals(), graph.Terminals()) {
+		t.Errorf("Entries/Terminals = %v/%v, expected %v/%v", exported.Entries(), exported.Terminals(), graph.Entries(), graph.Terminals())
+	}
+}
+
+// TestExportMermaid_GroupEntriesLost 测试组外到入口的边不能写为到复合状态的转换，且入口不是图的入口时，不导出组的入口
+func TestExportMermaid_GroupEntriesLost(t *testing.T) {
+	nodes := []string{"Build", "Lint", "Unit"}
+	graph := buildGraph(t, `stateDiagram-v2
+    [*] --> Build
+    Build --> Lint: {{ Param.lint }}
+    Build --> Unit
+    Lint --> Unit`, nodes...)
+	graph.AddGroup(pipelinex.NodeGroup{Name: "Checks", Nodes: []string{"Lint", "Unit"}, Entries: []string{"Lint", "Unit"}})
+
+	output := pipelinex.ExportMermaid(graph)
+	exported := buildGraph(t, output, nodes...)
+	expected := map[string]pipelinex.NodeGroup{"Checks": {Name: "Checks", Nodes: []string{"Lint", "Unit"}, Entries: []string{}, Exits: []string{}}}
+	if !reflect.DeepEqual(exported.Groups(), expected) {
+		t.Errorf("Groups = %+v, expected %+v\n%s", exported.Groups(), expected, output)
+	}
+	if got, expected := graphEdgeLabels(exported), graphEdgeLabels(graph); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Edges = %v, expected %v", got, expected)
+	}
+	if !reflect.DeepEqual(exported.Entries(), []string{"Build"}) {
+		t.Errorf("Entries = %v, expected [Build]", exported.Entries())
+	}
+}
+
+// TestExportDOT 测试导出 Graphviz DOT
+func TestExportDOT(t *testing.T) {
+	evalCtx := pipelinex.NewEvaluationContext().WithParams(map[string]any{"Param": map[string]any{"env": "dev"}})
+	output := pipelinex.ExportDOT(buildExportGraph(t), evalCtx)
+
+	for _, line := range []string{
+		"digraph pipeline {",
+		`    "Build" [label="Build\nSUCCESS", fillcolor="#c8e6c9"];`,
+		`    "Notify" [label="Notify\nUNKNOWN"];`,
+		`    "Build" -> "Deploy" [label="{{ Param.env == \"prod\" }}", style=dashed];`,
+		`    "Build" -> "Notify";`,
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Expected line %q in output:\n%s", line, output)
+		}
+	}
+}
+
+// TestExportJSON 测试导出 JSON 文档
+func TestExportJSON(t *testing.T) {
+	graph := buildExportGraph(t)
+
+	tests := []struct {
+		name    string
+		evalCtx pipelinex.EvaluationContext
+		result  *bool
+	}{
+		{name: "不求值", evalCtx: nil, result: nil},
+		{name: "条件满足", evalCtx: pipelinex.NewEvaluationContext().WithParams(map[string]any{"Param": map[string]any{"env": "prod"}}), result: boolPtr(true)},
+		{name: "条件不满足", evalCtx: pipelinex.NewEvaluationContext().WithParams(map[string]any{"Param": map[string]any{"env": "dev"}}), result: boolPtr(false)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := pipelinex.ExportJSON(graph, tt.evalCtx)
+			if err != nil {
+				t.Fatalf("ExportJSON failed: %v", err)
+			}
+			var doc pipelinex.GraphDocument
+			if err := json.Unmarshal(data, &doc); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+
+			if len(doc.Nodes) != 3 || doc.Nodes[0].ID != "Build" || !doc.Nodes[0].Entry || doc.Nodes[0].Status != pipelinex.StatusSuccess {
+				t.Errorf("Unexpected nodes: %+v", doc.Nodes)
+			}
+			if !doc.Nodes[1].Terminal || !doc.Nodes[2].Terminal {
+				t.Errorf("Expected Deploy and Notify to be terminal: %+v", doc.Nodes)
+			}
+			if len(doc.Edges) != 2 {
+				t.Fatalf("Expected 2 edges, got %+v", doc.Edges)
+			}
+			deploy := doc.Edges[0]
+			if deploy.ID != "Build->Deploy" || deploy.Expression != `{{ Param.env == "prod" }}` {
+				t.Errorf("Unexpected edge: %+v", deploy)
+			}
+			if (deploy.Result == nil) != (tt.result == nil) || (deploy.Result != nil && *deploy.Result != *tt.result) {
+				t.Errorf("Edge result = %v, expected %v", deploy.Result, tt.result)
+			}
+			if doc.Edges[1].Result != nil {
+				t.Errorf("Expected unconditional edge to have no result: %+v", doc.Edges[1])
+			}
+		})
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// TestRuntimeImpl_NodeStatus 测试执行时更新节点状态，导出的图反映执行结果
+func TestRuntimeImpl_NodeStatus(t *testing.T) {
+	config := `
+Graph: |
+  stateDiagram-v2
+    [*] --> Build
+    Build --> Test
+    Test --> Deploy
+    Deploy --> [*]
+Nodes:
+  Build:
+    steps: [{name: build, run: "true"}]
+  Test:
+    steps: [{name: test, run: "false"}]
+  Deploy:
+    steps: [{name: deploy, run: "true"}]
+`
+
+	ctx := context.Background()
+	runtime := pipelinex.NewRuntime(ctx)
+	runtime.SetStepRunner(pipelinex.NewLocalStepRunner())
+	listener := &pipelineCaptureListener{}
+	if _, err := runtime.RunSync(ctx, "test-node-status", config, listener); err == nil {
+		t.Fatal("Expected RunSync to fail")
+	}
+
+	nodes := listener.pipeline.GetGraph().Nodes()
+	expected := map[string]string{
+		"Build":  pipelinex.StatusSuccess,
+		"Test":   pipelinex.StatusFailed,
+		"Deploy": pipelinex.StatusUnknown,
+	}
+	for id, status := range expected {
+		if got := nodes[id].Status(); got != status {
+			t.Errorf("Node %s status = %s, expected %s", id, got, status)
+		}
+	}
+}
+
+// pipelineCaptureListener 记录事件对应的流水线，RunSync 失败时也能检查执行结果
+type pipelineCaptureListener struct {
+	mu       sync.Mutex
+	pipeline pipelinex.Pipeline
+}
+
+func (l *pipelineCaptureListener) Handle(p pipelinex.Pipeline, event pipelinex.Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pipeline = p
+}
+
+func (l *pipelineCaptureListener) Events() []pipelinex.Event {
+	return []pipelinex.Event{pipelinex.PipelineFinish}
+}
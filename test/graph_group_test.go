@@ -0,0 +1,308 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/chenyingqiao/pipelinex"
+)
+
+// TestParseGraphEdges_CompositeState 测试复合状态的入边连接到组内入口，出边从组内出口出发
+func TestParseGraphEdges_CompositeState(t *testing.T) {
+	config := &pipelinex.PipelineConfig{
+		Nodes: map[string]pipelinex.NodeConfig{
+			"Checkout": {},
+			"Compile":  {},
+			"Lint":     {},
+			"Package":  {},
+			"Deploy":   {},
+		},
+		Graph: `stateDiagram-v2
+    [*] --> Checkout
+    Checkout --> Build
+    state Build {
+        [*] --> Compile
+        [*] --> Lint
+        Compile --> Package
+        Lint --> Package
+        Package --> [*]
+    }
+    Build --> Deploy
+    Deploy --> [*]`,
+	}
+
+	runtime := pipelinex.NewRuntime(context.Background()).(*pipelinex.RuntimeImpl)
+	graph, err := runtime.BuildGraph(config)
+	if err != nil {
+		t.Fatalf("BuildGraph failed: %v", err)
+	}
+
+	expected := []string{"Checkout->Compile", "Checkout->Lint", "Compile->Package", "Lint->Package", "Package->Deploy"}
+	if ids := edgeIDs(graph); !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Edges = %v, expected %v", ids, expected)
+	}
+
+	group, ok := graph.Groups()["Build"]
+	if !ok {
+		t.Fatalf("Expected group Build, got %v", graph.Groups())
+	}
+	want := pipelinex.NodeGroup{
+		Name:    "Build",
+		Nodes:   []string{"Compile", "Lint", "Package"},
+		Entries: []string{"Compile", "Lint"},
+		Exits:   []string{"Package"},
+	}
+	if !reflect.DeepEqual(group, want) {
+		t.Errorf("Group = %+v, expected %+v", group, want)
+	}
+}
+
+// TestParseGraphEdges_NestedCompositeState 测试嵌套的复合状态
+func TestParseGraphEdges_NestedCompositeState(t *testing.T) {
+	config := &pipelinex.PipelineConfig{
+		Nodes: map[string]pipelinex.NodeConfig{
+			"Compile": {},
+			"Publish": {},
+			"Notify":  {},
+		},
+		Graph: `stateDiagram-v2
+    [*] --> Release
+    state Release {
+        [*] --> Build
+        state Build {
+            [*] --> Compile
+            Compile --> [*]
+        }
+        Build --> Publish
+        Publish --> [*]
+    }
+    Release --> Notify
+    Notify --> [*]`,
+	}
+
+	runtime := pipelinex.NewRuntime(context.Background()).(*pipelinex.RuntimeImpl)
+	graph, err := runtime.BuildGraph(config)
+	if err != nil {
+		t.Fatalf("BuildGraph failed: %v", err)
+	}
+
+	expected := []string{"Compile->Publish", "Publish->Notify"}
+	if ids := edgeIDs(graph); !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Edges = %v, expected %v", ids, expected)
+	}
+
+	groups := graph.Groups()
+	if build := groups["Build"]; build.Parent != "Release" || !reflect.DeepEqual(build.Nodes, []string{"Compile"}) {
+		t.Errorf("Group Build = %+v", build)
+	}
+	release := groups["Release"]
+	if !reflect.DeepEqual(release.Nodes, []string{"Compile", "Publish"}) ||
+		!reflect.DeepEqual(release.Entries, []string{"Compile"}) ||
+		!reflect.DeepEqual(release.Exits, []string{"Publish"}) {
+		t.Errorf("Group Release = %+v", release)
+	}
+}
+
+// TestParseGraphEdges_CompositeStateErrors 测试复合状态和 Groups 的配置错误
+func TestParseGraphEdges_CompositeStateErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		graph   string
+		groups  map[string]pipelinex.GroupConfig
+		message string
+	}{
+		{
+			name: "Groups引用不存在的复合状态",
+			graph: `stateDiagram-v2
+    [*] --> A
+    A --> B`,
+			groups:  map[string]pipelinex.GroupConfig{"Build": {Image: "golang"}},
+			message: `group "Build" in Groups is not a composite state in Graph`,
+		},
+		{
+			name:    "未定义Graph时配置Groups",
+			groups:  map[string]pipelinex.GroupConfig{"Build": {Image: "golang"}},
+			message: `group "Build" in Groups is not a composite state in Graph`,
+		},
+		{
+			name: "节点属于两个复合状态",
+			graph: `stateDiagram-v2
+    state G1 {
+        [*] --> A
+    }
+    state G2 {
+        A --> B
+    }
+    G1 --> G2`,
+			message: `line 6, column 9: invalid graph: node "A" belongs to composite states "G1" and "G2"`,
+		},
+		{
+			name: "复合状态与节点同名",
+			graph: `stateDiagram-v2
+    state A {
+        [*] --> B
+    }`,
+			message: `composite state "A" conflicts with node of the same name`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &pipelinex.PipelineConfig{
+				Nodes:  map[string]pipelinex.NodeConfig{"A": {}, "B": {}},
+				Graph:  tt.graph,
+				Groups: tt.groups,
+			}
+			runtime := pipelinex.NewRuntime(context.Background()).(*pipelinex.RuntimeImpl)
+			_, err := runtime.BuildGraph(config)
+			if !errors.Is(err, pipelinex.ErrInvalidGraph) || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Expected error containing %q, got %v", tt.message, err)
+			}
+		})
+	}
+}
+
+// TestRuntimeImpl_GroupConfigInheritance 测试组内节点继承组的镜像和配置
+func TestRuntimeImpl_GroupConfigInheritance(t *testing.T) {
+	config := `
+Graph: |
+  stateDiagram-v2
+    [*] --> Build
+    state Build {
+      [*] --> Compile
+      Compile --> Lint
+      Lint --> [*]
+    }
+    Build --> Deploy
+    Deploy --> [*]
+Groups:
+  Build:
+    image: golang:1.22
+    Config:
+      cache: true
+      arch: amd64
+Nodes:
+  Compile:
+    Config:
+      arch: arm64
+    steps: [{name: compile, run: "true"}]
+  Lint:
+    image: golangci-lint:latest
+    steps: [{name: lint, run: "true"}]
+  Deploy:
+    steps: [{name: deploy, run: "true"}]
+`
+
+	ctx := context.Background()
+	runtime := pipelinex.NewRuntime(ctx)
+	runner := &recordStepRunner{local: pipelinex.NewLocalStepRunner()}
+	runtime.SetStepRunner(runner)
+	if _, err := runtime.RunSync(ctx, "test-group-config", config, nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+
+	tests := []struct {
+		step   string
+		image  string
+		config map[string]interface{}
+	}{
+		{step: "compile", image: "golang:1.22", config: map[string]interface{}{"cache": true, "arch": "arm64"}},
+		{step: "lint", image: "golangci-lint:latest", config: map[string]interface{}{"cache": true, "arch": "amd64"}},
+		{step: "deploy", image: "", config: nil},
+	}
+	for _, tt := range tests {
+		execution, ok := runner.find(tt.step)
+		if !ok {
+			t.Fatalf("Step %s did not run", tt.step)
+		}
+		if execution.Image != tt.image {
+			t.Errorf("Step %s image = %q, expected %q", tt.step, execution.Image, tt.image)
+		}
+		if len(execution.Config) != len(tt.config) || (tt.config != nil && !reflect.DeepEqual(execution.Config, tt.config)) {
+			t.Errorf("Step %s config = %v, expected %v", tt.step, execution.Config, tt.config)
+		}
+	}
+}
+
+// groupRecordListener 记录节点组的状态变化
+type groupRecordListener struct {
+	mu      sync.Mutex
+	changes []string
+}
+
+func (l *groupRecordListener) Handle(p pipelinex.Pipeline, event pipelinex.Event) {}
+
+func (l *groupRecordListener) Events() []pipelinex.Event {
+	return []pipelinex.Event{pipelinex.PipelineGroupStart, pipelinex.PipelineGroupFinish}
+}
+
+func (l *groupRecordListener) HandleGroup(p pipelinex.Pipeline, group pipelinex.NodeGroup, status string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.changes = append(l.changes, group.Name+":"+status)
+}
+
+// TestRuntimeImpl_GroupListener 测试节点组的状态通知
+func TestRuntimeImpl_GroupListener(t *testing.T) {
+	config := `
+Graph: |
+  stateDiagram-v2
+    [*] --> Release
+    state Release {
+      [*] --> Build
+      state Build {
+        [*] --> Compile
+        Compile --> [*]
+      }
+      Build --> Publish
+      Publish --> [*]
+    }
+    Release --> Notify
+    Notify --> [*]
+Nodes:
+  Compile:
+    steps: [{name: compile, run: "true"}]
+  Publish:
+    steps: [{name: publish, run: "PUBLISH_CMD"}]
+  Notify:
+    steps: [{name: notify, run: "true"}]
+`
+
+	tests := []struct {
+		name     string
+		publish  string
+		expected []string
+	}{
+		{
+			name:     "成功",
+			publish:  "true",
+			expected: []string{"Release:" + pipelinex.StatusRunning, "Build:" + pipelinex.StatusRunning, "Build:" + pipelinex.StatusSuccess, "Release:" + pipelinex.StatusSuccess},
+		},
+		{
+			name:     "组内节点失败",
+			publish:  "false",
+			expected: []string{"Release:" + pipelinex.StatusRunning, "Build:" + pipelinex.StatusRunning, "Build:" + pipelinex.StatusSuccess, "Release:" + pipelinex.StatusFailed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			runtime := pipelinex.NewRuntime(ctx)
+			runtime.SetStepRunner(pipelinex.NewLocalStepRunner())
+			listener := &groupRecordListener{}
+
+			_, err := runtime.RunSync(ctx, "test-group-listener", strings.Replace(config, "PUBLISH_CMD", tt.publish, 1), listener)
+			if (err != nil) != (tt.publish == "false") {
+				t.Fatalf("RunSync error = %v", err)
+			}
+			if !reflect.DeepEqual(listener.changes, tt.expected) {
+				t.Errorf("Group changes = %v, expected %v", listener.changes, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/chenyingqiao/pipelinex"
+)
+
+// traverseIDs 遍历图并返回按ID排序的已执行节点，每个节点执行的次数记录在 counts 中
+func traverseIDs(t *testing.T, graph pipelinex.GraphReader, evalCtx pipelinex.EvaluationContext) ([]string, map[string]int) {
+	t.Helper()
+	var mu sync.Mutex
+	counts := map[string]int{}
+	err := graph.Traversal(context.Background(), evalCtx, func(ctx context.Context, node pipelinex.Node) error {
+		mu.Lock()
+		defer mu.Unlock()
+		counts[node.Id()]++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Traversal failed: %v", err)
+	}
+	ids := make([]string, 0, len(counts))
+	for id := range counts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, counts
+}
+
+// TestDGAGraph_ParallelEdges 测试同一对节点之间的并行边按“或”求值，目标节点只执行一次
+func TestDGAGraph_ParallelEdges(t *testing.T) {
+	a := pipelinex.NewDGANode("A", pipelinex.StatusUnknown)
+	b := pipelinex.NewDGANode("B", pipelinex.StatusUnknown)
+	graph := pipelinex.NewDGAGraph()
+	graph.AddVertex(a)
+	graph.AddVertex(b)
+	for _, expression := range []string{"{{ Param.x }}", "{{ Param.y }}"} {
+		if err := graph.AddEdge(pipelinex.NewConditionalEdge(a, b, expression)); err != nil {
+			t.Fatalf("AddEdge failed: %v", err)
+		}
+	}
+	if len(graph.Edges()) != 2 {
+		t.Fatalf("Expected 2 parallel edges, got %d", len(graph.Edges()))
+	}
+
+	tests := []struct {
+		name     string
+		x, y     bool
+		expected []string
+	}{
+		{name: "两个条件都满足", x: true, y: true, expected: []string{"A", "B"}},
+		{name: "第一个条件满足", x: true, y: false, expected: []string{"A", "B"}},
+		{name: "第二个条件满足", x: false, y: true, expected: []string{"A", "B"}},
+		{name: "都不满足", x: false, y: false, expected: []string{"A"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evalCtx := pipelinex.NewEvaluationContext().WithParams(map[string]any{"Param": map[string]any{"x": tt.x, "y": tt.y}})
+			ids, counts := traverseIDs(t, graph, evalCtx)
+			if !reflect.DeepEqual(ids, tt.expected) {
+				t.Errorf("Visited = %v, expected %v", ids, tt.expected)
+			}
+			if counts["B"] > 1 {
+				t.Errorf("Expected B to run once, ran %d times", counts["B"])
+			}
+		})
+	}
+
+	// 无条件的并行边使节点对无条件可达
+	if err := graph.AddEdge(pipelinex.NewDGAEdge(a, b)); err != nil {
+		t.Fatalf("AddEdge failed: %v", err)
+	}
+	evalCtx := pipelinex.NewEvaluationContext().WithParams(map[string]any{"Param": map[string]any{"x": false, "y": false}})
+	if ids, _ := traverseIDs(t, graph, evalCtx); !reflect.DeepEqual(ids, []string{"A", "B"}) {
+		t.Errorf("Visited = %v, expected [A B]", ids)
+	}
+}
+
+// TestRuntimeImpl_ParallelTransitions 测试状态图中重复的转换作为并行边
+func TestRuntimeImpl_ParallelTransitions(t *testing.T) {
+	config := &pipelinex.PipelineConfig{
+		Nodes: map[string]pipelinex.NodeConfig{"Build": {}, "Deploy": {}},
+		Graph: `stateDiagram-v2
+    [*] --> Build
+    Build --> Deploy: {{ Param.env == "prod" }}
+    Build --> Deploy: {{ Param.env == "staging" }}
+    Deploy --> [*]`,
+	}
+	runtime := pipelinex.NewRuntime(context.Background()).(*pipelinex.RuntimeImpl)
+	graph, err := runtime.BuildGraph(config)
+	if err != nil {
+		t.Fatalf("BuildGraph failed: %v", err)
+	}
+	if len(graph.Edges()) != 2 {
+		t.Fatalf("Expected 2 edges, got %d", len(graph.Edges()))
+	}
+
+	for env, expected := range map[string][]string{
+		"prod":    {"Build", "Deploy"},
+		"staging": {"Build", "Deploy"},
+	} {
+		evalCtx := pipelinex.NewEvaluationContext().WithParams(map[string]any{"Param": map[string]any{"env": env}})
+		if ids, _ := traverseIDs(t, graph, evalCtx); !reflect.DeepEqual(ids, expected) {
+			t.Errorf("env=%s: visited = %v, expected %v", env, ids, expected)
+		}
+	}
+
+	evalCtx := pipelinex.NewEvaluationContext().WithParams(map[string]any{"Param": map[string]any{"env": "dev"}})
+	err = graph.Traversal(context.Background(), evalCtx, func(ctx context.Context, node pipelinex.Node) error { return nil })
+	if !errors.Is(err, pipelinex.ErrNoTerminal) {
+		t.Errorf("Expected ErrNoTerminal, got %v", err)
+	}
+}
+
+// TestDGAGraph_RemoveEdge 测试删除边
+func TestDGAGraph_RemoveEdge(t *testing.T) {
+	a := pipelinex.NewDGANode("A", pipelinex.StatusUnknown)
+	b := pipelinex.NewDGANode("B", pipelinex.StatusUnknown)
+	graph := pipelinex.NewDGAGraph()
+	graph.AddVertex(a)
+	graph.AddVertex(b)
+	first := pipelinex.NewConditionalEdge(a, b, "{{ Param.x }}")
+	second := pipelinex.NewDGAEdge(a, b)
+	for _, edge := range []pipelinex.Edge{first, second} {
+		if err := graph.AddEdge(edge); err != nil {
+			t.Fatalf("AddEdge failed: %v", err)
+		}
+	}
+
+	// 删除无条件边后只剩条件边
+	if err := graph.RemoveEdge(second); err != nil {
+		t.Fatalf("RemoveEdge failed: %v", err)
+	}
+	edges := graph.Edges()
+	if len(edges) != 1 || edges[0] != first {
+		t.Fatalf("Expected only the conditional edge to remain, got %v", edges)
+	}
+	evalCtx := pipelinex.NewEvaluationContext().WithParams(map[string]any{"Param": map[string]any{"x": false}})
+	if ids, _ := traverseIDs(t, graph, evalCtx); !reflect.DeepEqual(ids, []string{"A"}) {
+		t.Errorf("Visited = %v, expected [A]", ids)
+	}
+
+	// 按表达式匹配删除最后一条边，B 不再依赖 A
+	if err := graph.RemoveEdge(pipelinex.NewConditionalEdge(a, b, "{{ Param.x }}")); err != nil {
+		t.Fatalf("RemoveEdge failed: %v", err)
+	}
+	if len(graph.Edges()) != 0 {
+		t.Fatalf("Expected no edges, got %v", graph.Edges())
+	}
+	if order, err := graph.TopologicalOrder(); err != nil || !reflect.DeepEqual(order, []string{"A", "B"}) {
+		t.Errorf("TopologicalOrder = %v, %v", order, err)
+	}
+	if ids, _ := traverseIDs(t, graph, evalCtx); !reflect.DeepEqual(ids, []string{"A", "B"}) {
+		t.Errorf("Visited = %v, expected [A B]", ids)
+	}
+
+	if err := graph.RemoveEdge(first); !errors.Is(err, pipelinex.ErrEdgeNotFound) {
+		t.Errorf("Expected ErrEdgeNotFound, got %v", err)
+	}
+}
+
+// TestDGAGraph_RemoveVertex 测试删除顶点时同时删除相连的边、入口标记和节点组成员
+func TestDGAGraph_RemoveVertex(t *testing.T) {
+	graph := newAnalysisGraph(t)
+	nodes := graph.Nodes()
+	graph.AddEntry(nodes["a"])
+	graph.AddTerminal(nodes["e"])
+	graph.AddGroup(pipelinex.NodeGroup{Name: "G", Nodes: []string{"b", "d"}, Entries: []string{"b"}, Exits: []string{"d"}})
+
+	if err := graph.RemoveVertex(nodes["d"]); err != nil {
+		t.Fatalf("RemoveVertex failed: %v", err)
+	}
+	if _, ok := graph.Nodes()["d"]; ok {
+		t.Error("Expected d to be removed")
+	}
+	for _, edge := range graph.Edges() {
+		if edge.Source().Id() == "d" || edge.Target().Id() == "d" {
+			t.Errorf("Expected edge %s to be removed", edge.ID())
+		}
+	}
+	if descendants := graph.Descendants("a"); !reflect.DeepEqual(descendants, []string{"b", "c", "e"}) {
+		t.Errorf("Descendants(a) = %v, expected [b c e]", descendants)
+	}
+	group := graph.Groups()["G"]
+	if !reflect.DeepEqual(group.Nodes, []string{"b"}) || len(group.Exits) != 0 {
+		t.Errorf("Unexpected group after removal: %+v", group)
+	}
+
+	if err := graph.RemoveVertex(nodes["e"]); err != nil {
+		t.Fatalf("RemoveVertex failed: %v", err)
+	}
+	if len(graph.Terminals()) != 0 {
+		t.Errorf("Expected terminal mark to be removed, got %v", graph.Terminals())
+	}
+	if ids, _ := traverseIDs(t, graph, pipelinex.NewEvaluationContext()); !reflect.DeepEqual(ids, []string{"a", "b", "c"}) {
+		t.Errorf("Visited = %v, expected [a b c]", ids)
+	}
+
+	if err := graph.RemoveVertex(nodes["d"]); !errors.Is(err, pipelinex.ErrVertexNotFound) {
+		t.Errorf("Expected ErrVertexNotFound, got %v", err)
+	}
+
+	// 重新添加顶点不会清除已有的边
+	graph.AddVertex(nodes["a"])
+	if descendants := graph.Descendants("a"); !reflect.DeepEqual(descendants, []string{"b", "c"}) {
+		t.Errorf("Descendants(a) = %v after re-adding a, expected [b c]", descendants)
+	}
+}
+
+// TestDGAGraph_RedundantParallelEdges 测试重复的无条件并行边只保留一条
+func TestDGAGraph_RedundantParallelEdges(t *testing.T) {
+	a := pipelinex.NewDGANode("A", pipelinex.StatusUnknown)
+	b := pipelinex.NewDGANode("B", pipelinex.StatusUnknown)
+	graph := pipelinex.NewDGAGraph()
+	graph.AddVertex(a)
+	graph.AddVertex(b)
+	conditional := pipelinex.NewConditionalEdge(a, b, "{{ Param.x }}")
+	first := pipelinex.NewDGAEdge(a, b)
+	duplicate := pipelinex.NewDGAEdge(a, b)
+	for _, edge := range []pipelinex.Edge{conditional, first, duplicate} {
+		if err := graph.AddEdge(edge); err != nil {
+			t.Fatalf("AddEdge failed: %v", err)
+		}
+	}
+
+	redundant := graph.RedundantEdges()
+	if len(redundant) != 2 || redundant[0] != conditional || redundant[1] != duplicate {
+		t.Errorf("RedundantEdges = %v, expected the conditional edge and the duplicate", redundant)
+	}
+	reduced, err := graph.TransitiveReduction()
+	if err != nil {
+		t.Fatalf("TransitiveReduction failed: %v", err)
+	}
+	if edges := reduced.Edges(); len(edges) != 1 || edges[0] != first {
+		t.Errorf("Reduced edges = %v, expected only the first unconditional edge", edges)
+	}
+}
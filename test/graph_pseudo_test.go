@@ -0,0 +1,169 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/chenyingqiao/pipelinex"
+)
+
+// edgeIDs 返回图中所有边的ID
+func edgeIDs(graph pipelinex.Graph) []string {
+	var ids []string
+	for _, edge := range graph.Edges() {
+		ids = append(ids, edge.ID())
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// TestParseGraphEdges_ForkJoin 测试 fork/join 伪状态展开为节点之间的边
+func TestParseGraphEdges_ForkJoin(t *testing.T) {
+	config := &pipelinex.PipelineConfig{
+		Nodes: map[string]pipelinex.NodeConfig{
+			"Checkout": {},
+			"Lint":     {},
+			"Test":     {},
+			"Build":    {},
+		},
+		Graph: `stateDiagram-v2
+    state fork_state <<fork>>
+    state join_state <<join>>
+    [*] --> Checkout
+    Checkout --> fork_state
+    fork_state --> Lint
+    fork_state --> Test
+    Lint --> join_state
+    Test --> join_state
+    join_state --> Build
+    Build --> [*]`,
+	}
+
+	runtime := pipelinex.NewRuntime(context.Background()).(*pipelinex.RuntimeImpl)
+	graph, err := runtime.BuildGraph(config)
+	if err != nil {
+		t.Fatalf("BuildGraph failed: %v", err)
+	}
+	if len(graph.Nodes()) != 4 {
+		t.Errorf("Expected pseudo-states not to become nodes, got %d nodes", len(graph.Nodes()))
+	}
+	expected := []string{"Checkout->Lint", "Checkout->Test", "Lint->Build", "Test->Build"}
+	if ids := edgeIDs(graph); !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Edges = %v, expected %v", ids, expected)
+	}
+}
+
+// TestRuntimeImpl_ChoiceState 测试 choice 按顺序只选择一个分支，都不满足时走 else
+func TestRuntimeImpl_ChoiceState(t *testing.T) {
+	config := `
+Graph: |
+  stateDiagram-v2
+    state target <<choice>>
+    [*] --> Build
+    Build --> target
+    target --> Prod: {{ Param.env == "prod" }}
+    target --> Staging: {{ Param.env != "dev" }}
+    target --> Dev: else
+    Prod --> [*]
+    Staging --> [*]
+    Dev --> [*]
+Nodes:
+  Build:
+    steps: [{name: build, run: "true"}]
+  Prod:
+    steps: [{name: prod, run: "true"}]
+  Staging:
+    steps: [{name: staging, run: "true"}]
+  Dev:
+    steps: [{name: dev, run: "true"}]
+`
+
+	tests := []struct {
+		env      string
+		expected string
+	}{
+		{env: "prod", expected: "prod"},
+		{env: "staging", expected: "staging"},
+		{env: "dev", expected: "dev"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			ctx := context.Background()
+			runtime := pipelinex.NewRuntime(ctx)
+			runner := &recordStepRunner{local: pipelinex.NewLocalStepRunner()}
+			runtime.SetStepRunner(runner)
+
+			withEnv := strings.Replace(config, "Graph:", "Param:\n  env: "+tt.env+"\nGraph:", 1)
+			if _, err := runtime.RunSync(ctx, "test-choice-"+tt.env, withEnv, nil); err != nil {
+				t.Fatalf("RunSync failed: %v", err)
+			}
+			for _, step := range []string{"prod", "staging", "dev"} {
+				_, ran := runner.find(step)
+				if ran != (step == tt.expected) {
+					t.Errorf("Step %s ran = %v, expected only %s to run", step, ran, tt.expected)
+				}
+			}
+		})
+	}
+}
+
+// TestParseGraphEdges_PseudoStateErrors 测试伪状态的配置错误
+func TestParseGraphEdges_PseudoStateErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		graph   string
+		message string
+	}{
+		{
+			name: "choice分支缺少条件",
+			graph: `stateDiagram-v2
+    state c <<choice>>
+    A --> c
+    c --> B`,
+			message: `line 4, column 5: invalid graph: choice branch c->B requires a condition or else`,
+		},
+		{
+			name: "多个else",
+			graph: `stateDiagram-v2
+    state c <<choice>>
+    A --> c
+    c --> A2: else
+    c --> B: else`,
+			message: `choice state "c" has more than one else branch`,
+		},
+		{
+			name: "伪状态与节点同名",
+			graph: `stateDiagram-v2
+    state B <<fork>>
+    A --> B`,
+			message: `line 2, column 11: invalid graph: fork state "B" conflicts with node of the same name`,
+		},
+		{
+			name: "伪状态没有出边",
+			graph: `stateDiagram-v2
+    state f <<fork>>
+    A --> f
+    A --> B`,
+			message: `fork state "f" has no outgoing transition`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &pipelinex.PipelineConfig{
+				Nodes: map[string]pipelinex.NodeConfig{"A": {}, "A2": {}, "B": {}},
+				Graph: tt.graph,
+			}
+			runtime := pipelinex.NewRuntime(context.Background()).(*pipelinex.RuntimeImpl)
+			_, err := runtime.BuildGraph(config)
+			if !errors.Is(err, pipelinex.ErrInvalidGraph) || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Expected error containing %q, got %v", tt.message, err)
+			}
+		})
+	}
+}
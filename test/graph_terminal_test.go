@@ -0,0 +1,157 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/chenyingqiao/pipelinex"
+)
+
+// TestParseGraphEdges_EntriesAndTerminals 测试 [*] 标记入口和终止节点
+func TestParseGraphEdges_EntriesAndTerminals(t *testing.T) {
+	config := &pipelinex.PipelineConfig{
+		Nodes: map[string]pipelinex.NodeConfig{
+			"Checkout": {},
+			"Build":    {},
+			"Deploy":   {},
+			"Notify":   {},
+		},
+		Graph: `stateDiagram-v2
+    [*] --> Checkout
+    Checkout --> Build
+    Build --> Deploy
+    Build --> Notify
+    Deploy --> [*]
+    Notify --> [*]`,
+	}
+
+	runtime := pipelinex.NewRuntime(context.Background()).(*pipelinex.RuntimeImpl)
+	graph, err := runtime.BuildGraph(config)
+	if err != nil {
+		t.Fatalf("BuildGraph failed: %v", err)
+	}
+	if entries := graph.Entries(); !reflect.DeepEqual(entries, []string{"Checkout"}) {
+		t.Errorf("Entries = %v, expected [Checkout]", entries)
+	}
+	if terminals := graph.Terminals(); !reflect.DeepEqual(terminals, []string{"Deploy", "Notify"}) {
+		t.Errorf("Terminals = %v, expected [Deploy Notify]", terminals)
+	}
+}
+
+// TestParseGraphEdges_EntryErrors 测试从 [*] 无法到达的节点和带条件的入口
+func TestParseGraphEdges_EntryErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		graph   string
+		target  error
+		message string
+	}{
+		{
+			name: "从入口无法到达的节点",
+			graph: `stateDiagram-v2
+    [*] --> A
+    A --> B
+    C --> B`,
+			target:  pipelinex.ErrUnreachableNode,
+			message: `line 4, column 5: node not reachable from [*]: "C"`,
+		},
+		{
+			name: "入口带条件",
+			graph: `stateDiagram-v2
+    [*] --> A: {{ Param.env == "prod" }}
+    A --> B
+    A --> C`,
+			target:  pipelinex.ErrInvalidGraph,
+			message: `line 2, column 5: invalid graph: condition on entry transition [*]->A is not supported`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &pipelinex.PipelineConfig{
+				Nodes: map[string]pipelinex.NodeConfig{"A": {}, "B": {}, "C": {}},
+				Graph: tt.graph,
+			}
+			runtime := pipelinex.NewRuntime(context.Background()).(*pipelinex.RuntimeImpl)
+			_, err := runtime.BuildGraph(config)
+			if !errors.Is(err, tt.target) || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Expected error containing %q, got %v", tt.message, err)
+			}
+		})
+	}
+}
+
+// TestDGAGraph_TraversalFromEntries 测试标记入口后只从入口节点开始遍历
+func TestDGAGraph_TraversalFromEntries(t *testing.T) {
+	graph := pipelinex.NewDGAGraph()
+	a := pipelinex.NewDGANode("a", pipelinex.StatusUnknown)
+	b := pipelinex.NewDGANode("b", pipelinex.StatusUnknown)
+	c := pipelinex.NewDGANode("c", pipelinex.StatusUnknown)
+	graph.AddVertex(a)
+	graph.AddVertex(b)
+	graph.AddVertex(c)
+	if err := graph.AddEdge(pipelinex.NewDGAEdge(a, b)); err != nil {
+		t.Fatalf("AddEdge(a->b): %v", err)
+	}
+	graph.AddEntry(a)
+
+	var mu sync.Mutex
+	var visited []string
+	err := graph.Traversal(context.Background(), pipelinex.NewEvaluationContext(), func(ctx context.Context, node pipelinex.Node) error {
+		mu.Lock()
+		defer mu.Unlock()
+		visited = append(visited, node.Id())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Traversal failed: %v", err)
+	}
+	sort.Strings(visited)
+	if !reflect.DeepEqual(visited, []string{"a", "b"}) {
+		t.Errorf("Visited = %v, expected [a b]", visited)
+	}
+}
+
+// TestRuntimeImpl_TerminalNotReached 测试条件边跳过所有终止节点时流水线失败
+func TestRuntimeImpl_TerminalNotReached(t *testing.T) {
+	config := `
+Param:
+  env: ENV
+Graph: |
+  stateDiagram-v2
+    [*] --> Build
+    Build --> Deploy: {{ Param.env == "prod" }}
+    Deploy --> [*]
+Nodes:
+  Build:
+    steps: [{name: build, run: "true"}]
+  Deploy:
+    steps: [{name: deploy, run: "true"}]
+`
+
+	tests := []struct {
+		env     string
+		wantErr bool
+	}{
+		{env: "prod", wantErr: false},
+		{env: "dev", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			ctx := context.Background()
+			runtime := pipelinex.NewRuntime(ctx)
+			runtime.SetStepRunner(pipelinex.NewLocalStepRunner())
+
+			_, err := runtime.RunSync(ctx, "test-terminal-"+tt.env, strings.Replace(config, "ENV", tt.env, 1), nil)
+			if tt.wantErr != errors.Is(err, pipelinex.ErrNoTerminal) {
+				t.Errorf("RunSync error = %v, expected ErrNoTerminal: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
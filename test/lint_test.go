@@ -0,0 +1,135 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chenyingqiao/pipelinex"
+)
+
+func TestRuntimeImpl_StrictEdgeCondition(t *testing.T) {
+	ctx := context.Background()
+	engine := pipelinex.NewPongo2TemplateEngineWithCache(64)
+	engine.SetStrict(true)
+	runtime := pipelinex.NewRuntime(ctx)
+	runtime.SetTemplateEngine(engine)
+
+	// 拼写错误的属性和未定义的顶层变量
+	for i, variable := range []string{"Param.enviroment", "Params.env"} {
+		config := `
+Param:
+  env: prod
+Graph: |
+  stateDiagram-v2
+    [*] --> Build
+    Build --> Deploy: {{ ` + variable + ` == "prod" }}
+    Deploy --> [*]
+Nodes:
+  Build: {}
+  Deploy: {}
+`
+
+		_, err := runtime.RunSync(ctx, fmt.Sprintf("test-strict-edge-%d", i), config, nil)
+		if !errors.Is(err, pipelinex.ErrUndefinedVariable) {
+			t.Fatalf("Expected undefined variable error for %s, got %v", variable, err)
+		}
+		if !strings.Contains(err.Error(), "Build->Deploy") || !strings.Contains(err.Error(), `"`+variable+`"`) {
+			t.Errorf("Expected error to name edge and variable, got %v", err)
+		}
+	}
+}
+
+func TestRuntimeImpl_Lint(t *testing.T) {
+	runtime := pipelinex.NewRuntime(context.Background())
+
+	config := `
+Param:
+  env: prod
+Metadate:
+  type: in-config
+  data:
+    region: eu
+Graph: |
+  stateDiagram-v2
+    [*] --> Build
+    Build --> Deploy: {{ Param.env == "prod" and version and nodes.Build.outputs.version }}
+    Build --> Rollback: {{ Param.enviroment == "prod" or nodeId }}
+    Deploy --> [*]
+Nodes:
+  Build:
+    steps:
+      - name: version
+        run: echo "version=1.0" >> "$PIPELINEX_OUTPUT"
+      - name: digest
+        run: ./build.sh
+        outputs: [digest]
+  Deploy:
+    image: registry/app:{{ nodes.Build.outputs.version }}
+    Config:
+      namespace: "{{ region }}-{{ nodeId }}"
+      args: ["--digest={{ digest }}", "--tag={{ nodes.Build.outputs.tag }}"]
+    steps:
+      - name: apply
+        run: echo "{{ regoin|upper }} {{ missing|default:'x' }}"
+  Rollback:
+    steps:
+      - run: echo "{% if %}"
+`
+
+	issues, err := runtime.Lint(config)
+	if err != nil {
+		t.Fatalf("Lint failed: %v", err)
+	}
+	var got []string
+	for _, issue := range issues {
+		got = append(got, issue.Location+" "+issue.Variable)
+	}
+	// 边条件求值时没有当前节点和 nodes，nodeId 和 nodes 未定义
+	expected := []string{
+		"edge Build->Deploy nodes.Build.outputs.version",
+		"edge Build->Rollback Param.enviroment",
+		"edge Build->Rollback nodeId",
+		"node Deploy Config.args[1] nodes.Build.outputs.tag",
+		"node Deploy step apply regoin",
+		"node Rollback step #1 ",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Lint issues = %q, expected %q", got, expected)
+	}
+	if !strings.Contains(issues[len(issues)-1].String(), "invalid expression syntax") {
+		t.Errorf("Expected syntax error message, got %s", issues[len(issues)-1])
+	}
+}
+
+func TestRuntimeImpl_LintExternalMetadata(t *testing.T) {
+	runtime := pipelinex.NewRuntime(context.Background())
+
+	// 外部元数据存储的 key 无法静态获取，只检查 Param 和 nodes
+	config := `
+Metadate:
+  type: redis
+Graph: |
+  stateDiagram-v2
+    [*] --> A
+    A --> B: {{ approved and Param.force and nodes.C.outputs.ok }}
+Nodes:
+  A: {}
+  B: {}
+`
+
+	issues, err := runtime.Lint(config)
+	if err != nil {
+		t.Fatalf("Lint failed: %v", err)
+	}
+	var got []string
+	for _, issue := range issues {
+		got = append(got, issue.Variable)
+	}
+	if !reflect.DeepEqual(got, []string{"Param.force", "nodes.C.outputs.ok"}) {
+		t.Errorf("Unexpected issues: %v", issues)
+	}
+}
@@ -0,0 +1,239 @@
+package test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chenyingqiao/pipelinex"
+)
+
+// collectEntries 读取通道中的全部日志，超时则失败
+func collectEntries(t *testing.T, ch <-chan pipelinex.Entry) []pipelinex.Entry {
+	t.Helper()
+	entries := []pipelinex.Entry{}
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case entry, ok := <-ch:
+			if !ok {
+				return entries
+			}
+			entries = append(entries, entry)
+		case <-timeout:
+			t.Fatalf("Timed out waiting for log stream to close, got %d entries", len(entries))
+			return entries
+		}
+	}
+}
+
+// recordPusher 记录收到日志的推送器
+type recordPusher struct {
+	mu      sync.Mutex
+	entries []pipelinex.Entry
+}
+
+func (p *recordPusher) Push(ctx context.Context, entry pipelinex.Entry) error {
+	return p.PushBatch(ctx, []pipelinex.Entry{entry})
+}
+
+func (p *recordPusher) PushBatch(ctx context.Context, entries []pipelinex.Entry) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries = append(p.entries, entries...)
+	return nil
+}
+
+func (p *recordPusher) Close() error {
+	return nil
+}
+
+func TestLogBroker_ReplayAndFollow(t *testing.T) {
+	broker := pipelinex.NewLogBroker("build-1", nil)
+	ctx := context.Background()
+
+	broker.Push(ctx, pipelinex.Entry{Node: "a", Message: "first"})
+
+	ch, err := broker.Subscribe(ctx, "")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	broker.Push(ctx, pipelinex.Entry{Node: "b", Message: "second"})
+	broker.Close()
+
+	entries := collectEntries(t, ch)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].Message != "first" || entries[1].Message != "second" {
+		t.Errorf("Unexpected entry order: %v", entries)
+	}
+	if entries[0].BuildID != "build-1" {
+		t.Errorf("Expected BuildID build-1, got %q", entries[0].BuildID)
+	}
+}
+
+func TestLogBroker_NodeFilter(t *testing.T) {
+	broker := pipelinex.NewLogBroker("build-1", nil)
+	ctx := context.Background()
+
+	ch, _ := broker.Subscribe(ctx, "a")
+
+	broker.Push(ctx, pipelinex.Entry{Node: "a", Message: "a1"})
+	broker.Push(ctx, pipelinex.Entry{Node: "b", Message: "b1"})
+	broker.Push(ctx, pipelinex.Entry{Node: "a", Message: "a2"})
+	broker.NodeDone("a")
+
+	// 节点结束后订阅应该关闭，即使流水线仍在运行
+	entries := collectEntries(t, ch)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries for node a, got %d", len(entries))
+	}
+	for _, entry := range entries {
+		if entry.Node != "a" {
+			t.Errorf("Expected only node a entries, got %q", entry.Node)
+		}
+	}
+}
+
+func TestLogBroker_MultipleSubscribers(t *testing.T) {
+	next := &recordPusher{}
+	broker := pipelinex.NewLogBroker("build-1", next)
+	ctx := context.Background()
+
+	ch1, _ := broker.Subscribe(ctx, "")
+	ch2, _ := broker.Subscribe(ctx, "")
+
+	for i := 0; i < 100; i++ {
+		broker.Push(ctx, pipelinex.Entry{Node: "a", Message: "line"})
+	}
+	broker.Close()
+
+	if n := len(collectEntries(t, ch1)); n != 100 {
+		t.Errorf("Subscriber 1 expected 100 entries, got %d", n)
+	}
+	if n := len(collectEntries(t, ch2)); n != 100 {
+		t.Errorf("Subscriber 2 expected 100 entries, got %d", n)
+	}
+	if len(next.entries) != 100 {
+		t.Errorf("Downstream pusher expected 100 entries, got %d", len(next.entries))
+	}
+
+	if err := broker.Push(ctx, pipelinex.Entry{Node: "a"}); err == nil {
+		t.Error("Expected error when pushing to closed stream")
+	}
+}
+
+func TestLogBroker_SubscribeCancel(t *testing.T) {
+	broker := pipelinex.NewLogBroker("build-1", nil)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ch, _ := broker.Subscribe(ctx, "")
+	cancel()
+
+	// ctx取消后订阅通道应该关闭
+	collectEntries(t, ch)
+}
+
+func TestRuntimeImpl_SubscribeLogs(t *testing.T) {
+	ctx := context.Background()
+	runtime := pipelinex.NewRuntime(ctx)
+	pusher := &recordPusher{}
+	runtime.SetPusher(pusher)
+
+	config := `
+Graph: |
+  stateDiagram-v2
+    [*] --> Task1
+    Task1 --> Task2
+    Task2 --> [*]
+Nodes:
+  Task1: {}
+  Task2: {}
+`
+
+	pipeline, err := runtime.RunAsync(ctx, "test-logs-pipeline", config, nil)
+	if err != nil {
+		t.Fatalf("RunAsync failed: %v", err)
+	}
+
+	all, err := runtime.SubscribeLogs(ctx, "test-logs-pipeline", "")
+	if err != nil {
+		t.Fatalf("SubscribeLogs failed: %v", err)
+	}
+	task1, err := runtime.SubscribeLogs(ctx, "test-logs-pipeline", "Task1")
+	if err != nil {
+		t.Fatalf("SubscribeLogs failed: %v", err)
+	}
+
+	// Task1 的订阅在节点结束时关闭，此时流水线仍未结束
+	task1Entries := collectEntries(t, task1)
+	if len(task1Entries) != 2 {
+		t.Errorf("Expected 2 entries for Task1, got %d", len(task1Entries))
+	}
+
+	allEntries := collectEntries(t, all)
+	if len(allEntries) != 4 {
+		t.Errorf("Expected 4 entries for pipeline, got %d", len(allEntries))
+	}
+	<-pipeline.Done()
+
+	// 流水线结束后订阅仍可回放完整日志
+	replay, err := runtime.SubscribeLogs(ctx, "test-logs-pipeline", "Task2")
+	if err != nil {
+		t.Fatalf("SubscribeLogs after finish failed: %v", err)
+	}
+	if n := len(collectEntries(t, replay)); n != 2 {
+		t.Errorf("Expected 2 replayed entries for Task2, got %d", n)
+	}
+
+	pusher.mu.Lock()
+	if len(pusher.entries) != 4 {
+		t.Errorf("Expected runtime pusher to receive 4 entries, got %d", len(pusher.entries))
+	}
+	pusher.mu.Unlock()
+
+	runtime.Rm("test-logs-pipeline")
+	if _, err := runtime.SubscribeLogs(ctx, "test-logs-pipeline", ""); err == nil {
+		t.Error("Expected error after pipeline logs removed")
+	}
+}
+
+// TestRuntimeImpl_LogRetention 测试流水线结束超过保留时间后日志被移除
+func TestRuntimeImpl_LogRetention(t *testing.T) {
+	ctx := context.Background()
+	runtime := pipelinex.NewRuntime(ctx).(*pipelinex.RuntimeImpl)
+	runtime.SetLogRetention(50 * time.Millisecond)
+
+	config := "Nodes:\n  Task1: {}\n"
+	if _, err := runtime.RunSync(ctx, "retention-old", config, nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if n := len(collectEntries(t, mustSubscribe(t, runtime, "retention-old"))); n != 2 {
+		t.Errorf("Expected 2 entries within retention, got %d", n)
+	}
+
+	// 下一次登记流水线时移除超过保留时间的日志
+	time.Sleep(100 * time.Millisecond)
+	if _, err := runtime.RunSync(ctx, "retention-new", config, nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if _, err := runtime.SubscribeLogs(ctx, "retention-old", ""); err == nil {
+		t.Error("Expected logs to be evicted after retention")
+	}
+	if n := len(collectEntries(t, mustSubscribe(t, runtime, "retention-new"))); n != 2 {
+		t.Errorf("Expected 2 entries for the new pipeline, got %d", n)
+	}
+}
+
+// mustSubscribe 订阅流水线的全部日志
+func mustSubscribe(t *testing.T, runtime pipelinex.Runtime, id string) <-chan pipelinex.Entry {
+	t.Helper()
+	ch, err := runtime.SubscribeLogs(context.Background(), id, "")
+	if err != nil {
+		t.Fatalf("SubscribeLogs(%s) failed: %v", id, err)
+	}
+	return ch
+}
@@ -0,0 +1,307 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chenyingqiao/pipelinex"
+)
+
+// newHTTPMetadataServer 启动基于内存存储的参考服务端
+func newHTTPMetadataServer(t *testing.T) (*httptest.Server, pipelinex.MetadataStore) {
+	t.Helper()
+	backend, _ := pipelinex.NewMemoryMetadataStore(pipelinex.MetadataConfig{Type: "memory"})
+	server := httptest.NewServer(pipelinex.NewHTTPMetadataHandler(backend))
+	t.Cleanup(server.Close)
+	return server, backend
+}
+
+// newHTTPMetadataStore 创建连接到指定地址的HTTP元数据存储
+func newHTTPMetadataStore(t *testing.T, data map[string]interface{}) *pipelinex.HTTPMetadataStore {
+	t.Helper()
+	store, err := pipelinex.NewHTTPMetadataStore(pipelinex.MetadataConfig{Type: "http", Data: data})
+	if err != nil {
+		t.Fatalf("NewHTTPMetadataStore failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestHTTPMetadataStore_ListAndBulk(t *testing.T) {
+	ctx := context.Background()
+	server, _ := newHTTPMetadataServer(t)
+	store := newHTTPMetadataStore(t, map[string]interface{}{"url": server.URL})
+
+	if err := store.SetMany(ctx, map[string]string{"app.version": "1.0", "app.name": "demo", "other": "x"}); err != nil {
+		t.Fatalf("SetMany failed: %v", err)
+	}
+
+	keys, err := store.List(ctx, "app.")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if !reflect.DeepEqual(keys, []string{"app.name", "app.version"}) {
+		t.Errorf("Unexpected keys: %v", keys)
+	}
+
+	values, err := store.GetMany(ctx, []string{"app.version", "missing"})
+	if err != nil {
+		t.Fatalf("GetMany failed: %v", err)
+	}
+	if len(values) != 1 || values["app.version"] != "1.0" {
+		t.Errorf("Unexpected values: %v", values)
+	}
+
+	if err := store.Delete(ctx, "other"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Get(ctx, "other"); !errors.Is(err, pipelinex.ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound after delete, got %v", err)
+	}
+	if err := store.Delete(ctx, "other"); err != nil {
+		t.Errorf("Expected deleting missing key to succeed, got %v", err)
+	}
+}
+
+func TestHTTPMetadataStore_EscapedKeys(t *testing.T) {
+	ctx := context.Background()
+	server, backend := newHTTPMetadataServer(t)
+	store := newHTTPMetadataStore(t, map[string]interface{}{"url": server.URL + "/"})
+
+	key := "deploy:a/b c?d&e=%"
+	if err := store.Set(ctx, key, "value"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if val, err := backend.Get(ctx, key); err != nil || val != "value" {
+		t.Errorf("Expected backend to receive unescaped key, got %q, %v", val, err)
+	}
+	if val, err := store.Get(ctx, key); err != nil || val != "value" {
+		t.Errorf("Expected round trip of escaped key, got %q, %v", val, err)
+	}
+}
+
+func TestHTTPMetadataStore_SetWithTTL(t *testing.T) {
+	var payload map[string]any
+	var method, path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.EscapedPath()
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	store := newHTTPMetadataStore(t, map[string]interface{}{"url": server.URL + "/metadata", "method": "post"})
+	if err := store.SetWithTTL(context.Background(), "version", "1.0", time.Minute); err != nil {
+		t.Fatalf("SetWithTTL failed: %v", err)
+	}
+	if method != http.MethodPost || path != "/metadata/version" {
+		t.Errorf("Expected POST /metadata/version, got %s %s", method, path)
+	}
+	if payload["value"] != "1.0" || payload["ttl"] != float64(60) {
+		t.Errorf("Expected value=1.0 ttl=60 in payload, got %v", payload)
+	}
+
+	// 不足一秒的 ttl 向上取整，不能变成表示永不过期的 0
+	for ttl, expected := range map[time.Duration]float64{500 * time.Millisecond: 1, 1500 * time.Millisecond: 2} {
+		payload = nil
+		if err := store.SetWithTTL(context.Background(), "version", "1.0", ttl); err != nil {
+			t.Fatalf("SetWithTTL failed: %v", err)
+		}
+		if payload["ttl"] != expected {
+			t.Errorf("SetWithTTL(%s): expected ttl=%v in payload, got %v", ttl, expected, payload)
+		}
+	}
+}
+
+func TestHTTPMetadataStore_CompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+	server, backend := newHTTPMetadataServer(t)
+	store := newHTTPMetadataStore(t, map[string]interface{}{"url": server.URL})
+
+	if ok, err := store.CompareAndSwap(ctx, "lock", "", "a"); err != nil || !ok {
+		t.Fatalf("Expected CAS to succeed, got %v, %v", ok, err)
+	}
+	if ok, err := store.CompareAndSwap(ctx, "lock", "", "b"); err != nil || ok {
+		t.Errorf("Expected CAS conflict, got %v, %v", ok, err)
+	}
+	if ok, err := store.CompareAndSwap(ctx, "lock", "a", "b"); err != nil || !ok {
+		t.Errorf("Expected CAS a->b to succeed, got %v, %v", ok, err)
+	}
+	if ok, err := store.CompareAndSwap(ctx, "lock", "a", "c"); err != nil || ok {
+		t.Errorf("Expected stale CAS to fail, got %v, %v", ok, err)
+	}
+	if val, _ := backend.Get(ctx, "lock"); val != "b" {
+		t.Errorf("Expected lock=b, got %q", val)
+	}
+
+	store.Incr(ctx, "counter", 2)
+	if val, err := store.Incr(ctx, "counter", 3); err != nil || val != 5 {
+		t.Errorf("Expected counter=5, got %d, %v", val, err)
+	}
+}
+
+func TestHTTPMetadataHandler_ETag(t *testing.T) {
+	server, _ := newHTTPMetadataServer(t)
+	store := newHTTPMetadataStore(t, map[string]interface{}{"url": server.URL})
+	store.Set(context.Background(), "version", "1.0")
+
+	resp, err := http.Get(server.URL + "/version")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var envelope struct {
+		Key     string `json:"key"`
+		Value   string `json:"value"`
+		Version string `json:"version"`
+	}
+	json.NewDecoder(resp.Body).Decode(&envelope)
+	if envelope.Key != "version" || envelope.Value != "1.0" || envelope.Version == "" {
+		t.Errorf("Unexpected envelope: %+v", envelope)
+	}
+	if resp.Header.Get("ETag") != `"`+envelope.Version+`"` {
+		t.Errorf("Expected ETag to match version, got %q", resp.Header.Get("ETag"))
+	}
+
+	// 版本不匹配的条件写入返回 412
+	req, _ := http.NewRequest(http.MethodPut, server.URL+"/version", strings.NewReader(`{"value":"2.0"}`))
+	req.Header.Set("If-Match", `"stale"`)
+	resp2, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT failed: %v", err)
+	}
+	resp2.Body.Close()
+	if resp2.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412, got %d", resp2.StatusCode)
+	}
+}
+
+func TestHTTPMetadataStore_Retry(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"key":"version","value":"1.0"}`))
+	}))
+	defer server.Close()
+
+	store := newHTTPMetadataStore(t, map[string]interface{}{"url": server.URL, "retry_backoff": "1ms"})
+	if val, err := store.Get(context.Background(), "version"); err != nil || val != "1.0" {
+		t.Errorf("Expected value after retries, got %q, %v", val, err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+
+	// 超过重试次数后返回错误
+	atomic.StoreInt32(&attempts, -10)
+	noRetry := newHTTPMetadataStore(t, map[string]interface{}{"url": server.URL, "retries": 1, "retry_backoff": "1ms"})
+	if _, err := noRetry.Get(context.Background(), "version"); err == nil {
+		t.Error("Expected error after retries exhausted")
+	}
+	if n := atomic.LoadInt32(&attempts) + 10; n != 2 {
+		t.Errorf("Expected 2 attempts, got %d", n)
+	}
+}
+
+func TestHTTPMetadataStore_Auth(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	bearer := newHTTPMetadataStore(t, map[string]interface{}{"url": server.URL, "token": "secret"})
+	bearer.Get(context.Background(), "version")
+	if authorization != "Bearer secret" {
+		t.Errorf("Expected bearer token, got %q", authorization)
+	}
+
+	basic := newHTTPMetadataStore(t, map[string]interface{}{"url": server.URL, "username": "ci", "password": "pw"})
+	basic.Get(context.Background(), "version")
+	if authorization != "Basic Y2k6cHc=" {
+		t.Errorf("Expected basic auth, got %q", authorization)
+	}
+}
+
+func TestHTTPMetadataStore_TLS(t *testing.T) {
+	backend, _ := pipelinex.NewMemoryMetadataStore(pipelinex.MetadataConfig{Type: "memory"})
+	server := httptest.NewTLSServer(pipelinex.NewHTTPMetadataHandler(backend))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	store := newHTTPMetadataStore(t, map[string]interface{}{
+		"url": server.URL,
+		"tls": map[interface{}]interface{}{"ca_file": caFile},
+	})
+	if err := store.Set(context.Background(), "version", "1.0"); err != nil {
+		t.Fatalf("Set over TLS failed: %v", err)
+	}
+
+	untrusted := newHTTPMetadataStore(t, map[string]interface{}{"url": server.URL, "retries": 0})
+	if err := untrusted.Set(context.Background(), "version", "1.0"); err == nil {
+		t.Error("Expected certificate verification error without ca_file")
+	}
+}
+
+// TestHTTPMetadataStore_LegacyMethod 测试旧配置中作为读取方法的 method: GET 仍被接受，写入使用 PUT
+func TestHTTPMetadataStore_LegacyMethod(t *testing.T) {
+	var method string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	config := pipelinex.MetadataConfig{Type: "http", Data: map[string]interface{}{"url": server.URL, "method": "GET"}}
+	if err := pipelinex.NewMetadataStoreFactory().Validate(config); err != nil {
+		t.Fatalf("Expected legacy method to pass validation, got %v", err)
+	}
+	store := newHTTPMetadataStore(t, config.Data)
+	if err := store.Set(context.Background(), "version", "1.0"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if method != http.MethodPut {
+		t.Errorf("Expected writes to use PUT, got %s", method)
+	}
+}
+
+func TestMetadataStoreFactory_ValidateHTTP(t *testing.T) {
+	factory := pipelinex.NewMetadataStoreFactory()
+	tests := []struct {
+		name string
+		data map[string]interface{}
+	}{
+		{"negative retries", map[string]interface{}{"url": "http://x", "retries": -1}},
+		{"bad backoff", map[string]interface{}{"url": "http://x", "retry_backoff": "soon"}},
+		{"token and basic", map[string]interface{}{"url": "http://x", "token": "t", "username": "u"}},
+		{"missing ca file", map[string]interface{}{"url": "http://x", "tls": map[string]interface{}{"ca_file": "/nonexistent.pem"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := factory.Validate(pipelinex.MetadataConfig{Type: "http", Data: tt.data}); err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+}
@@ -0,0 +1,988 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chenyingqiao/pipelinex"
+)
+
+func TestMemoryMetadataStore_SetGetDelete(t *testing.T) {
+	ctx := context.Background()
+	store, err := pipelinex.NewMemoryMetadataStore(pipelinex.MetadataConfig{
+		Type: "memory",
+		Data: map[string]interface{}{"version": "1.0.0", "replicas": 3},
+	})
+	if err != nil {
+		t.Fatalf("NewMemoryMetadataStore failed: %v", err)
+	}
+
+	if val, err := store.Get(ctx, "replicas"); err != nil || val != "3" {
+		t.Errorf("Expected initial replicas=3, got %q, %v", val, err)
+	}
+
+	if err := store.Set(ctx, "version", "1.0.1"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if val, _ := store.Get(ctx, "version"); val != "1.0.1" {
+		t.Errorf("Expected version=1.0.1, got %q", val)
+	}
+
+	if err := store.Delete(ctx, "version"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Get(ctx, "version"); err == nil {
+		t.Error("Expected error for deleted key")
+	}
+}
+
+func TestMemoryMetadataStore_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	store, _ := pipelinex.NewMemoryMetadataStore(pipelinex.MetadataConfig{Type: "memory"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("key-%d", i)
+			store.Set(ctx, key, "value")
+			store.Get(ctx, key)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 50; i++ {
+		if _, err := store.Get(ctx, fmt.Sprintf("key-%d", i)); err != nil {
+			t.Errorf("Expected key-%d to exist: %v", i, err)
+		}
+	}
+}
+
+func TestFileMetadataStore_PersistAcrossInstances(t *testing.T) {
+	ctx := context.Background()
+	config := pipelinex.MetadataConfig{
+		Type: "file",
+		Data: map[string]interface{}{
+			"path": filepath.Join(t.TempDir(), "meta", "store.json"),
+		},
+	}
+
+	first, err := pipelinex.NewFileMetadataStore(config)
+	if err != nil {
+		t.Fatalf("NewFileMetadataStore failed: %v", err)
+	}
+	if _, err := first.Get(ctx, "version"); err == nil {
+		t.Error("Expected error for missing key in empty store")
+	}
+	if err := first.Set(ctx, "version", "2.0.0"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	first.Close()
+
+	// 模拟下一次运行，重新打开同一个文件
+	second, err := pipelinex.NewFileMetadataStore(config)
+	if err != nil {
+		t.Fatalf("NewFileMetadataStore failed: %v", err)
+	}
+	if val, err := second.Get(ctx, "version"); err != nil || val != "2.0.0" {
+		t.Errorf("Expected version=2.0.0, got %q, %v", val, err)
+	}
+
+	if err := second.Delete(ctx, "version"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := first.Get(ctx, "version"); err == nil {
+		t.Error("Expected deleted key to be missing for other instance")
+	}
+}
+
+func TestFileMetadataStore_ConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	config := pipelinex.MetadataConfig{
+		Type: "file",
+		Data: map[string]interface{}{"path": filepath.Join(t.TempDir(), "store.json")},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// 每个协程使用独立实例，模拟多个流水线同时写入
+			store, _ := pipelinex.NewFileMetadataStore(config)
+			if err := store.Set(ctx, fmt.Sprintf("key-%d", i), "value"); err != nil {
+				t.Errorf("Set failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	store, _ := pipelinex.NewFileMetadataStore(config)
+	for i := 0; i < 20; i++ {
+		if _, err := store.Get(ctx, fmt.Sprintf("key-%d", i)); err != nil {
+			t.Errorf("Expected key-%d to survive concurrent writes: %v", i, err)
+		}
+	}
+}
+
+func TestFileMetadataStore_MissingPath(t *testing.T) {
+	_, err := pipelinex.NewFileMetadataStore(pipelinex.MetadataConfig{Type: "file"})
+	if err == nil {
+		t.Error("Expected error when path is missing")
+	}
+}
+
+func TestMetadataStoreFactory_Create(t *testing.T) {
+	factory := pipelinex.NewMetadataStoreFactory()
+
+	tests := []struct {
+		name    string
+		config  pipelinex.MetadataConfig
+		wantErr bool
+	}{
+		{"in-config", pipelinex.MetadataConfig{Type: "in-config"}, false},
+		{"memory", pipelinex.MetadataConfig{Type: "memory"}, false},
+		{"file", pipelinex.MetadataConfig{Type: "file", Data: map[string]interface{}{
+			"path": filepath.Join(t.TempDir(), "store.json"),
+		}}, false},
+		{"unknown", pipelinex.MetadataConfig{Type: "unknown"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := factory.Create(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Create(%s) error = %v, wantErr %v", tt.config.Type, err, tt.wantErr)
+			}
+			if store != nil {
+				store.Close()
+			}
+		})
+	}
+}
+
+func TestMetadataStore_ListAndBulk(t *testing.T) {
+	ctx := context.Background()
+	memory, _ := pipelinex.NewMemoryMetadataStore(pipelinex.MetadataConfig{Type: "memory"})
+	file, _ := pipelinex.NewFileMetadataStore(pipelinex.MetadataConfig{
+		Type: "file",
+		Data: map[string]interface{}{"path": filepath.Join(t.TempDir(), "store.json")},
+	})
+
+	stores := map[string]pipelinex.MetadataStore{
+		"memory": memory,
+		"file":   file,
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			err := store.SetMany(ctx, map[string]string{
+				"build.version": "1.2.3",
+				"build.digest":  "sha256:abc",
+				"deploy.env":    "prod",
+			})
+			if err != nil {
+				t.Fatalf("SetMany failed: %v", err)
+			}
+
+			keys, err := store.List(ctx, "build.")
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if !reflect.DeepEqual(keys, []string{"build.digest", "build.version"}) {
+				t.Errorf("Unexpected keys for prefix build.: %v", keys)
+			}
+
+			all, _ := store.List(ctx, "")
+			if len(all) != 3 {
+				t.Errorf("Expected 3 keys, got %v", all)
+			}
+
+			values, err := store.GetMany(ctx, []string{"build.version", "deploy.env", "missing"})
+			if err != nil {
+				t.Fatalf("GetMany failed: %v", err)
+			}
+			expected := map[string]string{"build.version": "1.2.3", "deploy.env": "prod"}
+			if !reflect.DeepEqual(values, expected) {
+				t.Errorf("GetMany = %v, expected %v", values, expected)
+			}
+
+			if _, err := store.Get(ctx, "missing"); !errors.Is(err, pipelinex.ErrKeyNotFound) {
+				t.Errorf("Expected ErrKeyNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestInConfigMetadataStore_List(t *testing.T) {
+	ctx := context.Background()
+	store, _ := pipelinex.NewInConfigMetadataStore(pipelinex.MetadataConfig{
+		Type: "in-config",
+		Data: map[string]interface{}{"a1": "x", "a2": "y", "b": "z"},
+	})
+
+	keys, _ := store.List(ctx, "a")
+	if !reflect.DeepEqual(keys, []string{"a1", "a2"}) {
+		t.Errorf("Unexpected keys: %v", keys)
+	}
+	if err := store.SetMany(ctx, map[string]string{"a1": "v"}); err == nil {
+		t.Error("Expected error for read-only store")
+	}
+}
+
+func TestPipeline_Metadata_FromWritableStore(t *testing.T) {
+	ctx := context.Background()
+	store, _ := pipelinex.NewMemoryMetadataStore(pipelinex.MetadataConfig{Type: "memory"})
+	store.Set(ctx, "env", "prod")
+
+	graph := pipelinex.NewDGAGraph()
+	nodeA := pipelinex.NewDGANode("a", pipelinex.StatusUnknown)
+	nodeB := pipelinex.NewDGANode("b", pipelinex.StatusUnknown)
+	graph.AddVertex(nodeA)
+	graph.AddVertex(nodeB)
+	graph.AddEdge(pipelinex.NewConditionalEdge(nodeA, nodeB, "{{ env == 'prod' }}"))
+
+	pipeline := pipelinex.NewPipeline(ctx)
+	pipeline.SetGraph(graph)
+	pipeline.SetMetadata(store)
+
+	if pipeline.Metadata()["env"] != "prod" {
+		t.Errorf("Expected metadata env=prod, got %v", pipeline.Metadata())
+	}
+
+	// 条件边依赖 memory 存储中的数据
+	visited := []string{}
+	evalCtx := pipelinex.NewEvaluationContext().WithPipeline(pipeline)
+	graph.Traversal(ctx, evalCtx, func(ctx context.Context, node pipelinex.Node) error {
+		visited = append(visited, node.Id())
+		return nil
+	})
+	if len(visited) != 2 {
+		t.Errorf("Expected conditional edge to pass with memory metadata, visited %v", visited)
+	}
+}
+
+func TestMemoryMetadataStore_TTL(t *testing.T) {
+	ctx := context.Background()
+	store, _ := pipelinex.NewMemoryMetadataStore(pipelinex.MetadataConfig{Type: "memory"})
+
+	store.SetWithTTL(ctx, "short", "v", 50*time.Millisecond)
+	store.Set(ctx, "forever", "v")
+
+	if _, err := store.Get(ctx, "short"); err != nil {
+		t.Fatalf("Expected key before expiry: %v", err)
+	}
+
+	time.Sleep(80 * time.Millisecond)
+
+	if _, err := store.Get(ctx, "short"); !errors.Is(err, pipelinex.ErrKeyNotFound) {
+		t.Errorf("Expected expired key to be not found, got %v", err)
+	}
+	keys, _ := store.List(ctx, "")
+	if !reflect.DeepEqual(keys, []string{"forever"}) {
+		t.Errorf("Expected only non-expiring key, got %v", keys)
+	}
+}
+
+func TestScopedMetadataStore_Isolation(t *testing.T) {
+	ctx := context.Background()
+	shared, _ := pipelinex.NewMemoryMetadataStore(pipelinex.MetadataConfig{Type: "memory"})
+
+	buildA := pipelinex.NewScopedMetadataStore(shared, "build:run-1", 0)
+	buildB := pipelinex.NewScopedMetadataStore(shared, "build:run-2", 0)
+	global := pipelinex.NewScopedMetadataStore(shared, "", 0)
+
+	buildA.Set(ctx, "version", "1.0.0")
+	buildB.Set(ctx, "version", "2.0.0")
+	global.Set(ctx, "registry", "myregistry.com")
+
+	if val, _ := buildA.Get(ctx, "version"); val != "1.0.0" {
+		t.Errorf("Expected run-1 version 1.0.0, got %q", val)
+	}
+	if val, _ := buildB.Get(ctx, "version"); val != "2.0.0" {
+		t.Errorf("Expected run-2 version 2.0.0, got %q", val)
+	}
+
+	keys, _ := buildA.List(ctx, "")
+	if !reflect.DeepEqual(keys, []string{"version"}) {
+		t.Errorf("Expected namespaced keys without prefix, got %v", keys)
+	}
+	values, _ := buildB.GetMany(ctx, []string{"version", "registry"})
+	if !reflect.DeepEqual(values, map[string]string{"version": "2.0.0"}) {
+		t.Errorf("Unexpected GetMany result: %v", values)
+	}
+
+	all, _ := shared.List(ctx, "")
+	expected := []string{"build:run-1/version", "build:run-2/version", "registry"}
+	if !reflect.DeepEqual(all, expected) {
+		t.Errorf("Unexpected underlying keys: %v", all)
+	}
+}
+
+func TestScopedMetadataStore_PipelineAndRunDisjoint(t *testing.T) {
+	ctx := context.Background()
+	shared, _ := pipelinex.NewMemoryMetadataStore(pipelinex.MetadataConfig{Type: "memory"})
+	scope := func(scope string) *pipelinex.ScopedMetadataStore {
+		namespace, err := pipelinex.MetadataNamespace(pipelinex.MetadataConfig{Type: "redis", Scope: scope}, "build", "run-1")
+		if err != nil {
+			t.Fatalf("MetadataNamespace(%s) failed: %v", scope, err)
+		}
+		return pipelinex.NewScopedMetadataStore(shared, namespace, 0)
+	}
+	pipelineScope := scope("pipeline")
+	runScope := scope("run")
+
+	pipelineScope.Set(ctx, "last_success", "1.0.0")
+	runScope.Set(ctx, "version", "1.1.0")
+
+	keys, _ := pipelineScope.List(ctx, "")
+	if !reflect.DeepEqual(keys, []string{"last_success"}) {
+		t.Errorf("Expected run keys to be excluded from pipeline scope, got %v", keys)
+	}
+	keys, _ = runScope.List(ctx, "")
+	if !reflect.DeepEqual(keys, []string{"version"}) {
+		t.Errorf("Expected only run keys in run scope, got %v", keys)
+	}
+}
+
+func TestScopedMetadataStore_DefaultTTL(t *testing.T) {
+	ctx := context.Background()
+	shared, _ := pipelinex.NewMemoryMetadataStore(pipelinex.MetadataConfig{Type: "memory"})
+	scoped := pipelinex.NewScopedMetadataStore(shared, "build", 50*time.Millisecond)
+
+	scoped.SetMany(ctx, map[string]string{"a": "1", "b": "2"})
+	time.Sleep(80 * time.Millisecond)
+
+	keys, _ := scoped.List(ctx, "")
+	if len(keys) != 0 {
+		t.Errorf("Expected keys to expire with default ttl, got %v", keys)
+	}
+}
+
+func TestMetadataNamespace(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   pipelinex.MetadataConfig
+		pipeline string
+		expected string
+		wantErr  bool
+	}{
+		{"默认按流水线隔离", pipelinex.MetadataConfig{Type: "redis"}, "build", "p/build", false},
+		{"未设置名称时全局", pipelinex.MetadataConfig{Type: "redis"}, "", "", false},
+		{"in-config默认全局", pipelinex.MetadataConfig{Type: "in-config"}, "build", "", false},
+		{"memory默认全局", pipelinex.MetadataConfig{Type: "memory"}, "build", "", false},
+		{"显式全局", pipelinex.MetadataConfig{Type: "redis", Scope: "global"}, "build", "", false},
+		{"按运行隔离", pipelinex.MetadataConfig{Type: "redis", Scope: "run"}, "build", "r/build/run-1", false},
+		{"名称中的斜杠被转义", pipelinex.MetadataConfig{Type: "redis", Scope: "pipeline"}, "team/build", "p/team%2Fbuild", false},
+		{"按流水线隔离缺少名称", pipelinex.MetadataConfig{Type: "redis", Scope: "pipeline"}, "", "", true},
+		{"未知作用域", pipelinex.MetadataConfig{Type: "redis", Scope: "tenant"}, "build", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace, err := pipelinex.MetadataNamespace(tt.config, tt.pipeline, "run-1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MetadataNamespace error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if namespace != tt.expected {
+				t.Errorf("MetadataNamespace = %q, expected %q", namespace, tt.expected)
+			}
+		})
+	}
+}
+
+func nextEvent(t *testing.T, events <-chan pipelinex.MetadataEvent) pipelinex.MetadataEvent {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("Watch channel closed unexpectedly")
+		}
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for metadata event")
+	}
+	return pipelinex.MetadataEvent{}
+}
+
+func TestMemoryMetadataStore_Watch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store, _ := pipelinex.NewMemoryMetadataStore(pipelinex.MetadataConfig{Type: "memory"})
+
+	events, err := store.Watch(ctx, "release.approved")
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	store.Set(ctx, "other", "x")
+	store.Set(ctx, "release.approved", "true")
+	event := nextEvent(t, events)
+	if event.Key != "release.approved" || event.Value != "true" || event.Deleted {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+
+	store.Delete(ctx, "release.approved")
+	if event := nextEvent(t, events); !event.Deleted {
+		t.Errorf("Expected delete event, got %+v", event)
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestMemoryMetadataStore_WatchExistingValue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store, _ := pipelinex.NewMemoryMetadataStore(pipelinex.MetadataConfig{
+		Type: "memory",
+		Data: map[string]interface{}{"release.approved": "true"},
+	})
+
+	// 已经存在的值会立即发送，等待方不会错过
+	events, _ := store.Watch(ctx, "release.approved")
+	if event := nextEvent(t, events); event.Value != "true" {
+		t.Errorf("Expected current value, got %+v", event)
+	}
+}
+
+func TestMemoryMetadataStore_CompareAndSwapClearsTTL(t *testing.T) {
+	ctx := context.Background()
+	store, _ := pipelinex.NewMemoryMetadataStore(pipelinex.MetadataConfig{Type: "memory"})
+
+	store.SetWithTTL(ctx, "lock", "owner-a", 50*time.Millisecond)
+	if ok, err := store.CompareAndSwap(ctx, "lock", "owner-a", "owner-b"); err != nil || !ok {
+		t.Fatalf("Expected swap to succeed, got %v, %v", ok, err)
+	}
+	time.Sleep(80 * time.Millisecond)
+
+	// 交换写入的值与 Set 一样不过期
+	if val, err := store.Get(ctx, "lock"); err != nil || val != "owner-b" {
+		t.Errorf("Expected swapped value to outlive the old ttl, got %q, %v", val, err)
+	}
+}
+
+func TestMetadataStore_CompareAndSwapAndIncr(t *testing.T) {
+	ctx := context.Background()
+	memory, _ := pipelinex.NewMemoryMetadataStore(pipelinex.MetadataConfig{Type: "memory"})
+	file, _ := pipelinex.NewFileMetadataStore(pipelinex.MetadataConfig{
+		Type: "file",
+		Data: map[string]interface{}{"path": filepath.Join(t.TempDir(), "store.json")},
+	})
+
+	stores := map[string]pipelinex.MetadataStore{
+		"memory": memory,
+		"file":   file,
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			if ok, err := store.CompareAndSwap(ctx, "lock", "", "owner-a"); err != nil || !ok {
+				t.Fatalf("Expected create-if-absent to succeed, got %v, %v", ok, err)
+			}
+			if ok, _ := store.CompareAndSwap(ctx, "lock", "", "owner-b"); ok {
+				t.Error("Expected create-if-absent to fail for existing key")
+			}
+			if ok, _ := store.CompareAndSwap(ctx, "lock", "owner-b", "owner-c"); ok {
+				t.Error("Expected swap with wrong old value to fail")
+			}
+			if ok, _ := store.CompareAndSwap(ctx, "lock", "owner-a", "owner-c"); !ok {
+				t.Error("Expected swap with matching old value to succeed")
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := store.Incr(ctx, "counter", 1); err != nil {
+						t.Errorf("Incr failed: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if val, err := store.Incr(ctx, "counter", 5); err != nil || val != 25 {
+				t.Errorf("Expected counter=25, got %d, %v", val, err)
+			}
+			if _, err := store.Incr(ctx, "lock", 1); err == nil {
+				t.Error("Expected error when incrementing non-integer value")
+			}
+		})
+	}
+}
+
+func TestFileMetadataStore_WatchPolling(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	config := pipelinex.MetadataConfig{
+		Type: "file",
+		Data: map[string]interface{}{
+			"path":          filepath.Join(t.TempDir(), "store.json"),
+			"poll_interval": "20ms",
+		},
+	}
+	watcher, _ := pipelinex.NewFileMetadataStore(config)
+	writer, _ := pipelinex.NewFileMetadataStore(config)
+
+	events, _ := watcher.Watch(ctx, "version")
+	writer.Set(ctx, "version", "3")
+
+	if event := nextEvent(t, events); event.Value != "3" {
+		t.Errorf("Expected version=3, got %+v", event)
+	}
+}
+
+func TestScopedMetadataStore_Watch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	shared, _ := pipelinex.NewMemoryMetadataStore(pipelinex.MetadataConfig{Type: "memory"})
+	scoped := pipelinex.NewScopedMetadataStore(shared, "deploy", 0)
+
+	events, _ := scoped.Watch(ctx, "approved")
+	shared.Set(ctx, "approved", "global")
+	shared.Set(ctx, "deploy/approved", "yes")
+
+	event := nextEvent(t, events)
+	if event.Key != "approved" || event.Value != "yes" {
+		t.Errorf("Expected scoped event approved=yes, got %+v", event)
+	}
+	if n, _ := scoped.Incr(ctx, "counter", 1); n != 1 {
+		t.Errorf("Expected scoped counter=1, got %d", n)
+	}
+	if _, err := shared.Get(ctx, "deploy/counter"); err != nil {
+		t.Errorf("Expected counter to be namespaced: %v", err)
+	}
+}
+
+func TestMetadataStoreFactory_Register(t *testing.T) {
+	factory := pipelinex.NewMetadataStoreFactory()
+	factory.Register("config-service", func(config pipelinex.MetadataConfig) (pipelinex.MetadataStore, error) {
+		return pipelinex.NewMemoryMetadataStore(pipelinex.MetadataConfig{
+			Data: map[string]interface{}{"endpoint": config.Data["endpoint"]},
+		})
+	}, func(data map[string]interface{}) error {
+		if _, ok := data["endpoint"].(string); !ok {
+			return fmt.Errorf("endpoint is required")
+		}
+		return nil
+	})
+
+	store, err := factory.Create(pipelinex.MetadataConfig{
+		Type: "config-service",
+		Data: map[string]interface{}{"endpoint": "http://config"},
+	})
+	if err != nil {
+		t.Fatalf("Create custom store failed: %v", err)
+	}
+	if val, _ := store.Get(context.Background(), "endpoint"); val != "http://config" {
+		t.Errorf("Expected custom constructor to receive config, got %q", val)
+	}
+
+	_, err = factory.Create(pipelinex.MetadataConfig{Type: "config-service"})
+	if err == nil || !strings.Contains(err.Error(), "endpoint is required") {
+		t.Errorf("Expected custom validation error, got %v", err)
+	}
+
+	found := false
+	for _, storeType := range factory.Types() {
+		if storeType == "config-service" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected config-service in registered types: %v", factory.Types())
+	}
+}
+
+// TestMetadataStoreFactory_ZeroValue 测试零值工厂可以直接创建内置类型和注册自定义类型
+func TestMetadataStoreFactory_ZeroValue(t *testing.T) {
+	var factory pipelinex.DefaultMetadataStoreFactory
+	if _, err := factory.Create(pipelinex.MetadataConfig{Type: "memory"}); err != nil {
+		t.Fatalf("Create on zero-value factory failed: %v", err)
+	}
+
+	var registered pipelinex.DefaultMetadataStoreFactory
+	registered.Register("custom", func(config pipelinex.MetadataConfig) (pipelinex.MetadataStore, error) {
+		return pipelinex.NewMemoryMetadataStore(config)
+	}, nil)
+	for _, storeType := range []string{"custom", "in-config"} {
+		if _, err := registered.Create(pipelinex.MetadataConfig{Type: storeType}); err != nil {
+			t.Errorf("Create(%s) failed: %v", storeType, err)
+		}
+	}
+}
+
+func TestMetadataStoreFactory_Validate(t *testing.T) {
+	factory := pipelinex.NewMetadataStoreFactory()
+
+	tests := []struct {
+		name    string
+		config  pipelinex.MetadataConfig
+		errPart string
+	}{
+		{"未知类型", pipelinex.MetadataConfig{Type: "etcd"}, "supported: file, http, in-config, memory, redis"},
+		{"http缺少url", pipelinex.MetadataConfig{Type: "http"}, "url is required"},
+		{"http非法url", pipelinex.MetadataConfig{Type: "http", Data: map[string]interface{}{"url": "localhost:8080"}}, "absolute http(s) URL"},
+		{"http非法超时", pipelinex.MetadataConfig{Type: "http", Data: map[string]interface{}{"url": "http://a", "timeout": "soon"}}, "timeout must be a duration"},
+		{"http非法headers", pipelinex.MetadataConfig{Type: "http", Data: map[string]interface{}{"url": "http://a", "headers": "x"}}, "headers must be a map"},
+		{"redis非法端口", pipelinex.MetadataConfig{Type: "redis", Data: map[string]interface{}{"port": "abc"}}, "port must be an integer"},
+		{"redis负数db", pipelinex.MetadataConfig{Type: "redis", Data: map[string]interface{}{"db": -1}}, "db must be a non-negative integer"},
+		{"file路径类型错误", pipelinex.MetadataConfig{Type: "file", Data: map[string]interface{}{"path": 1}}, "path must be a string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := factory.Validate(tt.config)
+			if err == nil || !strings.Contains(err.Error(), tt.errPart) {
+				t.Errorf("Validate error = %v, expected to contain %q", err, tt.errPart)
+			}
+		})
+	}
+
+	valid := pipelinex.MetadataConfig{Type: "redis", Data: map[string]interface{}{"host": "localhost", "port": 6380}}
+	if err := factory.Validate(valid); err != nil {
+		t.Errorf("Expected valid redis config, got %v", err)
+	}
+}
+
+func TestRuntimeImpl_SetMetadataStoreFactory(t *testing.T) {
+	ctx := context.Background()
+	runtime := pipelinex.NewRuntime(ctx)
+
+	created := false
+	factory := pipelinex.NewMetadataStoreFactory()
+	factory.Register("custom", func(config pipelinex.MetadataConfig) (pipelinex.MetadataStore, error) {
+		created = true
+		return pipelinex.NewMemoryMetadataStore(config)
+	}, nil)
+	runtime.SetMetadataStoreFactory(factory)
+
+	config := `
+Metadate:
+  type: custom
+  scope: global
+  data:
+    env: prod
+Nodes:
+  Task1: {}
+`
+	pipeline, err := runtime.RunSync(ctx, "custom-metadata", config, nil)
+	if err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if !created {
+		t.Error("Expected custom constructor to be used")
+	}
+	if pipeline.Metadata()["env"] != "prod" {
+		t.Errorf("Expected metadata from custom store, got %v", pipeline.Metadata())
+	}
+
+	_, err = runtime.RunSync(ctx, "unknown-metadata", "Metadate:\n  type: unknown\n", nil)
+	if err == nil || !strings.Contains(err.Error(), "unsupported metadata store type") {
+		t.Errorf("Expected unsupported type error, got %v", err)
+	}
+}
+
+func TestRuntimeImpl_MetadataNameWithSeedData(t *testing.T) {
+	ctx := context.Background()
+	runtime := pipelinex.NewRuntime(ctx)
+
+	for _, storeType := range []string{"memory", "in-config"} {
+		t.Run(storeType, func(t *testing.T) {
+			config := `
+Name: build
+Metadate:
+  type: ` + storeType + `
+  data:
+    env: prod
+Nodes:
+  Task1: {}
+`
+			pipeline, err := runtime.RunSync(ctx, "seeded-"+storeType, config, nil)
+			if err != nil {
+				t.Fatalf("RunSync failed: %v", err)
+			}
+			if pipeline.Metadata()["env"] != "prod" {
+				t.Errorf("Expected seed data to be visible with Name set, got %v", pipeline.Metadata())
+			}
+		})
+	}
+}
+
+func TestRuntimeImpl_MetadataTTLUnsupported(t *testing.T) {
+	ctx := context.Background()
+	runtime := pipelinex.NewRuntime(ctx)
+
+	config := `
+Metadate:
+  type: file
+  ttl: 1h
+  data:
+    path: ` + filepath.Join(t.TempDir(), "metadata.json") + `
+Nodes:
+  Task1: {}
+`
+	_, err := runtime.RunSync(ctx, "file-ttl", config, nil)
+	if err == nil || !strings.Contains(err.Error(), "does not support ttl") {
+		t.Errorf("Expected ttl to be rejected at setup, got %v", err)
+	}
+
+	factory := pipelinex.NewMetadataStoreFactory()
+	if err := factory.Validate(pipelinex.MetadataConfig{Type: "in-config", TTL: "1h"}); err == nil {
+		t.Error("Expected in-config store to reject ttl")
+	}
+	if err := factory.Validate(pipelinex.MetadataConfig{Type: "memory", TTL: "1h"}); err != nil {
+		t.Errorf("Expected memory store to accept ttl, got %v", err)
+	}
+	if err := factory.Validate(pipelinex.MetadataConfig{Type: "memory", TTL: "soon"}); err == nil {
+		t.Error("Expected invalid ttl to be rejected")
+	}
+}
+
+// closeTrackingStore 记录 Close 被调用的次数
+type closeTrackingStore struct {
+	*pipelinex.MemoryMetadataStore
+	closes atomic.Int32
+}
+
+func (s *closeTrackingStore) Close() error {
+	s.closes.Add(1)
+	return s.MemoryMetadataStore.Close()
+}
+
+func TestRuntimeImpl_MetadataClosedOnDuplicateID(t *testing.T) {
+	ctx := context.Background()
+	runtime := pipelinex.NewRuntime(ctx)
+
+	memory, _ := pipelinex.NewMemoryMetadataStore(pipelinex.MetadataConfig{Type: "memory"})
+	store := &closeTrackingStore{MemoryMetadataStore: memory}
+	factory := pipelinex.NewMetadataStoreFactory()
+	factory.Register("tracked", func(config pipelinex.MetadataConfig) (pipelinex.MetadataStore, error) {
+		// 创建存储期间相同ID的流水线完成登记
+		if _, err := runtime.RunSync(ctx, "duplicate-id", "Nodes:\n  Task1: {}\n", nil); err != nil {
+			t.Errorf("Concurrent RunSync failed: %v", err)
+		}
+		return store, nil
+	}, nil)
+	runtime.SetMetadataStoreFactory(factory)
+
+	config := `
+Metadate:
+  type: tracked
+Nodes:
+  Task1: {}
+`
+	_, err := runtime.RunSync(ctx, "duplicate-id", config, nil)
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("Expected duplicate id error, got %v", err)
+	}
+	if store.closes.Load() != 1 {
+		t.Errorf("Expected metadata store to be closed once when registration fails, got %d", store.closes.Load())
+	}
+}
+
+// TestRuntimeImpl_MetadataClosedAfterRun 测试流水线执行结束后关闭元数据存储
+func TestRuntimeImpl_MetadataClosedAfterRun(t *testing.T) {
+	ctx := context.Background()
+	runtime := pipelinex.NewRuntime(ctx)
+
+	var stores []*closeTrackingStore
+	factory := pipelinex.NewMetadataStoreFactory()
+	factory.Register("tracked", func(config pipelinex.MetadataConfig) (pipelinex.MetadataStore, error) {
+		memory, err := pipelinex.NewMemoryMetadataStore(config)
+		if err != nil {
+			return nil, err
+		}
+		store := &closeTrackingStore{MemoryMetadataStore: memory}
+		stores = append(stores, store)
+		return store, nil
+	}, nil)
+	runtime.SetMetadataStoreFactory(factory)
+
+	config := `
+Metadate:
+  type: tracked
+Nodes:
+  Task1: {}
+`
+	if _, err := runtime.RunSync(ctx, "closed-sync", config, nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if n := stores[0].closes.Load(); n != 1 {
+		t.Errorf("Expected store to be closed once after RunSync, got %d", n)
+	}
+
+	if _, err := runtime.RunAsync(ctx, "closed-async", config, nil); err != nil {
+		t.Fatalf("RunAsync failed: %v", err)
+	}
+	// 存储在执行协程结束时关闭
+	deadline := time.Now().Add(5 * time.Second)
+	for stores[1].closes.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := stores[1].closes.Load(); n != 1 {
+		t.Errorf("Expected store to be closed once after RunAsync, got %d", n)
+	}
+}
+
+func TestMetadataValue_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store, _ := pipelinex.NewMemoryMetadataStore(pipelinex.MetadataConfig{Type: "memory"})
+
+	values := map[string]any{
+		"string":  "1.0",
+		"int":     int64(3),
+		"float":   1.5,
+		"bool":    true,
+		"list":    []any{"a", "b"},
+		"map":     map[string]any{"replicas": int64(2), "canary": false},
+		"numeric": "2323",
+	}
+	for key, value := range values {
+		if err := pipelinex.SetMetadataValue(ctx, store, key, value); err != nil {
+			t.Fatalf("SetMetadataValue(%s) failed: %v", key, err)
+		}
+	}
+	for key, expected := range values {
+		value, err := pipelinex.GetMetadataValue(ctx, store, key)
+		if err != nil {
+			t.Fatalf("GetMetadataValue(%s) failed: %v", key, err)
+		}
+		if !reflect.DeepEqual(value, expected) {
+			t.Errorf("GetMetadataValue(%s) = %#v, expected %#v", key, value, expected)
+		}
+	}
+
+	// 没有类型前缀的值按原始字符串处理，即使恰好是合法 JSON
+	for _, raw := range []string{"1.2.3", "1.10", "true", "42", `{"a":1}`} {
+		store.Set(ctx, "raw", raw)
+		if value, _ := pipelinex.GetMetadataValue(ctx, store, "raw"); value != raw {
+			t.Errorf("Expected raw string %q, got %#v", raw, value)
+		}
+	}
+}
+
+func TestMetadataStore_SeedDataRaw(t *testing.T) {
+	ctx := context.Background()
+	data := map[string]interface{}{
+		"name": "demo", "version": "1.10", "replicas": 3, "canary": false,
+		"regions": []interface{}{"eu", "us"},
+		"limits":  map[interface{}]interface{}{"cpu": "1", "replicas": 2},
+	}
+	inConfig, _ := pipelinex.NewInConfigMetadataStore(pipelinex.MetadataConfig{Type: "in-config", Data: data})
+	memory, _ := pipelinex.NewMemoryMetadataStore(pipelinex.MetadataConfig{Type: "memory", Data: data})
+
+	for name, store := range map[string]pipelinex.MetadataStore{"in-config": inConfig, "memory": memory} {
+		t.Run(name, func(t *testing.T) {
+			// 列表和 map 保存为 JSON，其他标量按原样格式化
+			expected := map[string]string{
+				"name": "demo", "version": "1.10", "replicas": "3", "canary": "false",
+				"regions": `["eu","us"]`, "limits": `{"cpu":"1","replicas":2}`,
+			}
+			for key, want := range expected {
+				if got, err := store.Get(ctx, key); err != nil || got != want {
+					t.Errorf("Get(%s) = %q, %v, expected %q", key, got, err, want)
+				}
+			}
+			if value, _ := pipelinex.GetMetadataValue(ctx, store, "version"); value != "1.10" {
+				t.Errorf("Expected string seed to stay a string, got %#v", value)
+			}
+			if value, _ := pipelinex.GetMetadataValue(ctx, store, "replicas"); value != int64(3) {
+				t.Errorf("Expected typed seed to be an integer, got %#v", value)
+			}
+			if value, _ := pipelinex.GetMetadataValue(ctx, store, "regions"); !reflect.DeepEqual(value, []any{"eu", "us"}) {
+				t.Errorf("Expected list seed to keep its native type, got %#v", value)
+			}
+		})
+	}
+
+	// 改写后按新写入的值处理
+	memory.Set(ctx, "replicas", "5")
+	if value, _ := pipelinex.GetMetadataValue(ctx, memory, "replicas"); value != "5" {
+		t.Errorf("Expected overwritten seed to be raw, got %#v", value)
+	}
+}
+
+func TestPipeline_Metadata_RawStringsInTemplates(t *testing.T) {
+	ctx := context.Background()
+	store, _ := pipelinex.NewMemoryMetadataStore(pipelinex.MetadataConfig{Type: "memory"})
+	store.Set(ctx, "version", "1.10")
+	store.Set(ctx, "flag", "true")
+
+	pipeline := pipelinex.NewPipeline(ctx)
+	pipeline.SetMetadata(store)
+	evalCtx := pipelinex.NewEvaluationContext().WithPipeline(pipeline)
+
+	engine := pipelinex.NewPongo2TemplateEngine()
+	result, err := engine.EvaluateString("{{ version }} {{ flag }}", evalCtx.All())
+	if err != nil {
+		t.Fatalf("EvaluateString failed: %v", err)
+	}
+	if result != "1.10 true" {
+		t.Errorf("Expected raw strings to render unchanged, got %q", result)
+	}
+}
+
+func TestInConfigMetadataStore_TypedValues(t *testing.T) {
+	ctx := context.Background()
+	// 模拟 yaml.v2 解析出的嵌套结构
+	store, err := pipelinex.NewInConfigMetadataStore(pipelinex.MetadataConfig{
+		Type: "in-config",
+		Data: map[string]interface{}{
+			"targets": []interface{}{"us-east", "eu-west"},
+			"limits":  map[interface{}]interface{}{"cpu": "1000m", "replicas": 3},
+			"enabled": true,
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewInConfigMetadataStore failed: %v", err)
+	}
+
+	targets, _ := pipelinex.GetMetadataValue(ctx, store, "targets")
+	if !reflect.DeepEqual(targets, []any{"us-east", "eu-west"}) {
+		t.Errorf("Unexpected targets: %#v", targets)
+	}
+	limits, _ := pipelinex.GetMetadataValue(ctx, store, "limits")
+	if !reflect.DeepEqual(limits, map[string]any{"cpu": "1000m", "replicas": int64(3)}) {
+		t.Errorf("Unexpected limits: %#v", limits)
+	}
+}
+
+func TestPipeline_Metadata_NativeTypesInTemplates(t *testing.T) {
+	ctx := context.Background()
+	store, _ := pipelinex.NewInConfigMetadataStore(pipelinex.MetadataConfig{
+		Type: "in-config",
+		Data: map[string]interface{}{
+			"targets":  []interface{}{"a", "b", "c"},
+			"replicas": 3,
+			"canary":   false,
+		},
+	})
+
+	pipeline := pipelinex.NewPipeline(ctx)
+	pipeline.SetMetadata(store)
+	evalCtx := pipelinex.NewEvaluationContext().WithPipeline(pipeline)
+
+	engine := pipelinex.NewPongo2TemplateEngine()
+	result, err := engine.EvaluateString("{% for x in targets %}{{ x }},{% endfor %}", evalCtx.All())
+	if err != nil {
+		t.Fatalf("EvaluateString failed: %v", err)
+	}
+	if result != "a,b,c," {
+		t.Errorf("Expected loop over list metadata, got %q", result)
+	}
+
+	ok, err := engine.EvaluateBool("{{ replicas > 2 and not canary }}", evalCtx.All())
+	if err != nil || !ok {
+		t.Errorf("Expected typed comparison to be true, got %v, %v", ok, err)
+	}
+}
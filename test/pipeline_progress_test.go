@@ -0,0 +1,107 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/chenyingqiao/pipelinex"
+)
+
+const progressConfig = `
+Graph: |
+  stateDiagram-v2
+    [*] --> Checkout
+    Checkout --> Lint
+    Checkout --> Test
+    Lint --> Build
+    Test --> Build
+    Build --> [*]
+Nodes:
+  Checkout:
+    steps: [{name: checkout, run: "true"}]
+  Lint:
+    steps: [{name: lint, run: "true"}]
+  Test:
+    steps: [{name: test, run: "true"}]
+  Build:
+    steps: [{name: build, run: "BUILD_CMD"}]
+`
+
+// TestProgressRenderer_Plain 测试非终端输出时每次状态变化输出一行
+func TestProgressRenderer_Plain(t *testing.T) {
+	ctx := context.Background()
+	runtime := pipelinex.NewRuntime(ctx)
+	runtime.SetStepRunner(pipelinex.NewLocalStepRunner())
+
+	var out bytes.Buffer
+	renderer := pipelinex.NewProgressRenderer(&out)
+	if _, err := runtime.RunSync(ctx, "test-progress-plain", strings.Replace(progressConfig, "BUILD_CMD", "false", 1), renderer); err == nil {
+		t.Fatal("Expected RunSync to fail")
+	}
+
+	output := out.String()
+	if strings.Contains(output, "\033[") {
+		t.Errorf("Expected no escape sequences in plain output:\n%s", output)
+	}
+	for _, want := range []string{"● Checkout RUNNING", "✔ Checkout SUCCESS", "✔ Lint SUCCESS", "● Build RUNNING", "✘ Build FAILED"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output:\n%s", want, output)
+		}
+	}
+	if !strings.HasSuffix(output, " finished\n") {
+		t.Errorf("Expected pipeline finish line at the end:\n%s", output)
+	}
+	if strings.Contains(output, "UNKNOWN") {
+		t.Errorf("Expected nodes that have not started not to be printed:\n%s", output)
+	}
+	if strings.Index(output, "✔ Checkout SUCCESS") > strings.Index(output, "● Lint RUNNING") {
+		t.Errorf("Expected Checkout to finish before Lint starts:\n%s", output)
+	}
+}
+
+// TestProgressRenderer_Interactive 测试终端模式按拓扑层重绘
+func TestProgressRenderer_Interactive(t *testing.T) {
+	ctx := context.Background()
+	runtime := pipelinex.NewRuntime(ctx)
+	runtime.SetStepRunner(pipelinex.NewLocalStepRunner())
+
+	var out bytes.Buffer
+	renderer := pipelinex.NewProgressRenderer(&out)
+	renderer.SetInteractive(true)
+	if _, err := runtime.RunSync(ctx, "test-progress-tty", strings.Replace(progressConfig, "BUILD_CMD", "true", 1), renderer); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+
+	// 每次重绘前清除上次绘制的4行（标题和3层）
+	output := out.String()
+	if !strings.Contains(output, "\033[4A\033[J") {
+		t.Errorf("Expected redraw escape sequence in output:\n%q", output)
+	}
+
+	frames := strings.Split(output, "\033[4A\033[J")
+	last := strings.Split(strings.TrimSpace(frames[len(frames)-1]), "\n")
+	if len(last) != 4 || !strings.Contains(last[0], " finished ") {
+		t.Fatalf("Unexpected final frame:\n%s", strings.Join(last, "\n"))
+	}
+	layers := []struct {
+		prefix string
+		nodes  []string
+	}{
+		{prefix: "  1  ", nodes: []string{"✔ Checkout"}},
+		{prefix: "  2  ", nodes: []string{"✔ Lint", "✔ Test"}},
+		{prefix: "  3  ", nodes: []string{"✔ Build"}},
+	}
+	for i, layer := range layers {
+		line := last[i+1]
+		if !strings.HasPrefix(line, layer.prefix) {
+			t.Errorf("Layer %d line = %q, expected prefix %q", i+1, line, layer.prefix)
+		}
+		for _, node := range layer.nodes {
+			if !strings.Contains(line, node) {
+				t.Errorf("Layer %d line = %q, expected %q", i+1, line, node)
+			}
+		}
+	}
+}
@@ -2,8 +2,10 @@ package test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -46,7 +48,11 @@ func TestRuntimeImpl_RunSync(t *testing.T) {
 	config := `
 Param:
   test-param: "test-value"
-Graph: "Task1->Task2"
+Graph: |
+  stateDiagram-v2
+    [*] --> Task1
+    Task1 --> Task2
+    Task2 --> [*]
 Nodes:
   Task1:
     Image: "test-image:latest"
@@ -396,7 +402,10 @@ func TestParseGraphEdges_BasicStateDiagram(t *testing.T) {
 	}
 
 	runtime := pipelinex.NewRuntime(ctx).(*pipelinex.RuntimeImpl)
-	graph := runtime.BuildGraph(config)
+	graph, err := runtime.BuildGraph(config)
+	if err != nil {
+		t.Fatalf("BuildGraph failed: %v", err)
+	}
 
 	// 验证所有节点都存在
 	nodes := graph.Nodes()
@@ -435,7 +444,10 @@ func TestParseGraphEdges_ComplexDiagram(t *testing.T) {
 	}
 
 	runtime := pipelinex.NewRuntime(ctx).(*pipelinex.RuntimeImpl)
-	graph := runtime.BuildGraph(config)
+	graph, err := runtime.BuildGraph(config)
+	if err != nil {
+		t.Fatalf("BuildGraph failed: %v", err)
+	}
 
 	nodes := graph.Nodes()
 	if len(nodes) != 5 {
@@ -455,7 +467,10 @@ func TestParseGraphEdges_EmptyGraph(t *testing.T) {
 	}
 
 	runtime := pipelinex.NewRuntime(ctx).(*pipelinex.RuntimeImpl)
-	graph := runtime.BuildGraph(config)
+	graph, err := runtime.BuildGraph(config)
+	if err != nil {
+		t.Fatalf("BuildGraph failed: %v", err)
+	}
 
 	nodes := graph.Nodes()
 	if len(nodes) != 2 {
@@ -475,7 +490,14 @@ func TestParseGraphEdges_InvalidSyntax(t *testing.T) {
 	}
 
 	runtime := pipelinex.NewRuntime(ctx).(*pipelinex.RuntimeImpl)
-	graph := runtime.BuildGraph(config)
+	graph, err := runtime.BuildGraph(config)
+	if !errors.Is(err, pipelinex.ErrGraphSyntax) {
+		t.Errorf("Expected ErrGraphSyntax, got %v", err)
+	}
+	var graphErr *pipelinex.GraphError
+	if !errors.As(err, &graphErr) || graphErr.Line != 1 || graphErr.Column != 1 {
+		t.Errorf("Expected error at line 1, column 1, got %v", err)
+	}
 
 	// 即使图语法无效，也应该创建节点
 	nodes := graph.Nodes()
@@ -501,7 +523,11 @@ func TestParseGraphEdges_MissingNode(t *testing.T) {
 	}
 
 	runtime := pipelinex.NewRuntime(ctx).(*pipelinex.RuntimeImpl)
-	graph := runtime.BuildGraph(config)
+	graph, err := runtime.BuildGraph(config)
+	if !errors.Is(err, pipelinex.ErrUnknownNode) || !strings.Contains(err.Error(), `line 3, column 11: unknown node "B"`) ||
+		!strings.Contains(err.Error(), `line 4, column 5: unknown node "B"`) {
+		t.Errorf("Expected unknown node errors for B, got %v", err)
+	}
 
 	// 即使 B 节点缺失在配置中，也应该创建存在的节点
 	nodes := graph.Nodes()
@@ -510,6 +536,243 @@ func TestParseGraphEdges_MissingNode(t *testing.T) {
 	}
 }
 
+// TestParseGraphEdges_SyntaxErrorPosition 测试无法解析的语句报告行号和列号
+func TestParseGraphEdges_SyntaxErrorPosition(t *testing.T) {
+	ctx := context.Background()
+	config := &pipelinex.PipelineConfig{
+		Nodes: map[string]pipelinex.NodeConfig{
+			"A": {},
+			"B": {},
+			"C": {},
+		},
+		Graph: `stateDiagram-v2
+    [*] --> A
+    A --> B
+      B -> C
+    C --> [*]`,
+	}
+
+	runtime := pipelinex.NewRuntime(ctx).(*pipelinex.RuntimeImpl)
+	_, err := runtime.BuildGraph(config)
+	var graphErr *pipelinex.GraphError
+	if !errors.As(err, &graphErr) {
+		t.Fatalf("Expected GraphError, got %v", err)
+	}
+	if graphErr.Line != 4 || graphErr.Column != 7 || !errors.Is(graphErr, pipelinex.ErrGraphSyntax) {
+		t.Errorf("Expected syntax error at line 4, column 7, got %v", graphErr)
+	}
+	// B -> C 没有被解析，C 只出现在结束转换中
+	if errors.Is(err, pipelinex.ErrUnreferencedNode) {
+		t.Errorf("Expected C to be referenced by end transition, got %v", err)
+	}
+}
+
+// TestParseGraphEdges_UnreferencedNode 测试配置中定义但没有出现在图中的节点
+func TestParseGraphEdges_UnreferencedNode(t *testing.T) {
+	ctx := context.Background()
+	config := &pipelinex.PipelineConfig{
+		Nodes: map[string]pipelinex.NodeConfig{
+			"A":      {},
+			"B":      {},
+			"Orphan": {},
+		},
+		Graph: `stateDiagram-v2
+    [*] --> A
+    A --> B
+    B --> [*]`,
+	}
+
+	runtime := pipelinex.NewRuntime(ctx).(*pipelinex.RuntimeImpl)
+	_, err := runtime.BuildGraph(config)
+	if !errors.Is(err, pipelinex.ErrUnreferencedNode) || !strings.Contains(err.Error(), `"Orphan"`) {
+		t.Errorf("Expected unreferenced node error for Orphan, got %v", err)
+	}
+}
+
+// TestParseGraphEdges_Cycle 测试图中的环作为错误返回
+func TestParseGraphEdges_Cycle(t *testing.T) {
+	ctx := context.Background()
+	config := &pipelinex.PipelineConfig{
+		Nodes: map[string]pipelinex.NodeConfig{
+			"A": {},
+			"B": {},
+			"C": {},
+		},
+		Graph: `stateDiagram-v2
+    [*] --> A
+    A --> B
+    B --> C
+    C --> A
+    C --> [*]`,
+	}
+
+	runtime := pipelinex.NewRuntime(ctx).(*pipelinex.RuntimeImpl)
+	_, err := runtime.BuildGraph(config)
+	if !errors.Is(err, pipelinex.ErrHasCycle) {
+		t.Fatalf("Expected ErrHasCycle, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "line 5, column 5: edge C->A: has cycle: C -> A -> B -> C") {
+		t.Errorf("Expected cycle reported at edge C->A, got %v", err)
+	}
+}
+
+// TestRuntimeImpl_InvalidGraphRefusesToRun 测试图错误时 RunSync 和 RunAsync 不启动流水线
+func TestRuntimeImpl_InvalidGraphRefusesToRun(t *testing.T) {
+	ctx := context.Background()
+	runtime := pipelinex.NewRuntime(ctx)
+	runner := &recordStepRunner{local: pipelinex.NewLocalStepRunner()}
+	runtime.SetStepRunner(runner)
+
+	config := `
+Graph: |
+  stateDiagram-v2
+    [*] --> A
+    A --> Missing
+    A --> [*]
+Nodes:
+  A:
+    steps: [{name: a, run: "true"}]
+`
+
+	if _, err := runtime.RunSync(ctx, "test-invalid-graph-sync", config, nil); !errors.Is(err, pipelinex.ErrUnknownNode) {
+		t.Errorf("Expected RunSync to fail with ErrUnknownNode, got %v", err)
+	}
+	if _, err := runtime.RunAsync(ctx, "test-invalid-graph-async", config, nil); !errors.Is(err, pipelinex.ErrUnknownNode) {
+		t.Errorf("Expected RunAsync to fail with ErrUnknownNode, got %v", err)
+	}
+	if _, ok := runner.find("a"); ok {
+		t.Error("Expected no step to run")
+	}
+	for _, id := range []string{"test-invalid-graph-sync", "test-invalid-graph-async"} {
+		if _, err := runtime.Get(id); err == nil {
+			t.Errorf("Expected pipeline %s not to be registered", id)
+		}
+	}
+}
+
+// TestParseGraphEdges_Flowchart 测试 flowchart 语法的链式边、& 和条件标签
+func TestParseGraphEdges_Flowchart(t *testing.T) {
+	ctx := context.Background()
+	config := &pipelinex.PipelineConfig{
+		Nodes: map[string]pipelinex.NodeConfig{
+			"Checkout": {},
+			"Lint":     {},
+			"Test":     {},
+			"Build":    {},
+			"Deploy":   {},
+			"Notify":   {},
+		},
+		Graph: `flowchart LR
+    %% 检出后并行检查
+    Checkout[检出代码] --> Lint & Test --> Build
+    subgraph release [发布]
+      Build -->|{{ Param.env|default:"dev" == "prod" }}| Deploy
+    end
+    Build -- 总是通知 --> Notify
+    classDef done fill:#9f9`,
+	}
+
+	runtime := pipelinex.NewRuntime(ctx).(*pipelinex.RuntimeImpl)
+	graph, err := runtime.BuildGraph(config)
+	if err != nil {
+		t.Fatalf("BuildGraph failed: %v", err)
+	}
+
+	expressions := map[string]string{}
+	for _, edge := range graph.Edges() {
+		expressions[edge.Source().Id()+"->"+edge.Target().Id()] = edge.Expression()
+	}
+	expected := map[string]string{
+		"Checkout->Lint": "",
+		"Checkout->Test": "",
+		"Lint->Build":    "",
+		"Test->Build":    "",
+		"Build->Deploy":  `{{ Param.env|default:"dev" == "prod" }}`,
+		"Build->Notify":  "",
+	}
+	if !reflect.DeepEqual(expressions, expected) {
+		t.Errorf("Edges = %v, expected %v", expressions, expected)
+	}
+}
+
+// TestParseGraphEdges_FlowchartErrors 测试 flowchart 语法错误的位置
+func TestParseGraphEdges_FlowchartErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		graph   string
+		message string
+	}{
+		{
+			name:    "无方向的连线",
+			graph:   "graph TD\n    A --- B",
+			message: "line 2, column 7: invalid graph syntax: link without arrow",
+		},
+		{
+			name:    "双向连线",
+			graph:   "graph TD\n    A <--> B",
+			message: "line 2, column 7: invalid graph syntax: bidirectional links are not supported",
+		},
+		{
+			name:    "未关闭的subgraph",
+			graph:   "flowchart LR\n  subgraph g\n    A --> B",
+			message: "line 2, column 3: invalid graph syntax: subgraph is not closed",
+		},
+		{
+			name:    "未知节点",
+			graph:   "flowchart LR\n  A --> B --> C",
+			message: `line 2, column 15: unknown node "C"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &pipelinex.PipelineConfig{
+				Nodes: map[string]pipelinex.NodeConfig{"A": {}, "B": {}},
+				Graph: tt.graph,
+			}
+			runtime := pipelinex.NewRuntime(context.Background()).(*pipelinex.RuntimeImpl)
+			_, err := runtime.BuildGraph(config)
+			if err == nil || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Expected error containing %q, got %v", tt.message, err)
+			}
+		})
+	}
+}
+
+// TestRuntimeImpl_FlowchartConditionalEdges 测试 flowchart 条件边与状态图一样控制执行
+func TestRuntimeImpl_FlowchartConditionalEdges(t *testing.T) {
+	ctx := context.Background()
+	runtime := pipelinex.NewRuntime(ctx)
+	runner := &recordStepRunner{local: pipelinex.NewLocalStepRunner()}
+	runtime.SetStepRunner(runner)
+
+	config := `
+Param:
+  env: dev
+Graph: |
+  graph TD
+    A -->|{{ Param.env == "prod" }}| B
+    A -->|{{ Param.env == "dev" }}| C
+Nodes:
+  A:
+    steps: [{name: a, run: "true"}]
+  B:
+    steps: [{name: b, run: "true"}]
+  C:
+    steps: [{name: c, run: "true"}]
+`
+
+	if _, err := runtime.RunSync(ctx, "test-flowchart-conditions", config, nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if _, ok := runner.find("b"); ok {
+		t.Error("Expected B to be skipped")
+	}
+	if _, ok := runner.find("c"); !ok {
+		t.Error("Expected C to run")
+	}
+}
+
 // TestExtractExpression 测试条件表达式提取
 func TestExtractExpression(t *testing.T) {
 	tests := []struct {
@@ -587,7 +850,10 @@ func TestParseGraphEdges_ConditionalEdges(t *testing.T) {
 	}
 
 	runtime := pipelinex.NewRuntime(ctx).(*pipelinex.RuntimeImpl)
-	graph := runtime.BuildGraph(config)
+	graph, err := runtime.BuildGraph(config)
+	if err != nil {
+		t.Fatalf("BuildGraph failed: %v", err)
+	}
 
 	// 验证所有节点都存在
 	nodes := graph.Nodes()
@@ -616,6 +882,74 @@ func TestParseGraphEdges_ConditionalEdges(t *testing.T) {
 	}
 }
 
+// TestRuntimeImpl_InvalidEdgeCondition 测试条件表达式语法错误在执行前返回
+func TestRuntimeImpl_InvalidEdgeCondition(t *testing.T) {
+	ctx := context.Background()
+	runtime := pipelinex.NewRuntime(ctx)
+
+	config := `
+Graph: |
+  stateDiagram-v2
+    [*] --> A
+    A --> B: {% if Param.env %}true{% endfor %}
+    B --> [*]
+Nodes:
+  A: {}
+  B: {}
+`
+
+	_, err := runtime.RunSync(ctx, "test-invalid-condition", config, nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid condition on edge A->B") {
+		t.Errorf("Expected invalid condition error, got %v", err)
+	}
+	if _, err := runtime.Get("test-invalid-condition"); err == nil {
+		t.Error("Expected pipeline not to be registered")
+	}
+}
+
+// TestRuntimeImpl_CustomTemplateHelpers 测试条件边使用引擎注册的过滤器和标准辅助函数
+func TestRuntimeImpl_CustomTemplateHelpers(t *testing.T) {
+	ctx := context.Background()
+	engine := pipelinex.NewPongo2TemplateEngineWithCache(64)
+	engine.RegisterFilter("region", func(in, param any) (any, error) {
+		return strings.SplitN(fmt.Sprint(in), "-", 2)[0], nil
+	})
+	runtime := pipelinex.NewRuntime(ctx)
+	runtime.SetTemplateEngine(engine)
+	runner := &recordStepRunner{local: pipelinex.NewLocalStepRunner()}
+	runtime.SetStepRunner(runner)
+
+	config := `
+Param:
+  version: "2.1.0"
+  cluster: eu-west
+Graph: |
+  stateDiagram-v2
+    [*] --> A
+    A --> B: {{ Param.version|semver_gt:"2.0.0" and Param.cluster|region == "eu" }}
+    A --> C: {{ matches(Param.cluster, "^us-") }}
+    B --> [*]
+    C --> [*]
+Nodes:
+  A:
+    steps: [{name: a, run: "true"}]
+  B:
+    steps: [{name: b, run: "true"}]
+  C:
+    steps: [{name: c, run: "true"}]
+`
+
+	if _, err := runtime.RunSync(ctx, "test-custom-helpers", config, nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if _, ok := runner.find("b"); !ok {
+		t.Error("Expected B to run")
+	}
+	if _, ok := runner.find("c"); ok {
+		t.Error("Expected C to be skipped")
+	}
+}
+
 // TestParseGraphEdges_UnconditionalEdges 测试无条件边解析
 func TestParseGraphEdges_UnconditionalEdges(t *testing.T) {
 	ctx := context.Background()
@@ -631,7 +965,10 @@ func TestParseGraphEdges_UnconditionalEdges(t *testing.T) {
 	}
 
 	runtime := pipelinex.NewRuntime(ctx).(*pipelinex.RuntimeImpl)
-	graph := runtime.BuildGraph(config)
+	graph, err := runtime.BuildGraph(config)
+	if err != nil {
+		t.Fatalf("BuildGraph failed: %v", err)
+	}
 
 	// 获取边并验证无条件
 	edges := graph.Edges()
@@ -667,7 +1004,10 @@ func TestParseGraphEdges_WithNotes(t *testing.T) {
 	}
 
 	runtime := pipelinex.NewRuntime(ctx).(*pipelinex.RuntimeImpl)
-	graph := runtime.BuildGraph(config)
+	graph, err := runtime.BuildGraph(config)
+	if err != nil {
+		t.Fatalf("BuildGraph failed: %v", err)
+	}
 
 	nodes := graph.Nodes()
 	if len(nodes) != 3 {
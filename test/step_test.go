@@ -0,0 +1,271 @@
+package test
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/chenyingqiao/pipelinex"
+)
+
+func TestParseStepOutputs(t *testing.T) {
+	data := []byte("# comment\nversion=1.2.3\ndigest=sha256:abc=\n\nnotes<<EOF\nline one\nline two\nEOF\nempty=\n")
+	outputs, err := pipelinex.ParseStepOutputs(data)
+	if err != nil {
+		t.Fatalf("ParseStepOutputs failed: %v", err)
+	}
+	expected := map[string]string{
+		"version": "1.2.3",
+		"digest":  "sha256:abc=",
+		"notes":   "line one\nline two",
+		"empty":   "",
+	}
+	if !reflect.DeepEqual(outputs, expected) {
+		t.Errorf("ParseStepOutputs = %v, expected %v", outputs, expected)
+	}
+}
+
+func TestParseStepOutputs_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		errPart string
+	}{
+		{"缺少等号", "version", "line 1: expected key=value"},
+		{"非法key", "a=1\nbad key=1", "line 2: invalid output key"},
+		{"缺少结束标记", "notes<<EOF\ntext", "missing delimiter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := pipelinex.ParseStepOutputs([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.errPart) {
+				t.Errorf("ParseStepOutputs error = %v, expected to contain %q", err, tt.errPart)
+			}
+		})
+	}
+}
+
+func TestParseStepOutputMarker(t *testing.T) {
+	if key, value, ok := pipelinex.ParseStepOutputMarker("  ::set-metadata image=app@sha256:1"); !ok || key != "image" || value != "app@sha256:1" {
+		t.Errorf("Unexpected marker result: %q %q %v", key, value, ok)
+	}
+	for _, line := range []string{"building image", "::set-metadata novalue", "::set-metadata bad key=1"} {
+		if _, _, ok := pipelinex.ParseStepOutputMarker(line); ok {
+			t.Errorf("Expected %q not to be a marker", line)
+		}
+	}
+}
+
+func TestRuntimeImpl_StepOutputsWriteBack(t *testing.T) {
+	ctx := context.Background()
+	runtime := pipelinex.NewRuntime(ctx)
+	runtime.SetStepRunner(pipelinex.NewLocalStepRunner())
+
+	store, _ := pipelinex.NewMemoryMetadataStore(pipelinex.MetadataConfig{Type: "memory"})
+	factory := pipelinex.NewMetadataStoreFactory()
+	factory.Register("shared", func(config pipelinex.MetadataConfig) (pipelinex.MetadataStore, error) {
+		return store, nil
+	}, nil)
+	runtime.SetMetadataStoreFactory(factory)
+
+	config := `
+Metadate:
+  type: shared
+Graph: |
+  stateDiagram-v2
+    [*] --> Build
+    Build --> Deploy: {{ version == "1.2.3" }}
+    Build --> Rollback: {{ version != "1.2.3" }}
+    Deploy --> [*]
+Nodes:
+  Build:
+    steps:
+      - name: version
+        run: echo "version=1.2.3" >> "$PIPELINEX_OUTPUT"
+      - name: image
+        run: |
+          echo "building"
+          echo "::set-metadata digest=sha256:abc"
+  Deploy:
+    steps:
+      - name: apply
+        run: echo "deploying $PIPELINEX_NODE"
+  Rollback:
+    steps:
+      - name: undo
+        run: echo "rollback" && exit 1
+`
+
+	pipeline, err := runtime.RunSync(ctx, "test-step-outputs", config, nil)
+	if err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+
+	// 输出按原始字符串写入元数据存储
+	if value, err := pipelinex.GetMetadataValue(ctx, store, "version"); err != nil || value != "1.2.3" {
+		t.Errorf("Expected version=1.2.3 in store, got %v, %v", value, err)
+	}
+	if value, _ := pipelinex.GetMetadataValue(ctx, store, "digest"); value != "sha256:abc" {
+		t.Errorf("Expected digest from log marker, got %v", value)
+	}
+
+	// 后续节点和求值上下文可以读取输出
+	if metadata := pipeline.Metadata(); metadata["version"] != "1.2.3" {
+		t.Errorf("Expected version in pipeline metadata, got %v", metadata["version"])
+	}
+	outputs := pipeline.(*pipelinex.PipelineImpl).Outputs("Build")
+	if !reflect.DeepEqual(outputs, map[string]string{"version": "1.2.3", "digest": "sha256:abc"}) {
+		t.Errorf("Unexpected Build outputs: %v", outputs)
+	}
+
+	replay, err := runtime.SubscribeLogs(ctx, "test-step-outputs", "Deploy")
+	if err != nil {
+		t.Fatalf("SubscribeLogs failed: %v", err)
+	}
+	found := false
+	for _, entry := range collectEntries(t, replay) {
+		if entry.Step == "apply" && entry.Output == "deploying Deploy" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected Deploy step output in logs")
+	}
+}
+
+func TestRuntimeImpl_StepFailure(t *testing.T) {
+	ctx := context.Background()
+	runtime := pipelinex.NewRuntime(ctx)
+	runtime.SetStepRunner(pipelinex.NewLocalStepRunner())
+
+	config := `
+Graph: |
+  stateDiagram-v2
+    [*] --> Build
+    Build --> [*]
+Nodes:
+  Build:
+    steps:
+      - name: broken
+        run: echo "bad line" >> "$PIPELINEX_OUTPUT"
+`
+
+	_, err := runtime.RunSync(ctx, "test-step-failure", config, nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid output file") {
+		t.Errorf("Expected invalid output file error, got %v", err)
+	}
+}
+
+// recordStepRunner 记录步骤执行信息后交给本机执行器执行
+type recordStepRunner struct {
+	mu         sync.Mutex
+	executions []pipelinex.StepExecution
+	local      *pipelinex.LocalStepRunner
+}
+
+func (r *recordStepRunner) RunStep(ctx context.Context, execution pipelinex.StepExecution, output io.Writer) error {
+	r.mu.Lock()
+	r.executions = append(r.executions, execution)
+	r.mu.Unlock()
+	return r.local.RunStep(ctx, execution, output)
+}
+
+// find 返回指定步骤的执行信息
+func (r *recordStepRunner) find(step string) (pipelinex.StepExecution, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, execution := range r.executions {
+		if execution.Step.Name == step {
+			return execution, true
+		}
+	}
+	return pipelinex.StepExecution{}, false
+}
+
+func TestRuntimeImpl_RenderStepTemplates(t *testing.T) {
+	ctx := context.Background()
+	runtime := pipelinex.NewRuntime(ctx)
+	runner := &recordStepRunner{local: pipelinex.NewLocalStepRunner()}
+	runtime.SetStepRunner(runner)
+
+	config := `
+Param:
+  buildId: "2323"
+  targets: [us, eu]
+Metadate:
+  type: memory
+  data:
+    env: prod
+Graph: |
+  stateDiagram-v2
+    [*] --> Build
+    Build --> Deploy
+    Deploy --> [*]
+Nodes:
+  Build:
+    steps:
+      - name: version
+        run: echo "version=1.2.{{ Param.buildId }}" >> "$PIPELINEX_OUTPUT"
+      - name: echo
+        run: echo "built {{ version }}"
+  Deploy:
+    image: registry/app:{{ nodes.Build.outputs.version }}
+    Config:
+      namespace: "{{ env }}-ns"
+      replicas: 3
+      args: ["--build={{ Param.buildId }}"]
+    steps:
+      - name: apply
+        run: echo "{% for t in Param.targets %}{{ t }},{% endfor %} {{ env|upper }} {{ nodeId }}"
+`
+
+	if _, err := runtime.RunSync(ctx, "test-render-steps", config, nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+
+	// 步骤命令在执行前渲染，可以引用同一节点前面步骤的输出
+	if echo, _ := runner.find("echo"); echo.Step.Run != `echo "built 1.2.2323"` {
+		t.Errorf("Unexpected rendered run: %q", echo.Step.Run)
+	}
+
+	apply, ok := runner.find("apply")
+	if !ok {
+		t.Fatal("Expected apply step to run")
+	}
+	if apply.Step.Run != `echo "us,eu, PROD Deploy"` {
+		t.Errorf("Unexpected rendered run: %q", apply.Step.Run)
+	}
+	if apply.Image != "registry/app:1.2.2323" {
+		t.Errorf("Unexpected rendered image: %q", apply.Image)
+	}
+	expected := map[string]any{"namespace": "prod-ns", "replicas": 3, "args": []any{"--build=2323"}}
+	if !reflect.DeepEqual(apply.Config, expected) {
+		t.Errorf("Unexpected rendered config: %#v", apply.Config)
+	}
+}
+
+func TestRuntimeImpl_RenderStepTemplateError(t *testing.T) {
+	ctx := context.Background()
+	runtime := pipelinex.NewRuntime(ctx)
+	runtime.SetStepRunner(pipelinex.NewLocalStepRunner())
+
+	config := `
+Graph: |
+  stateDiagram-v2
+    [*] --> Build
+    Build --> [*]
+Nodes:
+  Build:
+    steps:
+      - name: broken
+        run: echo "{% if %}"
+`
+
+	_, err := runtime.RunSync(ctx, "test-render-error", config, nil)
+	if err == nil || !strings.Contains(err.Error(), "step broken: failed to render run") {
+		t.Errorf("Expected render error, got %v", err)
+	}
+}
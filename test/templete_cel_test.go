@@ -0,0 +1,159 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/chenyingqiao/pipelinex"
+)
+
+func TestCELTemplateEngine_EvaluateBool(t *testing.T) {
+	engine, err := pipelinex.NewCELTemplateEngine(nil, nil)
+	if err != nil {
+		t.Fatalf("NewCELTemplateEngine failed: %v", err)
+	}
+	ctx := map[string]any{
+		"Param":   map[string]any{"env": "prod", "retries": 2},
+		"count":   float64(3), // JSON 解码得到的数字
+		"enabled": "yes",
+		"files":   []any{"docs/a.md"},
+		"version": "1.4.0",
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		expected   bool
+	}{
+		{"模板标记", `{{ Param.env == "prod" && Param.retries < 3 }}`, true},
+		{"无模板标记", `count > 2`, true},
+		{"整数与浮点比较", `count == 3`, true},
+		{"字符串不是布尔", `enabled == "yes"`, true},
+		{"正则", `version.matches("^1\\.")`, true},
+		{"列表", `"docs/a.md" in files`, true},
+		{"semver", `semver_gt(version, "1.3.9")`, true},
+		{"changed_files", `changed_files_contains(files, "src/")`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := engine.EvaluateBool(tt.expression, ctx)
+			if err != nil {
+				t.Fatalf("EvaluateBool(%q) failed: %v", tt.expression, err)
+			}
+			if result != tt.expected {
+				t.Errorf("EvaluateBool(%q) = %v, expected %v", tt.expression, result, tt.expected)
+			}
+		})
+	}
+
+	// 非 bool 结果和缺失的变量都是错误，不再猜测真假
+	for _, expression := range []string{`{{ enabled }}`, `{{ Param.enviroment == "prod" }}`, `{{ missing }}`} {
+		if _, err := engine.EvaluateBool(expression, ctx); err == nil {
+			t.Errorf("Expected error for %q", expression)
+		}
+	}
+	if err := engine.Validate(`{% if a %}true{% endif %}`); err == nil {
+		t.Error("Expected template tags to be rejected")
+	}
+
+	engine.EvaluateBool("count > 2", ctx)
+	if stats := engine.CacheStats(); stats.Hits == 0 {
+		t.Errorf("Expected compiled expressions to be cached, got %+v", stats)
+	}
+}
+
+func TestCELTemplateEngine_Schema(t *testing.T) {
+	engine, err := pipelinex.NewCELTemplateEngine(map[string]string{
+		"Param":    "map",
+		"replicas": "int",
+		"branch":   "string",
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewCELTemplateEngine failed: %v", err)
+	}
+
+	if err := engine.Validate(`{{ replicas > 1 && branch.startsWith("release/") }}`); err != nil {
+		t.Errorf("Unexpected validate error: %v", err)
+	}
+	tests := []struct {
+		name       string
+		expression string
+		errPart    string
+	}{
+		{"未声明的变量", `{{ replica > 1 }}`, "undeclared reference to 'replica'"},
+		{"类型不匹配", `{{ replicas == "3" }}`, "no matching overload"},
+		{"结果不是布尔", `{{ branch }}`, "must evaluate to bool"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := engine.Validate(tt.expression); err == nil || !strings.Contains(err.Error(), tt.errPart) {
+				t.Errorf("Validate error = %v, expected to contain %q", err, tt.errPart)
+			}
+		})
+	}
+
+	// schema 声明为 int 的 JSON 数字按整数求值
+	result, err := engine.EvaluateBool(`{{ replicas == 3 }}`, map[string]any{"replicas": float64(3), "branch": "main", "Param": map[string]any{}})
+	if err != nil || !result {
+		t.Errorf("Expected replicas == 3, got %v, %v", result, err)
+	}
+
+	if _, err := pipelinex.NewCELTemplateEngine(map[string]string{"a": "object"}, nil); err == nil {
+		t.Error("Expected unsupported schema type error")
+	}
+
+	// 字符串渲染仍然使用pongo2
+	if result, _ := engine.EvaluateString("{{ branch|upper }}", map[string]any{"branch": "main"}); result != "MAIN" {
+		t.Errorf("Expected pongo2 rendering, got %q", result)
+	}
+}
+
+func TestRuntimeImpl_CELExpressionEngine(t *testing.T) {
+	ctx := context.Background()
+	runtime := pipelinex.NewRuntime(ctx)
+	runner := &recordStepRunner{local: pipelinex.NewLocalStepRunner()}
+	runtime.SetStepRunner(runner)
+
+	config := `
+Param:
+  env: prod
+  replicas: 3
+Expression:
+  engine: cel
+  schema:
+    Param: map
+Graph: |
+  stateDiagram-v2
+    [*] --> Build
+    Build --> Deploy: {{ Param.env == "prod" && Param.replicas > 2 }}
+    Build --> Skip: {{ Param.env != "prod" }}
+Nodes:
+  Build:
+    steps: [{name: build, run: "true"}]
+  Deploy:
+    steps: [{name: deploy, run: "echo {{ Param.env|upper }}"}]
+  Skip:
+    steps: [{name: skip, run: "true"}]
+`
+
+	if _, err := runtime.RunSync(ctx, "test-cel-engine", config, nil); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if deploy, ok := runner.find("deploy"); !ok || deploy.Step.Run != "echo PROD" {
+		t.Errorf("Expected Deploy to run with pongo2 rendering, got %q, %v", deploy.Step.Run, ok)
+	}
+	if _, ok := runner.find("skip"); ok {
+		t.Error("Expected Skip not to run")
+	}
+
+	// 类型检查错误在执行前返回
+	invalid := strings.Replace(config, `Param.replicas > 2`, `replicas > 2`, 1)
+	if _, err := runtime.RunSync(ctx, "test-cel-invalid", invalid, nil); err == nil || !strings.Contains(err.Error(), "invalid condition on edge Build->Deploy") {
+		t.Errorf("Expected type check error, got %v", err)
+	}
+	unknown := strings.Replace(config, "engine: cel", "engine: jsonnet", 1)
+	if _, err := runtime.RunSync(ctx, "test-cel-unknown", unknown, nil); err == nil || !strings.Contains(err.Error(), "unknown expression engine") {
+		t.Errorf("Expected unknown engine error, got %v", err)
+	}
+}
@@ -1,9 +1,16 @@
 package test
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/chenyingqiao/pipelinex"
+	"github.com/flosch/pongo2/v6"
 )
 
 func TestNewPongo2TemplateEngine(t *testing.T) {
@@ -278,3 +285,361 @@ func TestPongo2TemplateEngine_EvaluateBool_WithNodeData(t *testing.T) {
 		t.Error("Expected true for matching node status")
 	}
 }
+
+func TestPongo2TemplateEngine_Cache(t *testing.T) {
+	engine := pipelinex.NewPongo2TemplateEngineWithCache(2)
+	ctx := map[string]any{"A": "x"}
+
+	for i := 0; i < 3; i++ {
+		if ok, err := engine.EvaluateBool("{{ A == 'x' }}", ctx); err != nil || !ok {
+			t.Fatalf("EvaluateBool = %v, %v", ok, err)
+		}
+	}
+	if stats := engine.CacheStats(); stats.Size != 1 || stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("Unexpected cache stats: %+v", stats)
+	}
+
+	// 语法错误不缓存
+	engine.Validate("{% if %}")
+	if stats := engine.CacheStats(); stats.Size != 1 {
+		t.Errorf("Expected invalid expression not to be cached, got %+v", stats)
+	}
+
+	// 超出容量时淘汰最久未使用的模板
+	engine.Validate("{{ B }}")
+	engine.EvaluateString("{{ A }}", ctx)
+	engine.Validate("{{ C }}")
+	if stats := engine.CacheStats(); stats.Size != 2 {
+		t.Errorf("Expected cache size 2, got %+v", stats)
+	}
+	before := engine.CacheStats().Misses
+	engine.Validate("{{ A }}")
+	engine.Validate("{{ B }}")
+	if misses := engine.CacheStats().Misses - before; misses != 1 {
+		t.Errorf("Expected only evicted template to miss, got %d misses", misses)
+	}
+}
+
+func TestPongo2TemplateEngine_CacheDisabled(t *testing.T) {
+	engine := pipelinex.NewPongo2TemplateEngineWithCache(0)
+	engine.Validate("{{ A }}")
+	if stats := engine.CacheStats(); stats.Size != 0 {
+		t.Errorf("Expected no cached templates, got %+v", stats)
+	}
+}
+
+func TestPongo2TemplateEngine_CacheConcurrent(t *testing.T) {
+	engine := pipelinex.NewPongo2TemplateEngineWithCache(8)
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			expression := fmt.Sprintf("{{ n == %d }}", i%16)
+			ok, err := engine.EvaluateBool(expression, map[string]any{"n": i % 16})
+			if err != nil || !ok {
+				t.Errorf("EvaluateBool(%q) = %v, %v", expression, ok, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if stats := engine.CacheStats(); stats.Size > 8 || stats.Hits+stats.Misses != 32 {
+		t.Errorf("Unexpected cache stats: %+v", stats)
+	}
+}
+
+func TestPongo2TemplateEngine_StdHelpers(t *testing.T) {
+	t.Setenv("PIPELINEX_TEST_ENV", "ci")
+	engine := pipelinex.NewPongo2TemplateEngine()
+	ctx := map[string]any{
+		"version": "v1.10.0",
+		"branch":  "release/1.10",
+		"files":   []any{"docs/guide.md", "src/app/main.go"},
+		"changed": "README.md\nsrc/lib/util.go",
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		expected   bool
+	}{
+		{"semver过滤器", `{{ version|semver_gt:"1.9.5" }}`, true},
+		{"semver数字比较", `{{ semver_gt(version, "v1.2.0") }}`, true},
+		{"semver预发布版本", `{{ semver_lt("1.10.0-rc.1", version) }}`, true},
+		{"semver相等", `{{ semver_eq("1.10", version) }}`, true},
+		{"正则过滤器", `{{ branch|matches:"^release/" }}`, true},
+		{"正则函数", `{{ matches(branch, "^main$") }}`, false},
+		{"目录前缀", `{{ files|changed_files_contains:"docs/" }}`, true},
+		{"多级通配", `{{ changed_files_contains(files, "src/**/*.go") }}`, true},
+		{"单级通配", `{{ changed_files_contains(files, "src/*.go") }}`, false},
+		{"字符串文件列表", `{{ changed|changed_files_contains:"**/util.go" }}`, true},
+		{"环境变量", `{{ env("PIPELINEX_TEST_ENV") == "ci" }}`, true},
+		{"环境变量默认值", `{{ "PIPELINEX_TEST_MISSING"|env:"local" == "local" }}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := engine.EvaluateBool(tt.expression, ctx)
+			if err != nil {
+				t.Fatalf("EvaluateBool(%q) failed: %v", tt.expression, err)
+			}
+			if result != tt.expected {
+				t.Errorf("EvaluateBool(%q) = %v, expected %v", tt.expression, result, tt.expected)
+			}
+		})
+	}
+
+	if _, err := engine.EvaluateBool(`{{ semver_gt("latest", version) }}`, ctx); err == nil {
+		t.Error("Expected invalid version error")
+	}
+}
+
+func TestPongo2TemplateEngine_RegisterFilter(t *testing.T) {
+	first := pipelinex.NewPongo2TemplateEngineWithCache(16)
+	second := pipelinex.NewPongo2TemplateEngineWithCache(16)
+
+	if err := first.RegisterFilter("shout", func(in, param any) (any, error) {
+		return strings.ToUpper(fmt.Sprint(in)) + "!", nil
+	}); err != nil {
+		t.Fatalf("RegisterFilter failed: %v", err)
+	}
+	// 覆盖内置过滤器只影响当前引擎
+	first.RegisterFilter("upper", func(in, param any) (any, error) {
+		return "custom", nil
+	})
+
+	ctx := map[string]any{"name": "demo"}
+	if result, _ := first.EvaluateString(`{{ name|shout }} {{ name|upper }} {{ "a|shout" }}`, ctx); result != "DEMO! custom a|shout" {
+		t.Errorf("Unexpected result: %q", result)
+	}
+	if err := second.Validate("{{ name|shout }}"); err == nil {
+		t.Error("Expected filter not to exist on another engine")
+	}
+	if result, _ := second.EvaluateString("{{ name|upper }}", ctx); result != "DEMO" {
+		t.Errorf("Expected builtin upper on another engine, got %q", result)
+	}
+
+	// 同名过滤器在不同引擎中互不影响，重新注册后已缓存的模板会重新编译
+	second.RegisterFilter("shout", func(in, param any) (any, error) {
+		return fmt.Sprintf("%v%v", in, param), nil
+	})
+	if result, _ := second.EvaluateString(`{{ name|shout:"?" }}`, ctx); result != "demo?" {
+		t.Errorf("Unexpected result: %q", result)
+	}
+	first.RegisterFilter("shout", func(in, param any) (any, error) {
+		return "again", nil
+	})
+	if result, _ := first.EvaluateString(`{{ name|shout }} {{ name|upper }} {{ "a|shout" }}`, ctx); result != "again custom a|shout" {
+		t.Errorf("Expected re-registered filter, got %q", result)
+	}
+
+	if _, err := first.EvaluateString("{{ name|fail }}", ctx); err == nil {
+		t.Error("Expected unknown filter error")
+	}
+	first.RegisterFilter("fail", func(in, param any) (any, error) {
+		return nil, fmt.Errorf("boom")
+	})
+	if _, err := first.EvaluateString("{{ name|fail }}", ctx); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Expected filter error, got %v", err)
+	}
+	if err := first.RegisterFilter("bad-name", func(in, param any) (any, error) { return in, nil }); err == nil {
+		t.Error("Expected invalid filter name error")
+	}
+}
+
+// TestPongo2TemplateEngine_RegisterFilterAfterCompile 测试注册过滤器后已缓存的模板按新的过滤器重新编译
+func TestPongo2TemplateEngine_RegisterFilterAfterCompile(t *testing.T) {
+	engine := pipelinex.NewPongo2TemplateEngineWithCache(16)
+	ctx := map[string]any{"name": "demo"}
+
+	// 先编译并缓存使用内置过滤器的模板，再用同名过滤器覆盖
+	if err := engine.Validate("{{ name|upper }}"); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	engine.RegisterFilter("upper", func(in, param any) (any, error) { return "late", nil })
+	if result, err := engine.EvaluateString("{{ name|upper }}", ctx); err != nil || result != "late" {
+		t.Errorf("Expected registered filter after compile, got %q, %v", result, err)
+	}
+
+	// 编译和注册并发进行，注册完成后内置的 lower 被覆盖，不能使用注册前编译的模板
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					engine.EvaluateString(fmt.Sprintf("{{ name|lower }}{{ %d }}", i), ctx)
+				}
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	engine.RegisterFilter("lower", func(in, param any) (any, error) { return "custom", nil })
+	close(stop)
+	wg.Wait()
+	for i := 0; i < 4; i++ {
+		expected := fmt.Sprintf("custom%d", i)
+		if result, err := engine.EvaluateString(fmt.Sprintf("{{ name|lower }}{{ %d }}", i), ctx); err != nil || result != expected {
+			t.Errorf("Expected %q after registering, got %q, %v", expected, result, err)
+		}
+	}
+}
+
+// TestPongo2TemplateEngine_FiltersNotGlobal 测试标准过滤器和引擎过滤器不注册到pongo2的全局过滤器表
+func TestPongo2TemplateEngine_FiltersNotGlobal(t *testing.T) {
+	engine := pipelinex.NewPongo2TemplateEngineWithCache(16)
+	engine.RegisterFilter("local_only", func(in, param any) (any, error) { return in, nil })
+	if result, err := engine.EvaluateString(`{{ "1.2.0"|semver_gt:"1.1.0" }} {{ "x"|local_only }}`, nil); err != nil || result != "True x" {
+		t.Fatalf("Unexpected result: %q, %v", result, err)
+	}
+	for _, name := range []string{"semver_gt", "matches", "local_only"} {
+		if pongo2.FilterExists(name) {
+			t.Errorf("Expected filter %s not to be registered in pongo2", name)
+		}
+	}
+}
+
+// TestPongo2TemplateEngine_FilterRewrite 测试引擎过滤器在各种表达式位置中的改写
+func TestPongo2TemplateEngine_FilterRewrite(t *testing.T) {
+	engine := pipelinex.NewPongo2TemplateEngineWithCache(16)
+	engine.RegisterFilter("wrap", func(in, param any) (any, error) {
+		if param == nil {
+			param = ""
+		}
+		return fmt.Sprintf("[%v%v]", in, param), nil
+	})
+	ctx := map[string]any{"name": "Demo", "items": []any{"a", "b"}, "ok": false}
+
+	tests := []struct {
+		name       string
+		expression string
+		expected   string
+	}{
+		{name: "与内置过滤器链式使用", expression: `{{ name|lower|wrap:"!"|upper }}`, expected: "[DEMO!]"},
+		{name: "参数为变量", expression: `{{ name|wrap:items.0 }}`, expected: "[Demoa]"},
+		{name: "函数参数中使用", expression: `{{ matches(name|wrap, "^\\[") }}`, expected: "True"},
+		{name: "下标", expression: `{{ items.1|wrap }}`, expected: "[b]"},
+		{name: "逻辑或", expression: `{% if ok || name|wrap == "[Demo]" %}yes{% endif %}`, expected: "yes"},
+		{name: "字符串中的竖线", expression: `{{ "a\"|wrap"|wrap }}`, expected: `[a&quot;|wrap]`},
+		{name: "标签外的文本", expression: `x|wrap {{ name }}`, expected: "x|wrap Demo"},
+		{name: "for 标签", expression: `{% for i in items %}{{ i|wrap }}{% endfor %}`, expected: "[a][b]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := engine.EvaluateString(tt.expression, ctx)
+			if err != nil {
+				t.Fatalf("EvaluateString failed: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestPongo2TemplateEngine_RegisterFunction(t *testing.T) {
+	engine := pipelinex.NewPongo2TemplateEngineWithCache(16)
+	if err := engine.RegisterFunction("double", func(n int) int { return n * 2 }); err != nil {
+		t.Fatalf("RegisterFunction failed: %v", err)
+	}
+	if result, _ := engine.EvaluateBool("{{ double(2) == 4 }}", nil); !result {
+		t.Error("Expected double(2) == 4")
+	}
+
+	// 上下文中的同名变量优先于函数
+	if result, _ := engine.EvaluateString("{{ env }}", map[string]any{"env": "prod"}); result != "prod" {
+		t.Errorf("Expected context value to shadow function, got %q", result)
+	}
+	if err := pipelinex.NewPongo2TemplateEngineWithCache(0).Validate("{{ double(2) }}"); err != nil {
+		t.Errorf("Unexpected validate error: %v", err)
+	}
+	if result, _ := pipelinex.NewPongo2TemplateEngineWithCache(0).EvaluateString("{{ double(2) }}", nil); result == "4" {
+		t.Error("Expected function not to exist on another engine")
+	}
+
+	for _, fn := range []any{"not a func", func() {}, func() (int, int) { return 0, 0 }} {
+		if err := engine.RegisterFunction("bad", fn); err == nil {
+			t.Errorf("Expected error registering %T", fn)
+		}
+	}
+}
+
+func TestPongo2TemplateEngine_Strict(t *testing.T) {
+	engine := pipelinex.NewPongo2TemplateEngineWithCache(16)
+	ctx := map[string]any{
+		"Param":   map[string]any{"env": "prod", "regions": []any{"eu"}},
+		"version": "1.0",
+	}
+
+	// 非严格模式下未定义变量渲染为空
+	if result, err := engine.EvaluateBool(`{{ Param.enviroment == "prod" }}`, ctx); err != nil || result {
+		t.Errorf("Expected false without strict mode, got %v, %v", result, err)
+	}
+
+	engine.SetStrict(true)
+	tests := []struct {
+		name       string
+		expression string
+		undefined  string
+	}{
+		{"已定义变量", `{{ Param.env == "prod" and version }}`, ""},
+		{"拼写错误的属性", `{{ Param.enviroment == "prod" }}`, "Param.enviroment"},
+		{"未定义的顶层变量", `{% if verison %}true{% endif %}`, "verison"},
+		{"未定义的嵌套变量根", `{{ Params.env == "prod" }}`, "Params.env"},
+		{"列表下标", `{{ Param.regions.0 == "eu" }}`, ""},
+		{"越界下标", `{{ Param.regions.1 }}`, "Param.regions.1"},
+		{"循环变量", `{% for r in Param.regions %}{{ r }}{{ forloop.Counter }}{% endfor %}`, ""},
+		{"default过滤器", `{{ retries|default:3 }}`, ""},
+		{"过滤器参数", `{{ version|semver_gt:minVersion }}`, "minVersion"},
+		{"标准函数", `{{ matches(version, "^1") }}`, ""},
+		{"未定义的函数", `{{ lookup(version) }}`, "lookup"},
+		{"字符串中的标识符", `{{ "missing" == version }}`, ""},
+		{"未执行分支中的未定义变量", `{% if false %}{{ verison }}{% else %}{{ version }}{% endif %}`, ""},
+		{"未执行分支中的属性", `{% if Param.env == "dev" %}{{ Param.enviroment }}{{ lookup(version) }}{% endif %}`, ""},
+		{"执行的else分支", `{% if false %}{{ version }}{% else %}{{ Param.enviroment }}{% endif %}`, "Param.enviroment"},
+		{"同一路径下的多个引用", `{{ nodes.Build.version }}{% if false %}{{ nodes.Test.version }}{% endif %}`, "nodes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := engine.EvaluateString(tt.expression, ctx)
+			if tt.undefined == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			var undefinedErr *pipelinex.UndefinedVariableError
+			if !errors.As(err, &undefinedErr) || undefinedErr.Variable != tt.undefined {
+				t.Fatalf("Expected undefined variable %q, got %v", tt.undefined, err)
+			}
+			if !errors.Is(err, pipelinex.ErrUndefinedVariable) {
+				t.Error("Expected errors.Is ErrUndefinedVariable")
+			}
+		})
+	}
+
+	// 严格模式不修改调用方的上下文
+	param := ctx["Param"].(map[string]any)
+	if _, ok := param["enviroment"]; ok || len(param) != 2 {
+		t.Errorf("Expected context to be unchanged, got %v", param)
+	}
+}
+
+func TestPongo2TemplateEngine_References(t *testing.T) {
+	engine := pipelinex.NewPongo2TemplateEngineWithCache(16)
+	refs, err := engine.References(`{% with target=Param.env %}{% for f in files %}{{ f|upper }}{{ target }}{% endfor %}{% endwith %}{{ semver_gt(nodes.Build.outputs.version, "1.0") }}{{ x|default:1 }}`)
+	if err != nil {
+		t.Fatalf("References failed: %v", err)
+	}
+	expected := []string{"Param.env", "files", "nodes.Build.outputs.version"}
+	if !reflect.DeepEqual(refs, expected) {
+		t.Errorf("References = %v, expected %v", refs, expected)
+	}
+	if _, err := engine.References("{% if %}"); err == nil {
+		t.Error("Expected syntax error")
+	}
+}